@@ -4,6 +4,7 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
@@ -20,10 +21,36 @@ func main() {
 
 	pwd, _ := os.Getwd()
 	path := os.Args[1:][0]
+	remote := strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
 	absPath := filepath.Join(pwd, path)
 
-	configMapper := models.NewConfigurationMapperFromPath(absPath)
-	captin := core.NewCaptin(*configMapper)
+	var configMapper *models.ReloadableConfigurationMapper
+	var err error
+	if remote {
+		source := models.NewHTTPConfigSource(path)
+		if secret := os.Getenv("CAPTIN_CONFIG_SECRET"); secret != "" {
+			source.SetSecret(secret)
+		}
+		configMapper, err = models.NewRemoteConfigurationMapper(source, os.Getenv("CAPTIN_CONFIG_CACHE"))
+	} else {
+		configMapper, err = models.NewReloadableConfigurationMapper(absPath)
+	}
+	if err != nil {
+		log.WithFields(log.Fields{"error": err}).Fatal("Failed to load configurations")
+	}
+	defer configMapper.Close()
+	// schemas alongside the configuration file or in <dir>/schemas are reloaded with configurations
+	captin := core.NewCaptin(configMapper)
+	if err := captin.ValidateConfigurations(configMapper.Current().Configs()); err != nil {
+		log.WithFields(log.Fields{"error": err}).Fatal("Invalid configurations")
+	}
+	configMapper.SetValidator(captin.ValidateConfigurations)
+	models.LogEffectiveConfigurations(configMapper.Current().Configs())
+	if remote {
+		configMapper.Poll(30 * time.Second)
+	} else if err := configMapper.Watch(); err != nil {
+		log.WithFields(log.Fields{"error": err}).Error("Failed to watch configurations, reload with SIGHUP is unavailable")
+	}
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -2,12 +2,14 @@ package core
 
 import (
 	"fmt"
+	"time"
 
 	destination_filters "github.com/shoplineapp/captin/destinations/filters"
 	d "github.com/shoplineapp/captin/dispatcher"
 	interfaces "github.com/shoplineapp/captin/interfaces"
 	outgoing "github.com/shoplineapp/captin/internal/outgoing"
 	models "github.com/shoplineapp/captin/models"
+	schemas "github.com/shoplineapp/captin/schemas"
 	senders "github.com/shoplineapp/captin/senders"
 
 	captin_errors "github.com/shoplineapp/captin/errors"
@@ -36,6 +38,12 @@ type Captin struct {
 	store                interfaces.StoreInterface
 	DocumentStoreMapping map[string]interfaces.DocumentStoreInterface
 	throttler            interfaces.ThrottleInterface
+	schemaRegistry       interfaces.SchemaRegistryInterface
+	documentBatchWindow  time.Duration
+	documentBatchers     map[string]interfaces.DocumentStoreV2Interface
+	tenantPath           string
+	tenantQuotas         map[string]models.TenantQuota
+	quotaThrottler       *throttles.SlidingWindowThrottler
 }
 
 // NewCaptin - Create Captin instance with default http senders and time throttler
@@ -59,7 +67,9 @@ func NewCaptin(configMap interfaces.ConfigMapperInterface) *Captin {
 		DocumentStoreMapping: map[string]interfaces.DocumentStoreInterface{
 			"default": documentStores.NewNullDocumentStore(),
 		},
-		throttler: throttles.NewThrottler(store),
+		throttler:      throttles.NewStrategyThrottler(store),
+		tenantQuotas:   map[string]models.TenantQuota{},
+		quotaThrottler: throttles.NewSlidingWindowThrottler(store, 1),
 	}
 	return &c
 }
@@ -67,12 +77,43 @@ func NewCaptin(configMap interfaces.ConfigMapperInterface) *Captin {
 // SetStore - Set store
 func (c *Captin) SetStore(store interfaces.StoreInterface) {
 	c.store = store
-	c.throttler = throttles.NewThrottler(store)
+	c.throttler = throttles.NewStrategyThrottler(store)
+	c.quotaThrottler = throttles.NewSlidingWindowThrottler(store, 1)
 }
 
 // SetDocumentStoreMapping - Set store where event targets are being stored
 func (c *Captin) SetDocumentStoreMapping(mappings map[string]interfaces.DocumentStoreInterface) {
 	c.DocumentStoreMapping = mappings
+	c.buildDocumentBatchers()
+}
+
+// SetDocumentBatchWindow - Coalesce document lookups of throttled events within window, disabled when window is 0
+func (c *Captin) SetDocumentBatchWindow(window time.Duration) {
+	c.documentBatchWindow = window
+	c.buildDocumentBatchers()
+}
+
+func (c *Captin) buildDocumentBatchers() {
+	c.documentBatchers = map[string]interfaces.DocumentStoreV2Interface{}
+	if c.documentBatchWindow <= 0 {
+		return
+	}
+	for key, store := range c.DocumentStoreMapping {
+		c.documentBatchers[key] = documentStores.NewDocumentBatcher(store, c.documentBatchWindow, 0)
+	}
+}
+
+// SetTenantPath - Set field of events identifying their tenants, e.g. payload.merchant_id or control.tenant_id
+func (c *Captin) SetTenantPath(path string) {
+	c.tenantPath = path
+}
+
+// SetTenantQuota - Set max number of events of tenant executed in any period, tenant "" for tenants without quota
+func (c *Captin) SetTenantQuota(tenant string, quota models.TenantQuota) {
+	if c.tenantQuotas == nil {
+		c.tenantQuotas = map[string]models.TenantQuota{}
+	}
+	c.tenantQuotas[tenant] = quota
 }
 
 // SetThrottler - Set throttle
@@ -80,6 +121,30 @@ func (c *Captin) SetThrottler(throttle interfaces.ThrottleInterface) {
 	c.throttler = throttle
 }
 
+// SetSchemaRegistry - Set registry of JSON schemas for validating incoming payloads and destination payloads,
+// schemas of config mappers implementing SchemaConfigMapperInterface are preferred
+func (c *Captin) SetSchemaRegistry(registry interfaces.SchemaRegistryInterface) {
+	c.schemaRegistry = registry
+}
+
+// getConfigMap - Get current configurations of config mapper, loaded once for every event
+func (c *Captin) getConfigMap() interfaces.ConfigMapperInterface {
+	if snapshotMap, ok := c.ConfigMap.(interfaces.SnapshotConfigMapperInterface); ok {
+		return snapshotMap.Snapshot()
+	}
+	return c.ConfigMap
+}
+
+// getSchemaRegistry - Get schemas loaded with configurations of config mapper, or schemas set to Captin
+func (c *Captin) getSchemaRegistry(configMap interfaces.ConfigMapperInterface) interfaces.SchemaRegistryInterface {
+	if schemaMap, ok := configMap.(interfaces.SchemaConfigMapperInterface); ok {
+		if registry := schemaMap.SchemaRegistry(); registry != nil {
+			return registry
+		}
+	}
+	return c.schemaRegistry
+}
+
 // SetDestinationFilters - Set filters
 func (c *Captin) SetDestinationFilters(filters []destination_filters.DestinationFilterInterface) {
 	c.filters = filters
@@ -111,6 +176,27 @@ func (c *Captin) SetSenderMapping(senderMapping map[string]interfaces.EventSende
 	c.SenderMapping = senderMapping
 }
 
+// ValidateConfigurations - Validate configurations with senders, document stores and schemas of Captin,
+// can be set as validator of ReloadableConfigurationMapper
+//
+// Schemas of config mappers loading schemas are checked by the mappers, with schemas loaded with configurations.
+func (c *Captin) ValidateConfigurations(configs []interfaces.ConfigurationInterface) error {
+	rules := models.ConfigurationRules{Senders: []string{}, DocumentStores: []string{}}
+	if schemaMap, ok := c.ConfigMap.(interfaces.SchemaConfigMapperInterface); !ok || schemaMap.SchemaRegistry() == nil {
+		rules.Schemas = c.schemaRegistry
+		if rules.Schemas == nil {
+			rules.Schemas = schemas.NewSchemaRegistry()
+		}
+	}
+	for key := range c.SenderMapping {
+		rules.Senders = append(rules.Senders, key)
+	}
+	for key := range c.DocumentStoreMapping {
+		rules.DocumentStores = append(rules.DocumentStores, key)
+	}
+	return models.ValidateConfigurations(configs, rules)
+}
+
 func (c Captin) IsRunning() bool {
 	return c.Status == STATUS_RUNNING || d.PendingJobCount() > 0
 }
@@ -124,7 +210,36 @@ func (c *Captin) Execute(ie interfaces.IncomingEventInterface) (bool, []interfac
 		return false, []interfaces.ErrorInterface{&captin_errors.ExecutionError{Cause: "invalid incoming event object"}}
 	}
 
-	configs := c.ConfigMap.ConfigsForKey(e.Key)
+	configMap := c.getConfigMap()
+	schemaRegistry := c.getSchemaRegistry(configMap)
+	if schemaErr := c.validateSchema(e, schemaRegistry); schemaErr != nil {
+		c.Status = STATUS_READY
+		return false, []interfaces.ErrorInterface{schemaErr}
+	}
+
+	c.invalidateDocuments(e)
+
+	if c.tenantPath == "" && e.Tenant != "" {
+		// events never choose their tenant, hooks of tenants are only dispatched with tenant path
+		cLogger.WithFields(log.Fields{"event": e, "tenant": e.Tenant}).Warn("Tenant of event ignored without tenant path")
+		e.Tenant = ""
+	} else if c.tenantPath != "" {
+		// tenant at tenant path is authoritative
+		tenant := e.Field(c.tenantPath)
+		if e.Tenant != "" && e.Tenant != tenant {
+			c.Status = STATUS_READY
+			return false, []interfaces.ErrorInterface{&captin_errors.ExecutionError{
+				Cause: fmt.Sprintf("tenant %s of event does not match tenant at %s", e.Tenant, c.tenantPath),
+			}}
+		}
+		e.Tenant = tenant
+	}
+	if quotaErr := c.checkTenantQuota(e); quotaErr != nil {
+		c.Status = STATUS_READY
+		return false, []interfaces.ErrorInterface{quotaErr}
+	}
+
+	configs := c.configsForEvent(configMap, e)
 
 	destinations := []models.Destination{}
 	for _, config := range configs {
@@ -143,6 +258,8 @@ func (c *Captin) Execute(ie interfaces.IncomingEventInterface) (bool, []interfac
 	dispatcher.SetMiddlewares(c.dispatchMiddlewares)
 	dispatcher.SetErrorHandler(c.dispatchErrorHandler)
 	dispatcher.SetDelayer(c.dispatchDelayer)
+	dispatcher.SetSchemaRegistry(schemaRegistry)
+	dispatcher.SetDocumentBatchers(c.documentBatchers)
 	dispatcher.Dispatch(e, c.store, c.throttler, c.DocumentStoreMapping)
 
 	errors := dispatcher.GetErrors()
@@ -152,3 +269,64 @@ func (c *Captin) Execute(ie interfaces.IncomingEventInterface) (bool, []interfac
 	c.Status = STATUS_READY
 	return true, errors
 }
+
+// invalidateDocuments - Invalidate documents changed by event in document stores implementing InvalidatingDocumentStoreInterface
+func (c *Captin) invalidateDocuments(e models.IncomingEvent) {
+	for _, store := range c.DocumentStoreMapping {
+		if invalidating, ok := store.(interfaces.InvalidatingDocumentStoreInterface); ok {
+			invalidating.InvalidateEvent(e)
+		}
+	}
+}
+
+// configsForEvent - Get global configurations and configurations of tenant of event if mapper supports tenants
+func (c *Captin) configsForEvent(configMap interfaces.ConfigMapperInterface, e models.IncomingEvent) []interfaces.ConfigurationInterface {
+	if tenantMap, ok := configMap.(interfaces.TenantConfigMapperInterface); ok && e.Tenant != "" {
+		return tenantMap.ConfigsForTenantKey(e.Tenant, e.Key)
+	}
+	return configMap.ConfigsForKey(e.Key)
+}
+
+// checkTenantQuota - Count event of tenant, rejecting it if quota of tenant is exceeded
+func (c *Captin) checkTenantQuota(e models.IncomingEvent) interfaces.ErrorInterface {
+	if e.Tenant == "" || c.quotaThrottler == nil {
+		return nil
+	}
+	quota, exists := c.tenantQuotas[e.Tenant]
+	if !exists {
+		if quota, exists = c.tenantQuotas[""]; !exists {
+			return nil
+		}
+	}
+
+	allowed, _, err := c.quotaThrottler.CanTriggerWithLimit("tenant-quota:"+e.Tenant, quota.Period, quota.Limit)
+	if err != nil {
+		cLogger.WithFields(log.Fields{"event": e, "error": err}).Error("Failed to count tenant quota, event is not limited")
+		return nil
+	}
+	if !allowed {
+		cLogger.WithFields(log.Fields{"event": e, "limit": quota.Limit, "period": quota.Period}).Info("Event rejected by tenant quota")
+		return &captin_errors.ExecutionError{Cause: fmt.Sprintf("quota of tenant %s exceeded", e.Tenant)}
+	}
+	return nil
+}
+
+// validateSchema - Validate event payload against the schema registered with event key
+func (c *Captin) validateSchema(e models.IncomingEvent, schemaRegistry interfaces.SchemaRegistryInterface) interfaces.ErrorInterface {
+	if schemaRegistry == nil || schemaRegistry.Has(e.Key) == false {
+		return nil
+	}
+
+	violations, err := schemaRegistry.Validate(e.Key, e.Payload)
+	if err != nil {
+		return &captin_errors.ExecutionError{Cause: err.Error()}
+	}
+	if len(violations) > 0 {
+		cLogger.WithFields(log.Fields{"event": e, "violations": violations}).Info("Event payload rejected by schema")
+		return &captin_errors.ExecutionError{
+			Cause:      fmt.Sprintf("payload does not match schema of %s", e.Key),
+			Violations: violations,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package document_stores
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	statsd "github.com/joeycumines/statsd"
+	"github.com/mohae/deepcopy"
+	destination_filters "github.com/shoplineapp/captin/destinations/filters"
+	interfaces "github.com/shoplineapp/captin/interfaces"
+	documentStores "github.com/shoplineapp/captin/internal/document_stores"
+	models "github.com/shoplineapp/captin/models"
+	log "github.com/sirupsen/logrus"
+)
+
+var cacheLogger = log.WithFields(log.Fields{"class": "CachedDocumentStore"})
+
+// CachedDocumentStore - Cache documents of another document store by target type and id
+//
+// Documents are kept for TTL and the least recently used ones are evicted beyond MaxSize.
+// Errors are not cached. Events with keys matching InvalidateKeys (e.g. *.delete) evict
+// the document of their target, with every event executed by Captin mapping the store.
+type CachedDocumentStore struct {
+	interfaces.DocumentStoreInterface
+	interfaces.DocumentStoreV2Interface
+
+	Store   interfaces.DocumentStoreInterface
+	TTL     time.Duration
+	MaxSize int
+	// InvalidateKeys - Event key patterns which invalidate cached document of target, in the syntax of hook actions,
+	// e.g. product.delete, *.delete, **.delete or !order.delete
+	InvalidateKeys []string
+	// Name - Name of cache in metrics
+	Name         string
+	StatsdClient *statsd.Client
+
+	hits    uint64
+	misses  uint64
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+
+	matcherMu   sync.Mutex
+	matcher     *models.ActionMatcher
+	matcherKeys []string
+}
+
+type cacheEntry struct {
+	key       string
+	document  map[string]interface{}
+	expiresAt time.Time
+}
+
+// NewCachedDocumentStore - Create document store caching documents of store
+func NewCachedDocumentStore(store interfaces.DocumentStoreInterface, ttl time.Duration, maxSize int) *CachedDocumentStore {
+	return &CachedDocumentStore{
+		Store:          store,
+		TTL:            ttl,
+		MaxSize:        maxSize,
+		InvalidateKeys: []string{},
+		Name:           "default",
+		entries:        map[string]*list.Element{},
+		lru:            list.New(),
+	}
+}
+
+// GetDocument - Get document from cache or underlying store, empty document on errors which are logged, see GetDocumentContext for errors
+func (s *CachedDocumentStore) GetDocument(ie interfaces.IncomingEventInterface) map[string]interface{} {
+	document, err := s.GetDocumentContext(context.Background(), ie)
+	if err != nil {
+		cacheLogger.WithFields(log.Fields{"event": ie, "error": err}).Error("Failed to get document")
+		return map[string]interface{}{}
+	}
+	return document
+}
+
+// GetDocumentContext - Get document from cache or underlying store
+func (s *CachedDocumentStore) GetDocumentContext(ctx context.Context, ie interfaces.IncomingEventInterface) (map[string]interface{}, error) {
+	e, ok := ie.(models.IncomingEvent)
+	if !ok {
+		return nil, fmt.Errorf("CachedDocumentStore: unsupported event type %T", ie)
+	}
+	key := cacheKey(e.TargetType, e.TargetId)
+
+	if document, ok := s.get(key); ok {
+		atomic.AddUint64(&s.hits, 1)
+		s.track("hit", e)
+		return document, nil
+	}
+	atomic.AddUint64(&s.misses, 1)
+	s.track("miss", e)
+
+	document, err := documentStores.Adapt(s.Store).GetDocumentContext(ctx, e)
+	if err != nil {
+		return nil, err
+	}
+	s.set(key, document)
+	return deepcopy.Copy(document).(map[string]interface{}), nil
+}
+
+// Invalidate - Remove cached document of target
+func (s *CachedDocumentStore) Invalidate(targetType string, targetId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if element, ok := s.entries[cacheKey(targetType, targetId)]; ok {
+		s.remove(element)
+	}
+}
+
+// InvalidateEvent - Remove cached document of event target if event key matches InvalidateKeys
+func (s *CachedDocumentStore) InvalidateEvent(ie interfaces.IncomingEventInterface) bool {
+	e, ok := ie.(models.IncomingEvent)
+	if !ok || !s.invalidateMatcher().Match(e.Key) {
+		return false
+	}
+	cacheLogger.WithFields(log.Fields{"event": e, "patterns": s.InvalidateKeys}).Debug("Invalidate cached document")
+	s.Invalidate(e.TargetType, e.TargetId)
+	return true
+}
+
+// invalidateMatcher - Matcher of InvalidateKeys, compiled again when they are changed
+func (s *CachedDocumentStore) invalidateMatcher() *models.ActionMatcher {
+	s.matcherMu.Lock()
+	defer s.matcherMu.Unlock()
+	if s.matcher != nil && reflect.DeepEqual(s.matcherKeys, s.InvalidateKeys) {
+		return s.matcher
+	}
+	matcher, err := models.NewActionMatcher(s.InvalidateKeys)
+	if err != nil {
+		cacheLogger.WithFields(log.Fields{"patterns": s.InvalidateKeys, "error": err}).Error("Invalid InvalidateKeys, cache is not invalidated")
+		matcher, _ = models.NewActionMatcher(nil)
+	}
+	s.matcher = matcher
+	s.matcherKeys = append([]string{}, s.InvalidateKeys...)
+	return matcher
+}
+
+// Purge - Remove all cached documents
+func (s *CachedDocumentStore) Purge() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = map[string]*list.Element{}
+	s.lru.Init()
+}
+
+// Len - Number of cached documents, including expired ones not yet evicted
+func (s *CachedDocumentStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+// Stats - Get number of cache hits and misses
+func (s *CachedDocumentStore) Stats() (uint64, uint64) {
+	return atomic.LoadUint64(&s.hits), atomic.LoadUint64(&s.misses)
+}
+
+// Middleware - Destination middleware invalidating cache on events matching InvalidateKeys, for dispatchers without Captin
+//
+// Captin invalidates caches of its document stores with every event executed, without the middleware.
+func (s *CachedDocumentStore) Middleware() destination_filters.DestinationMiddlewareInterface {
+	return CacheInvalidationMiddleware{Store: s}
+}
+
+func (s *CachedDocumentStore) get(key string) (map[string]interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	element, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if s.TTL > 0 && time.Now().After(entry.expiresAt) {
+		s.remove(element)
+		return nil, false
+	}
+	s.lru.MoveToFront(element)
+	return deepcopy.Copy(entry.document).(map[string]interface{}), true
+}
+
+func (s *CachedDocumentStore) set(key string, document map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.entries == nil {
+		s.entries = map[string]*list.Element{}
+		s.lru = list.New()
+	}
+
+	entry := &cacheEntry{
+		key:       key,
+		document:  deepcopy.Copy(document).(map[string]interface{}),
+		expiresAt: time.Now().Add(s.TTL),
+	}
+	if element, ok := s.entries[key]; ok {
+		element.Value = entry
+		s.lru.MoveToFront(element)
+	} else {
+		s.entries[key] = s.lru.PushFront(entry)
+	}
+
+	for s.MaxSize > 0 && s.lru.Len() > s.MaxSize {
+		s.remove(s.lru.Back())
+	}
+}
+
+func (s *CachedDocumentStore) remove(element *list.Element) {
+	s.lru.Remove(element)
+	delete(s.entries, element.Value.(*cacheEntry).key)
+}
+
+func (s *CachedDocumentStore) track(result string, e models.IncomingEvent) {
+	if s.StatsdClient != nil {
+		s.StatsdClient.Increment(fmt.Sprintf("hook.document_store.cache.%s,metricname=%s,target_type=%s", result, s.Name, e.TargetType))
+	}
+}
+
+func cacheKey(targetType string, targetId string) string {
+	return fmt.Sprintf("%s.%s", targetType, targetId)
+}
+
+// CacheInvalidationMiddleware - Invalidate cached document when event key matches InvalidateKeys of store,
+// destinations are returned as is
+type CacheInvalidationMiddleware struct {
+	destination_filters.DestinationMiddlewareInterface
+	Store *CachedDocumentStore
+}
+
+// Apply - Invalidate cached document of event target
+func (m CacheInvalidationMiddleware) Apply(e *models.IncomingEvent, d []models.Destination) []models.Destination {
+	m.Store.InvalidateEvent(*e)
+	return d
+}
@@ -0,0 +1,168 @@
+package document_stores
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	captin_errors "github.com/shoplineapp/captin/errors"
+	interfaces "github.com/shoplineapp/captin/interfaces"
+	helpers "github.com/shoplineapp/captin/internal/helpers"
+	models "github.com/shoplineapp/captin/models"
+	log "github.com/sirupsen/logrus"
+)
+
+var hLogger = log.WithFields(log.Fields{"class": "HTTPDocumentStore"})
+
+// HTTPDocumentStore - Fetch target document from HTTP endpoint
+//
+// URLTemplate accepts placeholders {target_type}, {target_id}, {event_key} and {source},
+// e.g. https://api.example.com/{target_type}/{target_id}
+type HTTPDocumentStore struct {
+	interfaces.DocumentStoreInterface
+
+	URLTemplate string
+	// Headers - Extra request headers, e.g. Authorization
+	Headers map[string]string
+	Timeout time.Duration
+	// Retry - Number of retries on network errors, 429 and 5xx responses
+	Retry        int
+	RetryBackoff time.Duration
+	// ResponsePath - Dotted path of document in response JSON, e.g. data.product
+	ResponsePath string
+	Client       *http.Client
+}
+
+// NewHTTPDocumentStore - Create new HTTPDocumentStore with url template
+func NewHTTPDocumentStore(urlTemplate string) *HTTPDocumentStore {
+	return &HTTPDocumentStore{
+		URLTemplate:  urlTemplate,
+		Headers:      map[string]string{},
+		Timeout:      5 * time.Second,
+		Retry:        2,
+		RetryBackoff: 200 * time.Millisecond,
+		Client:       &http.Client{},
+	}
+}
+
+// GetDocument - Fetch document of event target, empty document on errors which are logged, see GetDocumentContext for errors
+func (s *HTTPDocumentStore) GetDocument(ie interfaces.IncomingEventInterface) map[string]interface{} {
+	document, err := s.GetDocumentContext(context.Background(), ie)
+	if err != nil {
+		hLogger.WithFields(log.Fields{"event": ie, "error": err}).Error("Failed to get document")
+		return map[string]interface{}{}
+	}
+	return document
+}
+
+// GetDocumentContext - Fetch document of event target, error wraps ErrDocumentNotFound on 404 response
+func (s *HTTPDocumentStore) GetDocumentContext(ctx context.Context, ie interfaces.IncomingEventInterface) (map[string]interface{}, error) {
+	e, ok := ie.(models.IncomingEvent)
+	if !ok {
+		return nil, fmt.Errorf("HTTPDocumentStore: unsupported event type %T", ie)
+	}
+	return s.fetch(ctx, e)
+}
+
+// URL - Build document url of event target
+func (s *HTTPDocumentStore) URL(e models.IncomingEvent) string {
+	return strings.NewReplacer(
+		"{target_type}", url.PathEscape(e.TargetType),
+		"{target_id}", url.PathEscape(e.TargetId),
+		"{event_key}", url.PathEscape(e.Key),
+		"{source}", url.PathEscape(e.Source),
+	).Replace(s.URLTemplate)
+}
+
+func (s *HTTPDocumentStore) fetch(ctx context.Context, e models.IncomingEvent) (map[string]interface{}, error) {
+	documentURL := s.URL(e)
+	urlLogger := hLogger.WithFields(log.Fields{"url": documentURL, "event": e})
+
+	var body []byte
+	var err error
+	for attempt := 0; attempt <= s.Retry; attempt++ {
+		if attempt > 0 {
+			urlLogger.WithFields(log.Fields{"attempt": attempt, "error": err}).Debug("Retry fetching document")
+			select {
+			case <-time.After(s.RetryBackoff * time.Duration(attempt)):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		var retryable bool
+		body, retryable, err = s.request(ctx, documentURL)
+		if err == nil || retryable == false {
+			break
+		}
+	}
+	if err != nil {
+		urlLogger.WithFields(log.Fields{"error": err}).Error("Failed to fetch document")
+		return nil, err
+	}
+
+	return s.extract(body)
+}
+
+func (s *HTTPDocumentStore) request(ctx context.Context, documentURL string) ([]byte, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", documentURL, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for key, value := range s.Headers {
+		req.Header.Set(key, value)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{}
+	}
+	if s.Timeout > 0 {
+		timeoutClient := *client
+		timeoutClient.Timeout = s.Timeout
+		client = &timeoutClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, ctx.Err() == nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, true, err
+	}
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, false, fmt.Errorf("HTTPDocumentStore: %w at %s", captin_errors.ErrDocumentNotFound, documentURL)
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		retryable := res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+		return nil, retryable, fmt.Errorf("HTTPDocumentStore: unexpected status %d from %s", res.StatusCode, documentURL)
+	}
+	return body, false, nil
+}
+
+func (s *HTTPDocumentStore) extract(body []byte) (map[string]interface{}, error) {
+	var response interface{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("HTTPDocumentStore: invalid response, %s", err)
+	}
+
+	value, ok := helpers.ValueAtPath(response, s.ResponsePath)
+	if !ok {
+		return nil, fmt.Errorf("HTTPDocumentStore: response path %s not found", s.ResponsePath)
+	}
+	document, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("HTTPDocumentStore: document at response path %s is not an object", s.ResponsePath)
+	}
+	return document, nil
+}
@@ -0,0 +1,255 @@
+package document_stores
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	captin_errors "github.com/shoplineapp/captin/errors"
+	interfaces "github.com/shoplineapp/captin/interfaces"
+	models "github.com/shoplineapp/captin/models"
+	log "github.com/sirupsen/logrus"
+)
+
+var sqlLogger = log.WithFields(log.Fields{"class": "SQLDocumentStore"})
+
+// SQLDocumentStore - Fetch target document from database/sql compatible database
+//
+// TargetType is mapped to a query in Queries, or to a table in Tables looked up by IDColumn.
+// Queries take the target id as the only parameter, e.g. SELECT * FROM orders WHERE number = $1,
+// and must match at most one row, otherwise the document is ambiguous and rejected.
+type SQLDocumentStore struct {
+	interfaces.DocumentStoreInterface
+	interfaces.BatchDocumentStoreInterface
+
+	DB      *sql.DB
+	Tables  map[string]string
+	Queries map[string]string
+	// IDColumn - Column of target id in Tables, default id
+	IDColumn string
+	// Placeholder - Parameter placeholder of the driver, ? for MySQL / SQLite and $1 for PostgreSQL
+	Placeholder string
+	// JSONColumns - Columns decoded as JSON, in addition to columns with JSON / JSONB type
+	JSONColumns []string
+}
+
+// NewSQLDocumentStore - Create new SQLDocumentStore with database connection
+func NewSQLDocumentStore(db *sql.DB) *SQLDocumentStore {
+	return &SQLDocumentStore{
+		DB:          db,
+		Tables:      map[string]string{},
+		Queries:     map[string]string{},
+		IDColumn:    "id",
+		Placeholder: "?",
+	}
+}
+
+// GetDocument - Fetch row of event target, empty document on errors which are logged, see GetDocumentContext for errors
+func (s *SQLDocumentStore) GetDocument(ie interfaces.IncomingEventInterface) map[string]interface{} {
+	document, err := s.GetDocumentContext(context.Background(), ie)
+	if err != nil {
+		sqlLogger.WithFields(log.Fields{"event": ie, "error": err}).Error("Failed to get document")
+		return map[string]interface{}{}
+	}
+	return document
+}
+
+// GetDocumentContext - Fetch row of event target, error wraps ErrDocumentNotFound when there is no matching row
+func (s *SQLDocumentStore) GetDocumentContext(ctx context.Context, ie interfaces.IncomingEventInterface) (map[string]interface{}, error) {
+	e, ok := ie.(models.IncomingEvent)
+	if !ok {
+		return nil, fmt.Errorf("SQLDocumentStore: unsupported event type %T", ie)
+	}
+	return s.fetch(ctx, e)
+}
+
+// GetDocuments - Fetch rows of event targets mapped to Tables with one query per table, for DocumentBatcher
+//
+// Documents are in the order of events and nil for targets without row, or of target types which are not mapped.
+// Targets mapped to Queries are fetched one by one.
+func (s *SQLDocumentStore) GetDocuments(ctx context.Context, events []interfaces.IncomingEventInterface) ([]map[string]interface{}, error) {
+	documents := make([]map[string]interface{}, len(events))
+	byTable := map[string][]int{}
+	for i, ie := range events {
+		e, ok := ie.(models.IncomingEvent)
+		if !ok {
+			sqlLogger.WithFields(log.Fields{"event": ie}).Warn("Skip lookup of unsupported event")
+			continue
+		}
+		if _, isQuery := s.Queries[e.TargetType]; isQuery {
+			document, err := s.fetch(ctx, e)
+			if err != nil && !errors.Is(err, captin_errors.ErrDocumentNotFound) {
+				return nil, err
+			}
+			documents[i] = document
+			continue
+		}
+		if _, ok := s.Tables[e.TargetType]; !ok {
+			sqlLogger.WithFields(log.Fields{"event": e}).Warn("Skip lookup of target type which is not mapped")
+			continue
+		}
+		byTable[e.TargetType] = append(byTable[e.TargetType], i)
+	}
+
+	for targetType, indexes := range byTable {
+		ids := []interface{}{}
+		for _, i := range indexes {
+			ids = append(ids, events[i].(models.IncomingEvent).TargetId)
+		}
+		rows, err := s.fetchAll(ctx, targetType, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range indexes {
+			documents[i] = rows[events[i].(models.IncomingEvent).TargetId]
+		}
+	}
+	return documents, nil
+}
+
+// BatchQuery - Get query of rows of target type by ids, with a placeholder per id
+func (s *SQLDocumentStore) BatchQuery(targetType string, size int) (string, error) {
+	table, ok := s.Tables[targetType]
+	if !ok {
+		return "", fmt.Errorf("SQLDocumentStore: target type %s is not mapped", targetType)
+	}
+	placeholders := make([]string, size)
+	for i := range placeholders {
+		placeholders[i] = s.Placeholder
+		if strings.HasPrefix(s.Placeholder, "$") {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+		}
+	}
+	return fmt.Sprintf("SELECT * FROM %s WHERE %s IN (%s)", table, s.IDColumn, strings.Join(placeholders, ", ")), nil
+}
+
+// fetchAll - Fetch rows of table of target type by ids, keyed by id
+func (s *SQLDocumentStore) fetchAll(ctx context.Context, targetType string, ids []interface{}) (map[string]map[string]interface{}, error) {
+	query, err := s.BatchQuery(targetType, len(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	queryLogger := sqlLogger.WithFields(log.Fields{"query": query, "size": len(ids)})
+	queryLogger.Debug("Query documents")
+
+	rows, err := s.DB.QueryContext(ctx, query, ids...)
+	if err != nil {
+		queryLogger.WithFields(log.Fields{"error": err}).Error("Failed to query documents")
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := map[string]map[string]interface{}{}
+	for rows.Next() {
+		document, err := s.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		result[fmt.Sprint(document[s.IDColumn])] = document
+	}
+	return result, rows.Err()
+}
+
+// Query - Get query for target type
+func (s *SQLDocumentStore) Query(targetType string) (string, error) {
+	if query, ok := s.Queries[targetType]; ok {
+		return query, nil
+	}
+	if table, ok := s.Tables[targetType]; ok {
+		return fmt.Sprintf("SELECT * FROM %s WHERE %s = %s LIMIT 1", table, s.IDColumn, s.Placeholder), nil
+	}
+	return "", fmt.Errorf("SQLDocumentStore: target type %s is not mapped", targetType)
+}
+
+func (s *SQLDocumentStore) fetch(ctx context.Context, e models.IncomingEvent) (map[string]interface{}, error) {
+	query, err := s.Query(e.TargetType)
+	if err != nil {
+		return nil, err
+	}
+
+	queryLogger := sqlLogger.WithFields(log.Fields{"query": query, "event": e})
+	queryLogger.Debug("Query document")
+
+	rows, err := s.DB.QueryContext(ctx, query, e.TargetId)
+	if err != nil {
+		queryLogger.WithFields(log.Fields{"error": err}).Error("Failed to query document")
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if rowsErr := rows.Err(); rowsErr != nil {
+			return nil, rowsErr
+		}
+		return nil, fmt.Errorf("SQLDocumentStore: %s %s %w", e.TargetType, e.TargetId, captin_errors.ErrDocumentNotFound)
+	}
+	document, err := s.scan(rows)
+	if err != nil {
+		return nil, err
+	}
+	if rows.Next() {
+		return nil, fmt.Errorf("SQLDocumentStore: %s %s matches more than one row", e.TargetType, e.TargetId)
+	}
+	return document, rows.Err()
+}
+
+func (s *SQLDocumentStore) scan(rows *sql.Rows) (map[string]interface{}, error) {
+	columns, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err := rows.Scan(pointers...); err != nil {
+		return nil, err
+	}
+
+	document := map[string]interface{}{}
+	for i, column := range columns {
+		value, err := s.convert(column, values[i])
+		if err != nil {
+			return nil, fmt.Errorf("SQLDocumentStore: invalid value of column %s, %s", column.Name(), err)
+		}
+		document[column.Name()] = value
+	}
+	return document, nil
+}
+
+func (s *SQLDocumentStore) convert(column *sql.ColumnType, value interface{}) (interface{}, error) {
+	var raw []byte
+	switch v := value.(type) {
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return value, nil
+	}
+
+	if s.isJSONColumn(column) {
+		var decoded interface{}
+		if err := json.Unmarshal(raw, &decoded); err != nil {
+			return nil, err
+		}
+		return decoded, nil
+	}
+	return string(raw), nil
+}
+
+func (s *SQLDocumentStore) isJSONColumn(column *sql.ColumnType) bool {
+	for _, name := range s.JSONColumns {
+		if name == column.Name() {
+			return true
+		}
+	}
+	typeName := strings.ToUpper(column.DatabaseTypeName())
+	return typeName == "JSON" || typeName == "JSONB"
+}
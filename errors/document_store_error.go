@@ -0,0 +1,8 @@
+package errors
+
+import (
+	"errors"
+)
+
+// ErrDocumentNotFound - Returned by document stores when event target does not exist
+var ErrDocumentNotFound = errors.New("document not found")
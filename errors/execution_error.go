@@ -2,6 +2,8 @@ package errors
 
 import (
 	"fmt"
+	"strings"
+
 	interfaces "github.com/shoplineapp/captin/interfaces"
 )
 
@@ -9,9 +11,13 @@ import (
 type ExecutionError struct {
 	interfaces.ErrorInterface
 
-	Cause string
+	Cause      string
+	Violations []string
 }
 
 func (e ExecutionError) Error() string {
+	if len(e.Violations) > 0 {
+		return fmt.Sprintf("ExecutionError: caused by %s (%s)", e.Cause, strings.Join(e.Violations, "; "))
+	}
 	return fmt.Sprintf("ExecutionError: caused by %s", e.Cause)
 }
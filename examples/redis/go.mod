@@ -3,9 +3,8 @@ module redis_example
 go 1.15
 
 require (
-	github.com/bsm/redis-lock v8.0.0+incompatible
 	github.com/gin-gonic/gin v1.7.0
-	github.com/go-redis/redis v6.15.2+incompatible
+	github.com/go-redis/redis v6.15.9+incompatible
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.1 // indirect
 	github.com/onsi/ginkgo v1.8.0 // indirect
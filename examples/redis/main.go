@@ -8,10 +8,11 @@ import (
 	"github.com/gin-gonic/gin"
 
 	incoming "redis_example/incoming"
-	stores "redis_example/stores"
 
+	"github.com/go-redis/redis"
 	core "github.com/shoplineapp/captin/core"
 	"github.com/shoplineapp/captin/models"
+	stores "github.com/shoplineapp/captin/stores"
 )
 
 func main() {
@@ -29,14 +30,19 @@ func main() {
 	redisHost := getEnv("CAPTIN_REDIS_HOST", "localhost")
 	redisPort := getEnv("CAPTIN_REDIS_PORT", "6379")
 
-	store := stores.NewRedisStore(fmt.Sprintf("%s:%s", redisHost, redisPort))
-	captin.SetStore(*store)
+	store, err := stores.NewRedisStore(&redis.UniversalOptions{
+		Addrs: []string{fmt.Sprintf("%s:%s", redisHost, redisPort)},
+	}, getEnv("CAPTIN_REDIS_PREFIX", ""))
+	if err != nil {
+		panic(err)
+	}
+	captin.SetStore(store)
 	fmt.Printf("[Main] %+v\n", store)
 
 	// Set up api server
 	router := gin.Default()
 	handler := incoming.HttpEventHandler{}
-	handler.Setup(*captin)
+	handler.Setup(captin)
 	handler.SetRoutes(router)
 
 	fmt.Printf("* Binding captin on 0.0.0.0%s\n", port)
@@ -1,7 +1,31 @@
 package interfaces
 
+import (
+  "context"
+)
+
 // StoreInterface - Store for throttle events
 type DocumentStoreInterface interface {
   // GetDocument - Get value from store, return the document map
   GetDocument(e IncomingEventInterface) (map[string]interface{})
 }
+
+// DocumentStoreV2Interface - Store for event targets which reports failures and supports cancellation,
+// preferred by dispatcher over DocumentStoreInterface when implemented
+type DocumentStoreV2Interface interface {
+  // GetDocumentContext - Get document of event target, return errors.ErrDocumentNotFound when target does not exist
+  GetDocumentContext(ctx context.Context, e IncomingEventInterface) (map[string]interface{}, error)
+}
+
+// BatchDocumentStoreInterface - Store which fetches documents of many event targets at once
+type BatchDocumentStoreInterface interface {
+  // GetDocuments - Get documents of events in the same order, nil for targets which do not exist
+  GetDocuments(ctx context.Context, events []IncomingEventInterface) ([]map[string]interface{}, error)
+}
+
+// InvalidatingDocumentStoreInterface - Document store invalidating documents changed by events, e.g. caches,
+// called by Captin with every event executed when implemented
+type InvalidatingDocumentStoreInterface interface {
+  // InvalidateEvent - Invalidate document of event target if changed by event, return true if invalidated
+  InvalidateEvent(e IncomingEventInterface) bool
+}
@@ -26,6 +26,13 @@ type ThrottleInterface interface {
 	CanTrigger(id string, period time.Duration) (bool, time.Duration, error)
 }
 
+// DestinationThrottleInterface - Throttle with settings of destination, e.g. throttle strategy,
+// preferred by dispatcher over ThrottleInterface when implemented
+type DestinationThrottleInterface interface {
+	// CanTriggerDestination - Check if can trigger for destination, return with time until next trigger
+	CanTriggerDestination(id string, dest DestinationInterface) (bool, time.Duration, error)
+}
+
 type ErrorHandlerInterface interface {
 	Exec(e ErrorInterface)
 }
@@ -15,6 +15,62 @@ type ConfigMapperInterface interface {
 	ConfigsForKey(eventKey string) []ConfigurationInterface
 }
 
+// TenantConfigMapperInterface - Interface for config mapper with hooks scoped to tenants, preferred by Captin
+// for events of tenants when implemented
+type TenantConfigMapperInterface interface {
+	ConfigMapperInterface
+
+	// ConfigsForTenantKey - Get global configurations and configurations of tenant for event key
+	ConfigsForTenantKey(tenant string, eventKey string) []ConfigurationInterface
+}
+
+// SchemaConfigMapperInterface - Interface for config mapper loading schemas with its configurations, preferred by Captin
+// over the registry set with SetSchemaRegistry when it has schemas
+type SchemaConfigMapperInterface interface {
+	ConfigMapperInterface
+
+	// SchemaRegistry - Get schemas loaded with current configurations, nil if there is none
+	SchemaRegistry() SchemaRegistryInterface
+}
+
+// ReloadableConfigMapperInterface - Interface for config mapper reloading configurations from its source
+type ReloadableConfigMapperInterface interface {
+	ConfigMapperInterface
+	Reload() error
+	Close() error
+}
+
+// SnapshotConfigMapperInterface - Interface for config mapper swapping its configurations, preferred by Captin
+// so that schemas and configurations for an event are looked up in the same configurations
+type SnapshotConfigMapperInterface interface {
+	ConfigMapperInterface
+
+	// Snapshot - Get current configurations and schemas, unchanged by later swaps
+	Snapshot() ConfigMapperInterface
+}
+
+// ConfigSourceInterface - Interface for source of configuration document other than local files
+type ConfigSourceInterface interface {
+	// Name - Name of document with extension of its format, e.g. hooks.yaml, JSON if no known extension
+	Name() string
+
+	// Fetch - Fetch document, error is ErrConfigurationNotModified of models if unchanged since last fetch
+	Fetch() ([]byte, error)
+}
+
+// CommittableConfigSourceInterface - Source comparing fetched documents with the last swapped one, so that documents
+// rejected by validation are fetched again instead of being reported as not modified
+type CommittableConfigSourceInterface interface {
+	// Commit - Keep document of last Fetch as unchanged, called after its configurations are swapped
+	Commit()
+}
+
+// SecretProviderInterface - Interface for provider of secrets referenced in configurations, e.g. vault:path
+type SecretProviderInterface interface {
+	// Resolve - Get secret value of name, the reference without scheme
+	Resolve(name string) (string, error)
+}
+
 type IncomingEventInterface interface {
 	GetTraceInfo() map[string]interface{}
 	GetControl() map[string]interface{}
@@ -56,3 +112,58 @@ type ConfigurationInterface interface {
 	GetExcludePayloadAttrs() []string
 	GetExtras() map[string]string
 }
+
+// SchemaConfigurationInterface - Configuration validating payloads sent with a schema,
+// payloads are not validated unless implemented
+type SchemaConfigurationInterface interface {
+	GetPayloadSchema() string
+}
+
+// DocumentConfigurationInterface - Configuration with timeout and policies on failures of getting documents,
+// documents are fetched without timeout and failures are reported unless implemented
+type DocumentConfigurationInterface interface {
+	GetDocumentTimeout() string
+	GetDocumentTimeoutValue() time.Duration
+	GetOnDocumentError() string
+	GetOnDocumentNotFound() string
+}
+
+// ThrottleStrategyConfigurationInterface - Configuration with throttle strategies and limits, preferred by throttlers
+// over throttling by period of ConfigurationInterface when implemented
+type ThrottleStrategyConfigurationInterface interface {
+	GetThrottleStrategy() string
+	GetThrottleLimit() int
+	GetThrottleBurst() int
+}
+
+// DebounceConfigurationInterface - Configuration debouncing events, events are not debounced unless implemented
+type DebounceConfigurationInterface interface {
+	GetDebounce() string
+	GetMaxWait() string
+	GetDebounceValue() time.Duration
+	GetMaxWaitValue() time.Duration
+}
+
+// ThrottleLeadingConfigurationInterface - Configuration sending throttled events on trailing edge only,
+// events are sent on leading edge unless implemented
+type ThrottleLeadingConfigurationInterface interface {
+	GetThrottleLeading() bool
+}
+
+// ThrottleKeyConfigurationInterface - Configuration composing throttle keys from fields of events,
+// events are throttled by event key and target id unless implemented
+type ThrottleKeyConfigurationInterface interface {
+	GetThrottleKey() string
+}
+
+// ThrottledPayloadsConfigurationInterface - Configuration merging and limiting throttled payloads,
+// throttled payloads are kept as they are unless implemented
+type ThrottledPayloadsConfigurationInterface interface {
+	GetThrottledPayloadsMerge() string
+	GetThrottledPayloadsLimit() int
+}
+
+// TenantConfigurationInterface - Configuration scoped to a tenant, configurations are global unless implemented
+type TenantConfigurationInterface interface {
+	GetTenant() string
+}
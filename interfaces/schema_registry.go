@@ -0,0 +1,10 @@
+package interfaces
+
+// SchemaRegistryInterface - Registry of JSON schemas for validating event payloads
+type SchemaRegistryInterface interface {
+	// Has - Check if a schema is registered with given name
+	Has(name string) bool
+
+	// Validate - Validate document against schema with given name, return the list of violations
+	Validate(name string, document interface{}) ([]string, error)
+}
@@ -18,9 +18,32 @@ type StoreInterface interface {
 	// Remove - Remove value for key
 	Remove(key string) (bool, error)
 
+	// DataKey - Key of event in destination with prefix and suffix, for keys of throttles, debounce and
+	// throttled payloads of the event
 	DataKey(e IncomingEventInterface, dest DestinationInterface, prefix string, suffix string) string
 
 	Enqueue(key string, value string, ttl time.Duration) (bool, error)
 
 	GetQueue(key string) ([]string, bool, time.Duration, error)
 }
+
+// AtomicStoreInterface - Store with atomic operations, preferred by throttlers and dispatcher when implemented
+// so that replicas sharing the store do not race on the same key
+type AtomicStoreInterface interface {
+	// SetNX - Set value into store with ttl only if key does not exist, return true if value is set
+	SetNX(key string, value string, ttl time.Duration) (bool, error)
+
+	// Incr - Increase integer value for key by delta, key is created with ttl if not exist, return with new value
+	Incr(key string, delta int64, ttl time.Duration) (int64, error)
+
+	// CompareAndSwap - Set value into store with ttl only if current value equals old, return true if value is swapped
+	CompareAndSwap(key string, old string, value string, ttl time.Duration) (bool, error)
+}
+
+// BoundedQueueStoreInterface - Store capping queues when values are enqueued, preferred by dispatcher when implemented
+// so that queues of throttled events never grow beyond their limits
+type BoundedQueueStoreInterface interface {
+	// EnqueueWithLimit - Append value to queue and keep only the latest limit values, unlimited if limit is not positive,
+	// ttl is set when first element is enqueued
+	EnqueueWithLimit(key string, value string, ttl time.Duration, limit int) (bool, error)
+}
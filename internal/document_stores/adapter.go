@@ -0,0 +1,52 @@
+package document_stores
+
+import (
+	"context"
+	"fmt"
+
+	interfaces "github.com/shoplineapp/captin/interfaces"
+)
+
+// DocumentStoreAdapter - Adapt DocumentStoreInterface to DocumentStoreV2Interface
+type DocumentStoreAdapter struct {
+	interfaces.DocumentStoreV2Interface
+	store interfaces.DocumentStoreInterface
+}
+
+// Adapt - Get DocumentStoreV2Interface of store, wrap with adapter if store only implements DocumentStoreInterface
+func Adapt(store interfaces.DocumentStoreInterface) interfaces.DocumentStoreV2Interface {
+	if v2, ok := store.(interfaces.DocumentStoreV2Interface); ok {
+		return v2
+	}
+	return &DocumentStoreAdapter{store: store}
+}
+
+// GetDocumentContext - Get document with legacy store, panics are returned as error.
+// Legacy stores cannot be cancelled, the lookup is abandoned when context is done.
+func (a *DocumentStoreAdapter) GetDocumentContext(ctx context.Context, e interfaces.IncomingEventInterface) (map[string]interface{}, error) {
+	type result struct {
+		document map[string]interface{}
+		err      error
+	}
+	ch := make(chan result, 1)
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err, ok := r.(error)
+				if !ok {
+					err = fmt.Errorf("%v", r)
+				}
+				ch <- result{err: err}
+			}
+		}()
+		ch <- result{document: a.store.GetDocument(e)}
+	}()
+
+	select {
+	case r := <-ch:
+		return r.document, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
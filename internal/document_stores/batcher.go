@@ -0,0 +1,203 @@
+package document_stores
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mohae/deepcopy"
+	captin_errors "github.com/shoplineapp/captin/errors"
+	interfaces "github.com/shoplineapp/captin/interfaces"
+	models "github.com/shoplineapp/captin/models"
+	log "github.com/sirupsen/logrus"
+)
+
+var bLogger = log.WithFields(log.Fields{"class": "DocumentBatcher"})
+
+// DocumentBatcher - Coalesce document lookups within a time window into one batch,
+// fetched with GetDocuments if store implements BatchDocumentStoreInterface or per event otherwise.
+// Lookups of the same target within a window are fetched once. Batches are cancelled at the latest deadline
+// of their lookups, so that stores are not left fetching for callers which stopped waiting.
+type DocumentBatcher struct {
+	interfaces.DocumentStoreV2Interface
+
+	store    interfaces.DocumentStoreInterface
+	window   time.Duration
+	maxBatch int
+
+	mu      sync.Mutex
+	pending map[string]*batchRequest
+	order   []*batchRequest
+	timer   *time.Timer
+}
+
+type batchRequest struct {
+	event    models.IncomingEvent
+	document map[string]interface{}
+	err      error
+	done     chan struct{}
+	// deadline - Latest deadline of lookups waiting for the document, zero if any of them has no deadline
+	deadline time.Time
+}
+
+// NewDocumentBatcher - Create batcher of store, batch is fetched after window or when it reaches maxBatch (0 for unlimited)
+func NewDocumentBatcher(store interfaces.DocumentStoreInterface, window time.Duration, maxBatch int) *DocumentBatcher {
+	return &DocumentBatcher{
+		store:    store,
+		window:   window,
+		maxBatch: maxBatch,
+		pending:  map[string]*batchRequest{},
+	}
+}
+
+// GetDocumentContext - Add lookup to current batch and wait for its document, events other than IncomingEvent are not batched
+func (b *DocumentBatcher) GetDocumentContext(ctx context.Context, ie interfaces.IncomingEventInterface) (map[string]interface{}, error) {
+	e, ok := ie.(models.IncomingEvent)
+	if b.window <= 0 || !ok {
+		return Adapt(b.store).GetDocumentContext(ctx, ie)
+	}
+
+	req := b.enqueue(ctx, e)
+	select {
+	case <-req.done:
+		if req.err != nil {
+			return nil, req.err
+		}
+		return deepcopy.Copy(req.document).(map[string]interface{}), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (b *DocumentBatcher) enqueue(ctx context.Context, e models.IncomingEvent) *batchRequest {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	deadline, _ := ctx.Deadline()
+	key := fmt.Sprintf("%s.%s", e.TargetType, e.TargetId)
+	if req, ok := b.pending[key]; ok {
+		if !req.deadline.IsZero() && (deadline.IsZero() || deadline.After(req.deadline)) {
+			req.deadline = deadline
+		}
+		return req
+	}
+
+	req := &batchRequest{event: e, done: make(chan struct{}), deadline: deadline}
+	b.pending[key] = req
+	b.order = append(b.order, req)
+
+	if b.maxBatch > 0 && len(b.order) >= b.maxBatch {
+		if b.timer != nil {
+			b.timer.Stop()
+		}
+		go b.fetch(b.take())
+	} else if len(b.order) == 1 {
+		b.timer = time.AfterFunc(b.window, b.flush)
+	}
+	return req
+}
+
+// take - Take pending requests as a batch, caller must hold lock
+func (b *DocumentBatcher) take() []*batchRequest {
+	batch := b.order
+	b.pending = map[string]*batchRequest{}
+	b.order = nil
+	b.timer = nil
+	return batch
+}
+
+func (b *DocumentBatcher) flush() {
+	b.mu.Lock()
+	batch := b.take()
+	b.mu.Unlock()
+
+	if len(batch) > 0 {
+		b.fetch(batch)
+	}
+}
+
+func (b *DocumentBatcher) fetch(batch []*batchRequest) {
+	defer func() {
+		for _, req := range batch {
+			close(req.done)
+		}
+	}()
+
+	batchStore, ok := b.store.(interfaces.BatchDocumentStoreInterface)
+	if !ok {
+		b.fetchEach(batch)
+		return
+	}
+
+	events := make([]interfaces.IncomingEventInterface, len(batch))
+	for i, req := range batch {
+		events[i] = req.event
+	}
+	bLogger.WithFields(log.Fields{"size": len(batch)}).Debug("Fetch documents in batch")
+
+	latest := batch[0].deadline
+	for _, req := range batch {
+		if req.deadline.IsZero() || latest.IsZero() {
+			latest = time.Time{}
+			break
+		}
+		if req.deadline.After(latest) {
+			latest = req.deadline
+		}
+	}
+	ctx, cancel := deadlineContext(latest)
+	defer cancel()
+
+	documents, err := b.getDocuments(ctx, batchStore, events)
+	if err == nil && len(documents) != len(batch) {
+		err = fmt.Errorf("DocumentBatcher: expected %d documents, got %d", len(batch), len(documents))
+	}
+	for i, req := range batch {
+		switch {
+		case err != nil:
+			req.err = err
+		case documents[i] == nil:
+			req.err = fmt.Errorf("%s %s %w", req.event.TargetType, req.event.TargetId, captin_errors.ErrDocumentNotFound)
+		default:
+			req.document = documents[i]
+		}
+	}
+}
+
+func (b *DocumentBatcher) getDocuments(ctx context.Context, store interfaces.BatchDocumentStoreInterface, events []interfaces.IncomingEventInterface) (documents []map[string]interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if recovered, ok := r.(error); ok {
+				err = recovered
+			} else {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+	return store.GetDocuments(ctx, events)
+}
+
+// fetchEach - Fallback to lookups per event for stores without batch support
+func (b *DocumentBatcher) fetchEach(batch []*batchRequest) {
+	store := Adapt(b.store)
+	wg := sync.WaitGroup{}
+	for _, req := range batch {
+		wg.Add(1)
+		go func(req *batchRequest) {
+			defer wg.Done()
+			ctx, cancel := deadlineContext(req.deadline)
+			defer cancel()
+			req.document, req.err = store.GetDocumentContext(ctx, req.event)
+		}(req)
+	}
+	wg.Wait()
+}
+
+// deadlineContext - Context cancelled at deadline, or never for zero deadline
+func deadlineContext(deadline time.Time) (context.Context, context.CancelFunc) {
+	if deadline.IsZero() {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithDeadline(context.Background(), deadline)
+}
@@ -0,0 +1,44 @@
+package helpers
+
+import (
+	"github.com/mohae/deepcopy"
+)
+
+// DeepMerge - Merge objects in order into a new object, later values win and nested objects are merged
+// recursively, while arrays and other values are replaced
+func DeepMerge(objects ...map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{}
+	for _, object := range objects {
+		deepMergeInto(result, object)
+	}
+	return result
+}
+
+// ShallowMerge - Merge top level keys of objects in order into a new object, later values win
+func ShallowMerge(objects ...map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{}
+	for _, object := range objects {
+		for key, value := range object {
+			result[key] = deepcopy.Copy(value)
+		}
+	}
+	return result
+}
+
+func deepMergeInto(target map[string]interface{}, source map[string]interface{}) {
+	for key, value := range source {
+		sourceMap, sourceIsMap := value.(map[string]interface{})
+		targetMap, targetIsMap := target[key].(map[string]interface{})
+		if sourceIsMap && targetIsMap {
+			deepMergeInto(targetMap, sourceMap)
+			continue
+		}
+		if sourceIsMap {
+			merged := map[string]interface{}{}
+			deepMergeInto(merged, sourceMap)
+			target[key] = merged
+			continue
+		}
+		target[key] = deepcopy.Copy(value)
+	}
+}
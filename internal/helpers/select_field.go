@@ -1,102 +1,337 @@
 package helpers
 
 import (
-  "fmt"
-  "sort"
-  "strings"
-  "reflect"
-  "github.com/thoas/go-funk"
-  "github.com/mohae/deepcopy"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mohae/deepcopy"
+)
+
+// Field selectors used in include / exclude attrs, e.g.
+//   foo.bar            nested field, applied to every element when foo is an array
+//   items.*.sku        wildcard on any key or any array element
+//   items[0].sku       array element by index
+//   price as amount    rename the field in result
+//   email:mask         mask the value, keeping the last characters (or the domain of emails)
+//   card_no:hash       replace the value with its sha256 hex digest
+// Within exclude attrs, a field with rename or transformation is kept and transformed instead of being removed.
+
+const (
+	TransformMask = "mask"
+	TransformHash = "hash"
+
+	wildcardSegment = "*"
 )
 
-func IncludeFields(object map[string]interface{}, fields []string) interface{} {
-  fieldTree := buildFieldTree(fields)
-  clone := deepcopy.Copy(object)
-  return filter(clone, fieldTree, "include")
+var aliasPattern = regexp.MustCompile(`^(.+?)\s+as(?:\s+(.*))?$`)
+var segmentPattern = regexp.MustCompile(`^([^\[\]]*)((?:\[\d+\])*)$`)
+var indexPattern = regexp.MustCompile(`\[(\d+)\]`)
+
+type fieldNode struct {
+	children  map[string]*fieldNode
+	alias     string
+	transform string
+}
+
+func (n *fieldNode) isLeaf() bool {
+	return len(n.children) == 0
+}
+
+// plain - subtree of keys applied to each element of arrays, excluding wildcard and indexes
+func (n *fieldNode) plain() *fieldNode {
+	var result *fieldNode
+	for key, child := range n.children {
+		if key == wildcardSegment || strings.HasPrefix(key, "[") {
+			continue
+		}
+		if result == nil {
+			result = &fieldNode{children: map[string]*fieldNode{}}
+		}
+		result.children[key] = child
+	}
+	return result
+}
+
+// IncludeFields - Copy of object with only selected fields, error if any selector is invalid
+func IncludeFields(object map[string]interface{}, fields []string) (interface{}, error) {
+	fieldTree, err := buildFieldTree(fields)
+	if err != nil {
+		return nil, err
+	}
+	clone := deepcopy.Copy(object)
+	return filter(clone, fieldTree, "include"), nil
 }
 
-func ExcludeFields(object map[string]interface{}, fields []string) interface{} {
-  fieldTree := buildFieldTree(fields)
-  clone := deepcopy.Copy(object)
-  return filter(clone, fieldTree, "exclude")
+// ExcludeFields - Copy of object without selected fields, error if any selector is invalid
+func ExcludeFields(object map[string]interface{}, fields []string) (interface{}, error) {
+	fieldTree, err := buildFieldTree(fields)
+	if err != nil {
+		return nil, err
+	}
+	clone := deepcopy.Copy(object)
+	return filter(clone, fieldTree, "exclude"), nil
 }
 
-func buildNode(ancestor map[string]interface{}, nodes []string, i int) {
-  if(i >= len(nodes)) { return }
+// ValidateFields - Check if field selectors can be parsed
+func ValidateFields(fields []string) error {
+	_, err := buildFieldTree(fields)
+	return err
+}
 
-  node := nodes[i]
+// parseField - Parse selector into path segments, transformation and alias
+func parseField(field string) ([]string, string, string, error) {
+	path, alias := strings.TrimSpace(field), ""
+	if match := aliasPattern.FindStringSubmatch(path); match != nil {
+		path, alias = match[1], strings.TrimSpace(match[2])
+		if alias == "" {
+			return nil, "", "", fmt.Errorf("invalid field %q: missing alias", field)
+		}
+	}
 
-  if(ancestor[node] == nil) { ancestor[node] = map[string]interface{}{} }
+	transform := ""
+	if i := strings.LastIndex(path, ":"); i >= 0 {
+		path, transform = path[:i], path[i+1:]
+		if transform != TransformMask && transform != TransformHash {
+			return nil, "", "", fmt.Errorf("invalid field %q: unknown transformation %q", field, transform)
+		}
+	}
 
-  buildNode(ancestor[node].(map[string]interface{}), nodes, i + 1)
+	segments := []string{}
+	for _, part := range strings.Split(path, ".") {
+		match := segmentPattern.FindStringSubmatch(part)
+		if match == nil || (match[1] == "" && match[2] == "") {
+			return nil, "", "", fmt.Errorf("invalid field %q", field)
+		}
+		if match[1] != "" {
+			segments = append(segments, match[1])
+		}
+		for _, index := range indexPattern.FindAllStringSubmatch(match[2], -1) {
+			segments = append(segments, fmt.Sprintf("[%s]", index[1]))
+		}
+	}
+	return segments, transform, alias, nil
 }
 
 // adapted from open-api-node FieldFilter
-func buildFieldTree(fields []string) map[string]interface{} {
-  sort.Strings(fields)
-
-  tree := map[string]interface{}{}
-
-  for _, field := range fields {
-    nodes := strings.Split(field, ".")
-    buildNode(tree, nodes, 0)
-  }
-
-  return tree
-}
-
-func applyFilter(result map[string]interface{}, key string, value interface{}, mode string) {
-  switch (mode) {
-    case "include":
-      result[key] = value
-    case "exclude":
-      delete(result, key)
-    default:
-      panic(fmt.Sprintf("unknown filter mode %s", "mode"))
-  }
-}
-
-func filter(object interface{}, fieldTree map[string]interface{}, mode string) interface{} {
-  var result interface{}
-
-  switch mode {
-    case "include":
-      result = map[string]interface{}{}
-    case "exclude":
-      result = object
-  }
-
-  // do not filter primitive types (e.g, object contains array of strings)
-  if(reflect.TypeOf(object).Kind() != reflect.Map) {
-    return object
-  }
-
-  // eslint-disable-next-line no-restricted-syntax
-  for key, value := range object.(map[string]interface{}) {
-    // not in include/exclude tree, keep it as is
-    if (!funk.Contains(funk.Keys(fieldTree), key)) {
-      continue
-    }
-
-    // {} is wildcard, keep/discard everything
-    if (reflect.TypeOf(fieldTree[key]).Kind() == reflect.Map && len(fieldTree[key].(map[string]interface{})) == 0){
-      applyFilter(result.(map[string]interface{}), key, value, mode)
-      continue
-    }
-
-    // nested object
-    if (reflect.TypeOf(value).Kind() == reflect.Map) {
-      result.(map[string]interface{})[key] = filter(value, fieldTree[key].(map[string]interface{}), mode)
-      continue
-    }
-
-    // apply filter on each element of array
-    if (reflect.TypeOf(value).Kind() == reflect.Slice || reflect.TypeOf(value).Kind() == reflect.Array) {
-      result.(map[string]interface{})[key] = funk.Map(value, func(element interface{}) interface{} {
-        return filter(element, fieldTree[key].(map[string]interface{}), mode)
-      })
-    }
-  }
-
-  return result
+func buildFieldTree(fields []string) (*fieldNode, error) {
+	tree := &fieldNode{children: map[string]*fieldNode{}}
+
+	for _, field := range fields {
+		segments, transform, alias, err := parseField(field)
+		if err != nil {
+			return nil, err
+		}
+
+		node := tree
+		for _, segment := range segments {
+			if node.children[segment] == nil {
+				node.children[segment] = &fieldNode{children: map[string]*fieldNode{}}
+			}
+			node = node.children[segment]
+		}
+		node.transform = transform
+		node.alias = alias
+	}
+
+	return tree, nil
+}
+
+// combine - merge subtrees matching the same value, a leaf selects the whole value and wins over nested fields
+func combine(nodes ...*fieldNode) *fieldNode {
+	matched := []*fieldNode{}
+	for _, node := range nodes {
+		if node != nil {
+			matched = append(matched, node)
+		}
+	}
+	if len(matched) == 0 {
+		return nil
+	}
+	if len(matched) == 1 {
+		return matched[0]
+	}
+
+	result := &fieldNode{children: map[string]*fieldNode{}}
+	for _, node := range matched {
+		if result.alias == "" {
+			result.alias = node.alias
+		}
+		if result.transform == "" {
+			result.transform = node.transform
+		}
+	}
+	for _, node := range matched {
+		if node.isLeaf() {
+			result.children = map[string]*fieldNode{}
+			return result
+		}
+		for key, child := range node.children {
+			result.children[key] = combine(result.children[key], child)
+		}
+	}
+	return result
+}
+
+func filter(object interface{}, fieldTree *fieldNode, mode string) interface{} {
+	switch mode {
+	case "include", "exclude":
+	default:
+		panic(fmt.Sprintf("unknown filter mode %s", mode))
+	}
+
+	result, ok := filterNested(object, fieldTree, mode)
+	if !ok {
+		return object
+	}
+	return result
+}
+
+// filterNested - filter maps and arrays with subtree, return false if value should be dropped
+func filterNested(value interface{}, node *fieldNode, mode string) (interface{}, bool) {
+	if m, ok := value.(map[string]interface{}); ok {
+		return filterMap(m, node, mode), true
+	}
+	if value != nil && (reflect.TypeOf(value).Kind() == reflect.Slice || reflect.TypeOf(value).Kind() == reflect.Array) {
+		return filterSlice(reflect.ValueOf(value), node, mode), true
+	}
+	// nested fields on primitive types are not found, keep them only on exclude
+	return value, mode == "exclude"
+}
+
+func filterMap(object map[string]interface{}, node *fieldNode, mode string) map[string]interface{} {
+	result := map[string]interface{}{}
+	if mode == "exclude" {
+		for key, value := range object {
+			result[key] = value
+		}
+	}
+
+	for key, value := range object {
+		child := combine(node.children[key], node.children[wildcardSegment])
+		// not in include/exclude tree, keep it as is
+		if child == nil {
+			continue
+		}
+
+		resultKey := key
+		if child.alias != "" && node.children[key] != nil {
+			resultKey = child.alias
+		}
+
+		if child.isLeaf() {
+			if mode == "exclude" {
+				delete(result, key)
+				if child.transform == "" && child.alias == "" {
+					continue
+				}
+			}
+			result[resultKey] = applyTransform(value, child.transform)
+			continue
+		}
+
+		filtered, ok := filterNested(value, child, mode)
+		if mode == "exclude" {
+			delete(result, key)
+		}
+		if ok {
+			result[resultKey] = filtered
+		}
+	}
+
+	return result
+}
+
+func filterSlice(elements reflect.Value, node *fieldNode, mode string) []interface{} {
+	result := []interface{}{}
+	plain := node.plain()
+
+	for i := 0; i < elements.Len(); i++ {
+		element := elements.Index(i).Interface()
+		child := combine(node.children[fmt.Sprintf("[%d]", i)], node.children[wildcardSegment], plain)
+
+		if child == nil {
+			if mode == "exclude" {
+				result = append(result, element)
+			}
+			continue
+		}
+
+		if child.isLeaf() {
+			if mode == "include" || child.transform != "" {
+				result = append(result, applyTransform(element, child.transform))
+			}
+			continue
+		}
+
+		// do not filter primitive types (e.g, object contains array of strings)
+		filtered, _ := filterNested(element, child, mode)
+		result = append(result, filtered)
+	}
+
+	return result
+}
+
+func applyTransform(value interface{}, transform string) interface{} {
+	if transform == "" || value == nil {
+		return value
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for key, nested := range v {
+			result[key] = applyTransform(nested, transform)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, nested := range v {
+			result[i] = applyTransform(nested, transform)
+		}
+		return result
+	}
+
+	switch transform {
+	case TransformMask:
+		return mask(stringify(value))
+	case TransformHash:
+		sum := sha256.Sum256([]byte(stringify(value)))
+		return hex.EncodeToString(sum[:])
+	default:
+		panic(fmt.Sprintf("unknown transformation %s", transform))
+	}
+}
+
+func stringify(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(bytes)
+}
+
+// mask - keep first character and domain of emails, or the last 4 characters of other values
+func mask(value string) string {
+	if i := strings.LastIndex(value, "@"); i > 0 {
+		local := []rune(value[:i])
+		return string(local[0]) + strings.Repeat("*", len(local)-1) + value[i:]
+	}
+
+	runes := []rune(value)
+	if len(runes) <= 4 {
+		return strings.Repeat("*", len(runes))
+	}
+	return strings.Repeat("*", len(runes)-4) + string(runes[len(runes)-4:])
 }
@@ -0,0 +1,37 @@
+package helpers
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ValueAtPath - Get value in nested maps and arrays by dotted path, e.g. data.items.0.name
+func ValueAtPath(object interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return object, true
+	}
+
+	current := object
+	for _, segment := range strings.Split(path, ".") {
+		switch value := current.(type) {
+		case map[string]interface{}:
+			next, ok := value[segment]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		default:
+			if value == nil || reflect.TypeOf(value).Kind() != reflect.Slice {
+				return nil, false
+			}
+			index, err := strconv.Atoi(segment)
+			list := reflect.ValueOf(value)
+			if err != nil || index < 0 || index >= list.Len() {
+				return nil, false
+			}
+			current = list.Index(index).Interface()
+		}
+	}
+	return current, true
+}
@@ -1,12 +1,16 @@
 package outgoing
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/mohae/deepcopy"
 	destination_filters "github.com/shoplineapp/captin/destinations/filters"
 	"github.com/shoplineapp/captin/dispatcher"
@@ -21,16 +25,23 @@ import (
 var dLogger = log.WithFields(log.Fields{"class": "Dispatcher"})
 var nullDocumentStore = documentStores.NewNullDocumentStore()
 
+// maxDebounceAttempts - Attempts of compare-and-swap on debounce token before failing the event
+const maxDebounceAttempts = 10
+
 // Dispatcher - Event Dispatcher
 type Dispatcher struct {
 	destinations   []models.Destination
 	senderMapping  map[string]interfaces.EventSenderInterface
 	Errors         []interfaces.ErrorInterface
 	targetDocument map[string]interface{}
-	filters        []destination_filters.DestinationFilterInterface
-	middlewares    []destination_filters.DestinationMiddlewareInterface
-	errorHandler   interfaces.ErrorHandlerInterface
-	delayer        interfaces.DispatchDelayerInterface
+	// targetDocumentErrs - Errors of getting document by document timeout of destinations
+	targetDocumentErrs map[time.Duration]error
+	filters            []destination_filters.DestinationFilterInterface
+	middlewares        []destination_filters.DestinationMiddlewareInterface
+	errorHandler       interfaces.ErrorHandlerInterface
+	delayer            interfaces.DispatchDelayerInterface
+	schemaRegistry     interfaces.SchemaRegistryInterface
+	documentBatchers   map[string]interfaces.DocumentStoreV2Interface
 
 	muTargetDocument sync.Mutex
 	muErrors         sync.Mutex
@@ -68,6 +79,15 @@ func (d *Dispatcher) SetDelayer(delayer interfaces.DispatchDelayerInterface) {
 	d.delayer = delayer
 }
 
+func (d *Dispatcher) SetSchemaRegistry(registry interfaces.SchemaRegistryInterface) {
+	d.schemaRegistry = registry
+}
+
+// SetDocumentBatchers - Set batchers of document stores for fetching documents of throttled events
+func (d *Dispatcher) SetDocumentBatchers(batchers map[string]interfaces.DocumentStoreV2Interface) {
+	d.documentBatchers = batchers
+}
+
 func (d *Dispatcher) GetErrors() []interfaces.ErrorInterface {
 	d.muErrors.Lock()
 	defer d.muErrors.Unlock()
@@ -77,6 +97,14 @@ func (d *Dispatcher) GetErrors() []interfaces.ErrorInterface {
 func (d *Dispatcher) OnError(evt interfaces.IncomingEventInterface, err interfaces.ErrorInterface) {
 	d.muErrors.Lock()
 	defer d.muErrors.Unlock()
+
+	// Errors of senders may contain callback url or extras with secrets of configuration
+	switch redactedErr := err.(type) {
+	case *captin_errors.DispatcherError:
+		redactedErr.Msg = redactedErr.Destination.Redact(redactedErr.Msg)
+	case *captin_errors.UnretryableError:
+		redactedErr.Msg = redactedErr.Destination.Redact(redactedErr.Msg)
+	}
 	d.Errors = append(d.Errors, err)
 
 	switch dispatcherErr := err.(type) {
@@ -103,30 +131,45 @@ func (d *Dispatcher) Dispatch(
 	e := event.(models.IncomingEvent)
 	for _, destination := range d.destinations {
 		config := destination.Config
-		canTrigger, timeRemain, err := throttler.CanTrigger(getEventKey(store, e, destination), config.GetThrottleValue())
 		documentStore := d.getDocumentStore(destination, documentStoreMappings)
 
+		if models.DebounceOptionsOf(config).GetDebounceValue() > 0 {
+			go func(e models.IncomingEvent, destination models.Destination, documentStore interfaces.DocumentStoreV2Interface) {
+				d.processDebouncedEvent(e, destination, store, documentStore)
+				responses <- 1
+			}(e, destination, d.getThrottledDocumentStore(destination, documentStore))
+			continue
+		}
+
+		canTrigger, timeRemain, err := canTrigger(throttler, getEventKey(store, e, destination), destination)
+
 		if err != nil {
 			dLogger.WithFields(log.Fields{"event": e, "destination": destination, "error": err}).Error("Error on getting throttle key")
 
 			// Send without throttling
-			go func(e models.IncomingEvent, destination models.Destination, documentStore interfaces.DocumentStoreInterface) {
+			go func(e models.IncomingEvent, destination models.Destination, documentStore interfaces.DocumentStoreV2Interface) {
 				d.sendEvent(e, destination, store, documentStore)
 				responses <- 1
 			}(e, destination, documentStore)
 			continue
 		}
 
-		if canTrigger {
-			go func(e models.IncomingEvent, destination models.Destination, documentStore interfaces.DocumentStoreInterface) {
+		if canTrigger && !models.ThrottleLeadingOf(config) && config.GetThrottleValue() > 0 {
+			// Trailing edge only, hold the first event until end of throttle period
+			go func(e models.IncomingEvent, destination models.Destination, documentStore interfaces.DocumentStoreV2Interface) {
+				d.processDelayedEvent(e, config.GetThrottleValue(), destination, store, documentStore)
+				responses <- 1
+			}(e, destination, d.getThrottledDocumentStore(destination, documentStore))
+		} else if canTrigger {
+			go func(e models.IncomingEvent, destination models.Destination, documentStore interfaces.DocumentStoreV2Interface) {
 				d.sendEvent(e, destination, store, documentStore)
 				responses <- 1
 			}(e, destination, documentStore)
 		} else if !config.GetThrottleTrailingDisabled() {
-			go func(e models.IncomingEvent, destination models.Destination, documentStore interfaces.DocumentStoreInterface) {
+			go func(e models.IncomingEvent, destination models.Destination, documentStore interfaces.DocumentStoreV2Interface) {
 				d.processDelayedEvent(e, timeRemain, destination, store, documentStore)
 				responses <- 1
-			}(e, destination, documentStore)
+			}(e, destination, d.getThrottledDocumentStore(destination, documentStore))
 		} else {
 			dLogger.WithFields(log.Fields{"event": e, "destination": destination}).Info("Cannot trigger send event")
 			responses <- 0
@@ -149,20 +192,52 @@ func (d *Dispatcher) TriggerErrorHandler(err *captin_errors.DispatcherError) {
 
 // Private Functions
 
-func (d *Dispatcher) getDocumentStore(dest models.Destination, documentStoreMappings map[string]interfaces.DocumentStoreInterface) interfaces.DocumentStoreInterface {
+// canTrigger - Check throttle with destination settings if supported by throttler
+func canTrigger(throttler interfaces.ThrottleInterface, id string, dest models.Destination) (bool, time.Duration, error) {
+	if destinationThrottler, ok := throttler.(interfaces.DestinationThrottleInterface); ok {
+		return destinationThrottler.CanTriggerDestination(id, dest)
+	}
+	return throttler.CanTrigger(id, dest.Config.GetThrottleValue())
+}
+
+func (d *Dispatcher) getDocumentStore(dest models.Destination, documentStoreMappings map[string]interfaces.DocumentStoreInterface) interfaces.DocumentStoreV2Interface {
 	if documentStoreMappings[dest.GetDocumentStore()] != nil {
-		return documentStoreMappings[dest.GetDocumentStore()]
+		return documentStores.Adapt(documentStoreMappings[dest.GetDocumentStore()])
+	}
+	return documentStores.Adapt(nullDocumentStore)
+}
+
+// getThrottledDocumentStore - Use batcher of document store for throttled events if available
+func (d *Dispatcher) getThrottledDocumentStore(dest models.Destination, documentStore interfaces.DocumentStoreV2Interface) interfaces.DocumentStoreV2Interface {
+	if batcher, ok := d.documentBatchers[dest.GetDocumentStore()]; ok {
+		return batcher
 	}
-	return nullDocumentStore
+	return documentStore
 }
 
 // inject document and sanitize fields in event based on destination
-func (d *Dispatcher) customizeEvent(e models.IncomingEvent, destination models.Destination, documentStore interfaces.DocumentStoreInterface) interfaces.IncomingEventInterface {
+func (d *Dispatcher) customizeEvent(e models.IncomingEvent, destination models.Destination, documentStore interfaces.DocumentStoreV2Interface) (interfaces.IncomingEventInterface, error) {
 	customized := e
 
-	customized.TargetDocument = d.customizeDocument(&customized, destination, documentStore)
-	customized.Payload = d.customizePayload(customized, destination)
-	return customized
+	document, err := d.customizeDocument(&customized, destination, documentStore)
+	if err != nil {
+		return customized, err
+	}
+	customized.TargetDocument = document
+	payload, err := d.customizePayload(customized, destination)
+	if err != nil {
+		return customized, err
+	}
+	customized.Payload = payload
+	return customized, nil
+}
+
+// documentPolicy - Get policy on failure of getting document for destination
+func documentPolicy(destination models.Destination, err error) string {
+	if errors.Is(err, captin_errors.ErrDocumentNotFound) {
+		return models.DocumentOptionsOf(destination.Config).GetOnDocumentNotFound()
+	}
+	return models.DocumentOptionsOf(destination.Config).GetOnDocumentError()
 }
 
 // inject throttled payloads from store if keep_throttled_payloads is true
@@ -176,6 +251,37 @@ func (d *Dispatcher) injectThrottledPayloads(e models.IncomingEvent, destination
 			json.Unmarshal([]byte(payloadStr), &payload)
 			e.ThrottledPayloads = append(e.ThrottledPayloads, payload)
 		}
+		e = mergeThrottledPayloads(e, destination)
+	}
+	return e
+}
+
+// mergeThrottledPayloads - Merge the latest throttled_payloads_limit of throttled payloads into payload
+// with throttled_payloads_merge
+func mergeThrottledPayloads(e models.IncomingEvent, destination models.Destination) models.IncomingEvent {
+	payloads := e.ThrottledPayloads
+	if len(payloads) == 0 {
+		return e
+	}
+	// stores without capped queues keep every throttled payload
+	options := models.ThrottledPayloadsOptionsOf(destination.Config)
+	if limit := options.GetThrottledPayloadsLimit(); limit > 0 && len(payloads) > limit {
+		payloads = payloads[len(payloads)-limit:]
+		e.ThrottledPayloads = payloads
+	}
+
+	switch strategy := options.GetThrottledPayloadsMerge(); strategy {
+	case models.ThrottledPayloadsMergeNone:
+	case models.ThrottledPayloadsMergeDeep:
+		e.Payload = helpers.DeepMerge(payloads...)
+	case models.ThrottledPayloadsMergeUnion:
+		e.Payload = helpers.ShallowMerge(payloads...)
+	case models.ThrottledPayloadsMergeKeepFirst:
+		e.Payload = payloads[0]
+	case models.ThrottledPayloadsMergeKeepLast:
+		e.Payload = payloads[len(payloads)-1]
+	default:
+		dLogger.WithFields(log.Fields{"strategy": strategy, "hook_name": destination.Config.GetName()}).Warn("Unknown throttled payloads merge strategy")
 	}
 	return e
 }
@@ -195,41 +301,88 @@ func (d *Dispatcher) injectThrottledDocuments(e models.IncomingEvent, destinatio
 	return e
 }
 
-func (d *Dispatcher) customizeDocument(e *models.IncomingEvent, destination models.Destination, documentStore interfaces.DocumentStoreInterface) map[string]interface{} {
+func (d *Dispatcher) customizeDocument(e *models.IncomingEvent, destination models.Destination, documentStore interfaces.DocumentStoreV2Interface) (map[string]interface{}, error) {
 	config := destination.Config
 	if config.GetIncludeDocument() == false {
-		return e.TargetDocument
+		return e.TargetDocument, nil
 	}
 
 	d.muTargetDocument.Lock()
 	defer d.muTargetDocument.Unlock()
 
-	// memoize document to be used across events for diff. destinations
+	// memoize document to be used across events for diff. destinations, and errors by document timeout,
+	// so that destinations with longer timeout do not fail with timeout of another destination
 	if d.targetDocument == nil {
-		d.targetDocument = documentStore.GetDocument(*e)
+		timeout := models.DocumentOptionsOf(config).GetDocumentTimeoutValue()
+		if err, failed := d.targetDocumentErrs[timeout]; failed {
+			return nil, err
+		}
+
+		ctx, cancel := documentContext(config)
+		defer cancel()
+		document, err := documentStore.GetDocumentContext(ctx, *e)
+		if err != nil {
+			if d.targetDocumentErrs == nil {
+				d.targetDocumentErrs = map[time.Duration]error{}
+			}
+			d.targetDocumentErrs[timeout] = err
+			return nil, err
+		}
+		d.targetDocument = document
 	}
 
-	if len(config.GetIncludeDocumentAttrs()) >= 1 {
-		return helpers.IncludeFields(d.targetDocument, config.GetIncludeDocumentAttrs()).(map[string]interface{})
-	} else if len(config.GetExcludeDocumentAttrs()) >= 1 {
-		return helpers.ExcludeFields(d.targetDocument, config.GetExcludeDocumentAttrs()).(map[string]interface{})
-	} else {
-		return d.targetDocument
+	return selectFields(*e, destination, d.targetDocument, config.GetIncludeDocumentAttrs(), config.GetExcludeDocumentAttrs())
+}
+
+func documentContext(config interfaces.ConfigurationInterface) (context.Context, context.CancelFunc) {
+	if timeout := models.DocumentOptionsOf(config).GetDocumentTimeoutValue(); timeout > 0 {
+		return context.WithTimeout(context.Background(), timeout)
 	}
+	return context.WithCancel(context.Background())
+}
+
+func (d *Dispatcher) customizePayload(e models.IncomingEvent, destination interfaces.DestinationInterface) (map[string]interface{}, error) {
+	dest := destination.(models.Destination)
+	return selectFields(e, dest, e.Payload, dest.Config.GetIncludePayloadAttrs(), dest.Config.GetExcludePayloadAttrs())
 }
 
-func (d *Dispatcher) customizePayload(e models.IncomingEvent, destination interfaces.DestinationInterface) map[string]interface{} {
-	config := destination.(models.Destination).Config
-	if len(config.GetIncludePayloadAttrs()) >= 1 {
-		return helpers.IncludeFields(e.Payload, config.GetIncludePayloadAttrs()).(map[string]interface{})
-	} else if len(config.GetExcludePayloadAttrs()) >= 1 {
-		return helpers.ExcludeFields(e.Payload, config.GetExcludePayloadAttrs()).(map[string]interface{})
+// selectFields - Include or exclude fields of object, include attrs take precedence
+//
+// Invalid selectors are returned as DispatcherError instead of sending fields which should be excluded or masked
+func selectFields(e models.IncomingEvent, destination models.Destination, object map[string]interface{}, include []string, exclude []string) (map[string]interface{}, error) {
+	var result interface{}
+	var err error
+	if len(include) >= 1 {
+		result, err = helpers.IncludeFields(object, include)
+	} else if len(exclude) >= 1 {
+		result, err = helpers.ExcludeFields(object, exclude)
+	} else {
+		return object, nil
+	}
+	if err != nil {
+		return nil, &captin_errors.DispatcherError{
+			Msg:         fmt.Sprintf("Failed to select fields, %s", err),
+			Destination: destination,
+			Event:       e,
+		}
 	}
+	return result.(map[string]interface{}), nil
+}
 
-	return e.Payload
+// dispatcherError - Error of sending event to destination as DispatcherError, without wrapping DispatcherErrors again
+func dispatcherError(err error, destination models.Destination, e models.IncomingEvent) *captin_errors.DispatcherError {
+	var dispatcherErr *captin_errors.DispatcherError
+	if errors.As(err, &dispatcherErr) {
+		return dispatcherErr
+	}
+	return &captin_errors.DispatcherError{
+		Msg:         err.Error(),
+		Destination: destination,
+		Event:       e,
+	}
 }
 
-func (d *Dispatcher) processDelayedEvent(e models.IncomingEvent, timeRemain time.Duration, dest models.Destination, store interfaces.StoreInterface, documentStore interfaces.DocumentStoreInterface) {
+func (d *Dispatcher) processDelayedEvent(e models.IncomingEvent, timeRemain time.Duration, dest models.Destination, store interfaces.StoreInterface, documentStore interfaces.DocumentStoreV2Interface) {
 	defer func() {
 		if err := recover(); err != nil {
 			d.OnError(e, &captin_errors.DispatcherError{
@@ -242,6 +395,108 @@ func (d *Dispatcher) processDelayedEvent(e models.IncomingEvent, timeRemain time
 
 	// Check if store have payload
 	dataKey := getEventDataKey(store, e, dest)
+	dataExists, storedIsNewer := checkStoredEventData(e, dataKey, store)
+	if storedIsNewer {
+		return
+	}
+
+	if err := d.storeTrailingData(e, dest, store, documentStore, dest.Config.GetThrottleValue()*2); err != nil {
+		d.OnError(e, dispatcherError(err, dest, e))
+		return
+	}
+
+	jsonString, jsonErr := e.ToJson()
+	if jsonErr != nil {
+		panic(jsonErr)
+	}
+
+	if dataExists {
+		// Update Value
+		_, updateErr := store.Update(dataKey, string(jsonString))
+		if updateErr != nil {
+			panic(updateErr)
+		}
+	} else if created, saveErr := createEventData(store, dataKey, string(jsonString), dest.Config.GetThrottleValue()*2); saveErr != nil {
+		panic(saveErr)
+	} else if !created {
+		// Created by another worker in between, which has scheduled the send
+		_, updateErr := store.Update(dataKey, string(jsonString))
+		if updateErr != nil {
+			panic(updateErr)
+		}
+	} else {
+		// Schedule send event later
+		dispatcher.TrackAfterFuncJob(timeRemain, func() {
+			d.sendStoredEvent(e, dataKey, dest, store, documentStore)
+		})
+	}
+}
+
+// processDebouncedEvent - Store event and schedule its send after debounce period without newer events,
+// events waiting longer than max_wait are sent regardless
+func (d *Dispatcher) processDebouncedEvent(e models.IncomingEvent, dest models.Destination, store interfaces.StoreInterface, documentStore interfaces.DocumentStoreV2Interface) {
+	defer func() {
+		if err := recover(); err != nil {
+			d.OnError(e, &captin_errors.DispatcherError{
+				Msg:         err.(error).Error(),
+				Destination: dest,
+				Event:       e,
+			})
+		}
+	}()
+
+	options := models.DebounceOptionsOf(dest.Config)
+	debounce := options.GetDebounceValue()
+	maxWait := options.GetMaxWaitValue()
+	ttl := (debounce + maxWait) * 2
+
+	dataKey := getEventDataKey(store, e, dest)
+	if _, storedIsNewer := checkStoredEventData(e, dataKey, store); storedIsNewer {
+		return
+	}
+
+	if err := d.storeTrailingData(e, dest, store, documentStore, ttl); err != nil {
+		d.OnError(e, dispatcherError(err, dest, e))
+		return
+	}
+
+	jsonString, jsonErr := e.ToJson()
+	if jsonErr != nil {
+		panic(jsonErr)
+	}
+	if _, saveErr := store.Set(dataKey, string(jsonString), ttl); saveErr != nil {
+		panic(saveErr)
+	}
+
+	// Keep time of first debounced event with a token of latest event, so that only the latest schedule sends
+	now := time.Now()
+	debounceKey := getEventDebounceKey(store, e, dest)
+	token, firstAt, saveErr := setDebounceToken(store, debounceKey, now, ttl)
+	if saveErr != nil {
+		panic(saveErr)
+	}
+
+	delay := debounce
+	if maxWait > 0 {
+		if remain := firstAt.Add(maxWait).Sub(now); remain < delay {
+			delay = remain
+		}
+	}
+	if delay < 0 {
+		delay = 0
+	}
+
+	dispatcher.TrackAfterFuncJob(delay, func() {
+		if !claimDebounceToken(store, debounceKey, token) {
+			dLogger.WithFields(log.Fields{"key": dataKey}).Debug("Debounced by newer event")
+			return
+		}
+		d.sendStoredEvent(e, dataKey, dest, store, documentStore)
+	})
+}
+
+// checkStoredEventData - Check if event data exists and if it is newer than event, which should not be updated then
+func checkStoredEventData(e models.IncomingEvent, dataKey string, store interfaces.StoreInterface) (bool, bool) {
 	storedData, dataExists, _, storeErr := store.Get(dataKey)
 	if storeErr != nil {
 		panic(storeErr)
@@ -260,11 +515,18 @@ func (d *Dispatcher) processDelayedEvent(e models.IncomingEvent, timeRemain time
 			"event":        e,
 			"eventDataKey": "dataKey",
 		}).Debug("Skipping update on event data")
-		return
+		return true, true
 	}
+	return dataExists, false
+}
 
+// storeTrailingData - Accumulate payload and document of event for the trailing send
+func (d *Dispatcher) storeTrailingData(e models.IncomingEvent, dest models.Destination, store interfaces.StoreInterface, documentStore interfaces.DocumentStoreV2Interface, ttl time.Duration) error {
 	if dest.Config.GetKeepThrottledPayloads() {
-		customizedPayload := d.customizePayload(e, dest)
+		customizedPayload, err := d.customizePayload(e, dest)
+		if err != nil {
+			return err
+		}
 		queueKey := getEventThrottledPayloadsKey(store, e, dest)
 		jsonString, jsonErr := json.Marshal(customizedPayload)
 		if jsonErr != nil {
@@ -275,59 +537,142 @@ func (d *Dispatcher) processDelayedEvent(e models.IncomingEvent, timeRemain time
 			"event":          e,
 			"enqueuePayload": jsonString,
 		}).Debug("Storing throttled payload")
-		store.Enqueue(queueKey, string(jsonString), dest.Config.GetThrottleValue()*2)
+		enqueue(store, queueKey, string(jsonString), ttl, models.ThrottledPayloadsOptionsOf(dest.Config).GetThrottledPayloadsLimit())
 	}
 
 	if dest.Config.GetIncludeDocument() && dest.Config.GetKeepThrottledDocuments() {
-		customizedDocument := d.customizeDocument(&e, dest, documentStore)
-		queueKey := getEventThrottledDocumentsKey(store, e, dest)
-		jsonString, jsonErr := json.Marshal(customizedDocument)
-		if jsonErr != nil {
-			panic(jsonErr)
-		}
-		dLogger.WithFields(log.Fields{
-			"queueKey":        queueKey,
-			"event":           e,
-			"enqueueDocument": jsonString,
-		}).Debug("Storing throttled document")
-		store.Enqueue(queueKey, string(jsonString), dest.Config.GetThrottleValue()*2)
+		return d.storeThrottledDocument(e, dest, store, documentStore, ttl)
 	}
+	return nil
+}
 
-	jsonString, jsonErr := e.ToJson()
-	if jsonErr != nil {
-		panic(jsonErr)
+// sendStoredEvent - Send the latest event stored in dataKey and remove it
+func (d *Dispatcher) sendStoredEvent(e models.IncomingEvent, dataKey string, dest models.Destination, store interfaces.StoreInterface, documentStore interfaces.DocumentStoreV2Interface) {
+	dLogger.WithFields(log.Fields{"key": dataKey}).Debug("After event callback")
+	payload, exists, _, _ := store.Get(dataKey)
+	// Key might be deleted by another worker, resulting in data not found
+	if !exists {
+		dLogger.WithFields(log.Fields{"key": dataKey}).Debug("Event data not found")
+		d.OnError(models.IncomingEvent{}, &captin_errors.UnretryableError{Msg: "Event data not found", Event: e, Destination: dest})
+		return
 	}
+	event := models.IncomingEvent{}
+	json.Unmarshal([]byte(payload), &event)
+	d.sendEvent(event, dest, store, documentStore)
+	store.Remove(dataKey)
+}
 
-	if dataExists {
-		// Update Value
-		_, updateErr := store.Update(dataKey, string(jsonString))
-		if updateErr != nil {
-			panic(updateErr)
+// parseDebounceToken - Get time of first debounced event from token
+func parseDebounceToken(token string) (time.Time, bool) {
+	parts := strings.SplitN(token, ":", 2)
+	if len(parts) != 2 {
+		return time.Time{}, false
+	}
+	nanos, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, nanos), true
+}
+
+// setDebounceToken - Replace debounce token with token of event at now, keeping time of first debounced event
+//
+// Stores implementing AtomicStoreInterface replace the token with compare-and-swap, so that replicas debouncing
+// the same key never both keep the time of the first event they read, retrying with the token of each other.
+func setDebounceToken(store interfaces.StoreInterface, debounceKey string, now time.Time, ttl time.Duration) (string, time.Time, error) {
+	atomicStore, isAtomic := store.(interfaces.AtomicStoreInterface)
+	for attempt := 0; attempt < maxDebounceAttempts; attempt++ {
+		stored, exists, _, err := store.Get(debounceKey)
+		if err != nil {
+			return "", now, err
 		}
-	} else {
-		// Create Value
-		config := dest.Config
-		_, saveErr := store.Set(dataKey, string(jsonString), config.GetThrottleValue()*2)
-		if saveErr != nil {
-			panic(saveErr)
+		firstAt := now
+		if exists {
+			if startedAt, ok := parseDebounceToken(stored); ok {
+				firstAt = startedAt
+			}
+		}
+		token := fmt.Sprintf("%s:%d", uuid.New().String(), firstAt.UnixNano())
+
+		var saved bool
+		switch {
+		case !isAtomic:
+			saved, err = store.Set(debounceKey, token, ttl)
+		case exists:
+			saved, err = atomicStore.CompareAndSwap(debounceKey, stored, token, ttl)
+		default:
+			saved, err = atomicStore.SetNX(debounceKey, token, ttl)
+		}
+		if err != nil {
+			return "", now, err
 		}
+		// token is replaced by another event, retry with its time of first event
+		if saved {
+			return token, firstAt, nil
+		}
+	}
+	return "", now, fmt.Errorf("debounce token %s is contended", debounceKey)
+}
 
-		// Schedule send event later
-		dispatcher.TrackAfterFuncJob(timeRemain, func() {
-			dLogger.WithFields(log.Fields{"key": dataKey}).Debug("After event callback")
-			payload, exists, _, _ := store.Get(dataKey)
-			// Key might be deleted by another worker, resulting in data not found
-			if !exists {
-				dLogger.WithFields(log.Fields{"key": dataKey}).Debug("Event data not found")
-				d.OnError(models.IncomingEvent{}, &captin_errors.UnretryableError{Msg: "Event data not found", Event: e, Destination: dest})
-				return
-			}
-			event := models.IncomingEvent{}
-			json.Unmarshal([]byte(payload), &event)
-			d.sendEvent(event, dest, store, documentStore)
-			store.Remove(dataKey)
-		})
+// claimDebounceToken - Remove debounce token if it is still the given one, return false if replaced by newer event
+func claimDebounceToken(store interfaces.StoreInterface, debounceKey string, token string) bool {
+	if atomicStore, ok := store.(interfaces.AtomicStoreInterface); ok {
+		claimed, err := atomicStore.CompareAndSwap(debounceKey, token, "", time.Millisecond)
+		if err != nil || !claimed {
+			return false
+		}
+	} else if current, exists, _, _ := store.Get(debounceKey); !exists || current != token {
+		return false
 	}
+	store.Remove(debounceKey)
+	return true
+}
+
+// createEventData - Create event data for trailing send, return false if it exists already
+func createEventData(store interfaces.StoreInterface, dataKey string, value string, ttl time.Duration) (bool, error) {
+	if atomicStore, ok := store.(interfaces.AtomicStoreInterface); ok {
+		return atomicStore.SetNX(dataKey, value, ttl)
+	}
+	return store.Set(dataKey, value, ttl)
+}
+
+func (d *Dispatcher) storeThrottledDocument(e models.IncomingEvent, dest models.Destination, store interfaces.StoreInterface, documentStore interfaces.DocumentStoreV2Interface, ttl time.Duration) error {
+	customizedDocument, err := d.customizeDocument(&e, dest, documentStore)
+	var dispatcherErr *captin_errors.DispatcherError
+	if errors.As(err, &dispatcherErr) {
+		return dispatcherErr
+	} else if err != nil {
+		switch documentPolicy(dest, err) {
+		case models.DocumentPolicySkip:
+			dLogger.WithFields(log.Fields{"event": e, "error": err}).Info("Skip storing throttled document")
+			return nil
+		case models.DocumentPolicySend:
+			customizedDocument = map[string]interface{}{}
+		default:
+			return err
+		}
+	}
+
+	queueKey := getEventThrottledDocumentsKey(store, e, dest)
+	jsonString, jsonErr := json.Marshal(customizedDocument)
+	if jsonErr != nil {
+		panic(jsonErr)
+	}
+	dLogger.WithFields(log.Fields{
+		"queueKey":        queueKey,
+		"event":           e,
+		"enqueueDocument": jsonString,
+	}).Debug("Storing throttled document")
+	store.Enqueue(queueKey, string(jsonString), ttl)
+	return nil
+}
+
+// enqueue - Enqueue value capped to the latest limit values by stores implementing BoundedQueueStoreInterface
+func enqueue(store interfaces.StoreInterface, key string, value string, ttl time.Duration, limit int) (bool, error) {
+	if bounded, ok := store.(interfaces.BoundedQueueStoreInterface); ok && limit > 0 {
+		return bounded.EnqueueWithLimit(key, value, ttl, limit)
+	}
+	return store.Enqueue(key, value, ttl)
 }
 
 func getControlTimestamp(e models.IncomingEvent, defaultValue uint64) uint64 {
@@ -355,11 +700,12 @@ func getControlTimestamp(e models.IncomingEvent, defaultValue uint64) uint64 {
 	return value.(uint64)
 }
 
-func getEventKey(s interfaces.StoreInterface, e interfaces.IncomingEventInterface, d interfaces.DestinationInterface) string {
+// getEventKey - Key of event in destination, composed by DataKey of store
+func getEventKey(s interfaces.StoreInterface, e models.IncomingEvent, d models.Destination) string {
 	return s.DataKey(e, d, "", "")
 }
 
-func getEventDataKey(s interfaces.StoreInterface, e interfaces.IncomingEventInterface, d interfaces.DestinationInterface) string {
+func getEventDataKey(s interfaces.StoreInterface, e models.IncomingEvent, d models.Destination) string {
 	return s.DataKey(e, d, "", "-data")
 }
 
@@ -371,19 +717,53 @@ func getEventThrottledDocumentsKey(s interfaces.StoreInterface, e models.Incomin
 	return s.DataKey(e, d, "", "-throttled_documents")
 }
 
-func (d *Dispatcher) sendEvent(evt models.IncomingEvent, destination models.Destination, store interfaces.StoreInterface, documentStore interfaces.DocumentStoreInterface) {
+func getEventDebounceKey(s interfaces.StoreInterface, e models.IncomingEvent, d models.Destination) string {
+	return s.DataKey(e, d, "", "-debounce")
+}
+
+// guard payload received by destination with the schema given in payload_schema
+func (d *Dispatcher) validatePayloadSchema(evt models.IncomingEvent, destination models.Destination) {
+	schemaName := models.PayloadSchemaOf(destination.Config)
+	if schemaName == "" {
+		return
+	}
+
+	if d.schemaRegistry == nil || d.schemaRegistry.Has(schemaName) == false {
+		panic(&captin_errors.DispatcherError{
+			Msg:         fmt.Sprintf("Payload schema %s does not exist", schemaName),
+			Destination: destination,
+			Event:       evt,
+		})
+	}
+
+	violations, err := d.schemaRegistry.Validate(schemaName, evt.Payload)
+	if err != nil {
+		panic(err)
+	}
+	if len(violations) > 0 {
+		panic(&captin_errors.DispatcherError{
+			Msg:         fmt.Sprintf("Payload does not match schema %s (%s)", schemaName, strings.Join(violations, "; ")),
+			Destination: destination,
+			Event:       evt,
+		})
+	}
+}
+
+func (d *Dispatcher) sendEvent(evt models.IncomingEvent, destination models.Destination, store interfaces.StoreInterface, documentStore interfaces.DocumentStoreV2Interface) {
 	config := destination.Config
+	// Callback url may contain secrets of configuration
+	callbackURL := destination.Redacted().GetCallbackURL()
 	callbackLogger := dLogger.WithFields(log.Fields{
 		"action":         evt.Key,
 		"event":          evt,
 		"hook_name":      config.GetName(),
-		"callback_url":   destination.GetCallbackURL(),
+		"callback_url":   callbackURL,
 		"document_store": destination.GetDocumentStore(),
 	})
 
 	defer func() {
 		if err := recover(); err != nil {
-			callbackLogger.Info(fmt.Sprintf("Event failed sending to %s [%s]", config.GetName(), destination.GetCallbackURL()))
+			callbackLogger.Info(fmt.Sprintf("Event failed sending to %s [%s]", config.GetName(), callbackURL))
 			d.OnError(evt, &captin_errors.DispatcherError{
 				Msg:         err.(error).Error(),
 				Destination: destination,
@@ -395,12 +775,44 @@ func (d *Dispatcher) sendEvent(evt models.IncomingEvent, destination models.Dest
 
 	callbackLogger.Debug("Preprocess payload and document")
 
-	evt = d.customizeEvent(evt, destination, documentStore).(models.IncomingEvent)
+	customized, documentErr := d.customizeEvent(evt, destination, documentStore)
+	// Errors of selecting fields are not failures of document store
+	var dispatcherErr *captin_errors.DispatcherError
+	if errors.As(documentErr, &dispatcherErr) {
+		d.OnError(evt, dispatcherErr)
+		return
+	} else if documentErr != nil {
+		switch documentPolicy(destination, documentErr) {
+		case models.DocumentPolicySkip:
+			callbackLogger.WithFields(log.Fields{"error": documentErr}).Info("Event skipped as document is unavailable")
+			return
+		case models.DocumentPolicySend:
+			callbackLogger.WithFields(log.Fields{"error": documentErr}).Debug("Send event with empty document")
+			evt.TargetDocument = map[string]interface{}{}
+			payload, payloadErr := d.customizePayload(evt, destination)
+			if payloadErr != nil {
+				d.OnError(evt, dispatcherError(payloadErr, destination, evt))
+				return
+			}
+			evt.Payload = payload
+			customized = evt
+		default:
+			d.OnError(evt, &captin_errors.DispatcherError{
+				Msg:         fmt.Sprintf("Failed to get document, %s", documentErr),
+				Destination: destination,
+				Event:       evt,
+			})
+			return
+		}
+	}
+	evt = customized.(models.IncomingEvent)
 
 	evt = d.injectThrottledPayloads(evt, destination, store).(models.IncomingEvent)
 
 	evt = d.injectThrottledDocuments(evt, destination, store).(models.IncomingEvent)
 
+	d.validatePayloadSchema(evt, destination)
+
 	callbackLogger.Debug("Final sift on dispatcher")
 
 	sifted := Custom{}.Sift(&evt, []models.Destination{destination}, d.filters, d.middlewares)
@@ -449,7 +861,7 @@ func (d *Dispatcher) sendEvent(evt models.IncomingEvent, destination models.Dest
 		if err != nil {
 			panic(err)
 		}
-		callbackLogger.Info(fmt.Sprintf("Event successfully sent to %s [%s]", config.GetName(), destination.GetCallbackURL()))
+		callbackLogger.Info(fmt.Sprintf("Event successfully sent to %s [%s]", config.GetName(), callbackURL))
 	}
 
 	if destination.RequireDelay(evt) {
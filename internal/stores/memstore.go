@@ -1,7 +1,10 @@
 package stores
 
 import (
+	"container/heap"
 	"fmt"
+	"hash/fnv"
+	"strconv"
 	"sync"
 	"time"
 
@@ -12,129 +15,354 @@ import (
 
 var mLogger = log.WithFields(log.Fields{"class": "MemoryStore"})
 
+const (
+	shardCount = 32
+	// DefaultSweepInterval - Interval of removing expired keys of MemoryStore in background
+	DefaultSweepInterval = 100 * time.Millisecond
+)
+
 type item struct {
-	value      interface{}
-	createDate time.Time
-	ttl        time.Duration
+	key   string
+	value interface{}
+	// expiresAt - zero for keys without expiry
+	expiresAt time.Time
+	// index - Position in expiry heap, -1 for keys without expiry
+	index int
+}
+
+func (it *item) expired(now time.Time) bool {
+	return !it.expiresAt.IsZero() && !now.Before(it.expiresAt)
+}
+
+func (it *item) remaining(now time.Time) time.Duration {
+	if it.expiresAt.IsZero() {
+		return time.Duration(0)
+	}
+	return it.expiresAt.Sub(now)
+}
+
+// expiryHeap - Items with expiry by the earliest, with one entry per key
+type expiryHeap []*item
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].expiresAt.Before(h[j].expiresAt) }
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *expiryHeap) Push(x interface{}) {
+	it := x.(*item)
+	it.index = len(*h)
+	*h = append(*h, it)
+}
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	last := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	last.index = -1
+	return last
+}
+
+type shard struct {
+	lock     sync.Mutex
+	m        map[string]*item
+	expiries expiryHeap
+}
+
+// get - Get item which is not expired, caller must hold lock
+func (s *shard) get(key string, now time.Time) (*item, bool) {
+	it, ok := s.m[key]
+	if !ok {
+		return nil, false
+	}
+	if it.expired(now) {
+		s.delete(key)
+		return nil, false
+	}
+	return it, true
+}
+
+// set - Set value of key with ttl, no expiry if ttl is not positive, caller must hold lock
+//
+// Item of existing key is updated in place, so that its expiry is moved instead of added to heap
+func (s *shard) set(key string, value interface{}, ttl time.Duration, now time.Time) *item {
+	it, ok := s.m[key]
+	if !ok {
+		it = &item{key: key, index: -1}
+		s.m[key] = it
+	}
+	it.value = value
+	it.expiresAt = time.Time{}
+	if ttl > 0 {
+		it.expiresAt = now.Add(ttl)
+	}
+
+	switch {
+	case ttl > 0 && it.index >= 0:
+		heap.Fix(&s.expiries, it.index)
+	case ttl > 0:
+		heap.Push(&s.expiries, it)
+	case it.index >= 0:
+		heap.Remove(&s.expiries, it.index)
+	}
+	return it
+}
+
+// delete - Remove key and its expiry, caller must hold lock
+func (s *shard) delete(key string) {
+	it, ok := s.m[key]
+	if !ok {
+		return
+	}
+	if it.index >= 0 {
+		heap.Remove(&s.expiries, it.index)
+	}
+	delete(s.m, key)
+}
+
+// removeExpired - Remove keys expired by now from the earliest expiry
+func (s *shard) removeExpired(now time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for len(s.expiries) > 0 && s.expiries[0].expired(now) {
+		it := heap.Pop(&s.expiries).(*item)
+		delete(s.m, it.key)
+	}
 }
 
 // MemoryStore - In-app memory storage
+//
+// Keys are sharded by hash with a lock per shard. Expired keys are never returned and are removed
+// by a sweeper in order of expiry until Close.
 type MemoryStore struct {
 	interfaces.StoreInterface
-	m    map[string]*item
-	lock sync.Mutex
+	interfaces.AtomicStoreInterface
+	shards    [shardCount]*shard
+	now       func() time.Time
+	stop      chan struct{}
+	closeOnce sync.Once
 }
 
-// NewMemoryStore - Create new MemoryStore
+// NewMemoryStore - Create new MemoryStore removing expired keys every DefaultSweepInterval
 func NewMemoryStore() *MemoryStore {
-	m := &MemoryStore{
-		m: make(map[string]*item),
+	return NewMemoryStoreWithSweepInterval(DefaultSweepInterval)
+}
+
+// NewMemoryStoreWithSweepInterval - Create new MemoryStore removing expired keys every interval,
+// or only with Sweep if interval is not positive
+func NewMemoryStoreWithSweepInterval(interval time.Duration) *MemoryStore {
+	ms := &MemoryStore{now: time.Now, stop: make(chan struct{})}
+	for i := range ms.shards {
+		ms.shards[i] = &shard{m: map[string]*item{}}
+	}
+	if interval <= 0 {
+		return ms
 	}
 
 	go func() {
-		for range time.Tick(100 * time.Millisecond) {
-			m.lock.Lock()
-			for k, v := range m.m {
-				duration := time.Since(v.createDate)
-				if duration > v.ttl {
-					delete(m.m, k)
-				}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ms.stop:
+				return
+			case <-ticker.C:
+				ms.Sweep()
 			}
-			m.lock.Unlock()
 		}
 	}()
 
-	return m
+	return ms
+}
+
+// SetClock - Set current time of store, e.g. to expire keys without waiting in tests
+func (ms *MemoryStore) SetClock(now func() time.Time) {
+	ms.now = now
+}
+
+// Sweep - Remove keys expired by now
+func (ms *MemoryStore) Sweep() {
+	now := ms.now()
+	for _, s := range ms.shards {
+		s.removeExpired(now)
+	}
+}
+
+func (ms *MemoryStore) shard(key string) *shard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return ms.shards[h.Sum32()%shardCount]
 }
 
 // Get - Get value from store, return with remaining time
 func (ms *MemoryStore) Get(key string) (string, bool, time.Duration, error) {
-	ms.lock.Lock()
-	defer ms.lock.Unlock()
-
 	mLogger.WithFields(log.Fields{"key": key}).Debug("Get key")
-	if it, ok := ms.m[key]; ok {
-		return it.value.(string), true, time.Since(it.createDate), nil
+
+	s := ms.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := ms.now()
+	it, ok := s.get(key, now)
+	if !ok {
+		return "", false, 0, nil
 	}
-	return "", false, 0, nil
+	value, _ := it.value.(string)
+	return value, true, it.remaining(now), nil
 }
 
-// Set - Set value into store with ttl
+// Set - Set value into store with ttl, no expiry if ttl is not positive
 func (ms *MemoryStore) Set(key string, value string, ttl time.Duration) (bool, error) {
-	ms.lock.Lock()
-	it, ok := ms.m[key]
+	s := ms.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.set(key, value, ttl, ms.now())
+	return true, nil
+}
+
+// SetNX - Set value into store with ttl only if key does not exist
+func (ms *MemoryStore) SetNX(key string, value string, ttl time.Duration) (bool, error) {
+	s := ms.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := ms.now()
+	if _, ok := s.get(key, now); ok {
+		return false, nil
+	}
+	s.set(key, value, ttl, now)
+	return true, nil
+}
+
+// Incr - Increase integer value for key by delta, key is created with ttl if not exist
+func (ms *MemoryStore) Incr(key string, delta int64, ttl time.Duration) (int64, error) {
+	s := ms.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := ms.now()
+	it, ok := s.get(key, now)
 	if !ok {
-		it = &item{value: value}
-		ms.m[key] = it
+		it = s.set(key, "0", ttl, now)
+	}
+	str, isString := it.value.(string)
+	if !isString {
+		return 0, fmt.Errorf("MemoryStore: value of %s is not an integer", key)
+	}
+	current, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("MemoryStore: value of %s is not an integer", key)
+	}
+	current += delta
+	it.value = strconv.FormatInt(current, 10)
+	return current, nil
+}
+
+// CompareAndSwap - Set value into store with ttl only if current value equals old
+func (ms *MemoryStore) CompareAndSwap(key string, old string, value string, ttl time.Duration) (bool, error) {
+	s := ms.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := ms.now()
+	it, ok := s.get(key, now)
+	if !ok || it.value != old {
+		return false, nil
 	}
-	it.createDate = time.Now()
-	it.ttl = ttl
-	ms.lock.Unlock()
+	s.set(key, value, ttl, now)
 	return true, nil
 }
 
-// Update - Update value into store
+// Update - Update value into store, keeping its ttl
 func (ms *MemoryStore) Update(key string, value string) (bool, error) {
-	ms.lock.Lock()
-	defer ms.lock.Unlock()
+	s := ms.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
 
-	it, ok := ms.m[key]
+	it, ok := s.get(key, ms.now())
 	if !ok {
 		return false, nil
 	}
 	it.value = value
-	ms.m[key] = it
 	return true, nil
 }
 
 // Remove - Remove value in store
 func (ms *MemoryStore) Remove(key string) (bool, error) {
-	ms.lock.Lock()
-	delete(ms.m, key)
-	ms.lock.Unlock()
+	s := ms.shard(key)
+	s.lock.Lock()
+	s.delete(key)
+	s.lock.Unlock()
 	return true, nil
 }
 
 // Enqueue - ttl: optional params for setting the ttl of queue when first element is enqueued
 func (ms *MemoryStore) Enqueue(key string, value string, ttl time.Duration) (bool, error) {
-	ms.lock.Lock()
-	_, ok := ms.m[key]
+	return ms.EnqueueWithLimit(key, value, ttl, 0)
+}
+
+// EnqueueWithLimit - Append value to queue and keep only the latest limit values, unlimited if limit is not positive
+func (ms *MemoryStore) EnqueueWithLimit(key string, value string, ttl time.Duration, limit int) (bool, error) {
+	s := ms.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := ms.now()
+	it, ok := s.get(key, now)
 	if !ok {
-		ms.m[key] = &item{
-			value:      []string{},
-			createDate: time.Now(),
-			ttl:        ttl,
-		}
+		it = s.set(key, []string{}, ttl, now)
 	}
-	it := ms.m[key]
-
-	it.value = append(it.value.([]string), value)
-	ms.lock.Unlock()
+	queue, _ := it.value.([]string)
+	queue = append(queue, value)
+	if limit > 0 && len(queue) > limit {
+		// copy, so that dropped values are not kept by the underlying array
+		queue = append([]string{}, queue[len(queue)-limit:]...)
+	}
+	it.value = queue
 	return true, nil
 }
 
+// GetQueue - Get values of queue, return with remaining time
 func (ms *MemoryStore) GetQueue(key string) ([]string, bool, time.Duration, error) {
-	ms.lock.Lock()
-	defer ms.lock.Unlock()
-
 	mLogger.WithFields(log.Fields{"key": key}).Debug("Get key")
-	if it, ok := ms.m[key]; ok {
-		return it.value.([]string), true, time.Since(it.createDate), nil
+
+	s := ms.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := ms.now()
+	it, ok := s.get(key, now)
+	if !ok {
+		return []string{}, false, 0, nil
 	}
-	return []string{}, false, 0, nil
+	queue, _ := it.value.([]string)
+	return append([]string{}, queue...), true, it.remaining(now), nil
 }
 
-// Len - Get memory size
+// Len - Get memory size, including expired keys not yet removed
 func (ms *MemoryStore) Len() int {
-	ms.lock.Lock()
-	defer ms.lock.Unlock()
+	count := 0
+	for _, s := range ms.shards {
+		s.lock.Lock()
+		count += len(s.m)
+		s.lock.Unlock()
+	}
+	return count
+}
 
-	return len(ms.m)
+// Close - Stop removing expired keys in background
+func (ms *MemoryStore) Close() error {
+	ms.closeOnce.Do(func() {
+		close(ms.stop)
+	})
+	return nil
 }
 
 // DataKey - Generate DataKey with events and destination
 func (ms *MemoryStore) DataKey(ev interfaces.IncomingEventInterface, dest interfaces.DestinationInterface, prefix string, suffix string) string {
-	e := ev.(models.IncomingEvent)
-	config := dest.(models.Destination).Config
-	return fmt.Sprintf("%s%s.%s.%s%s", prefix, e.Key, config.GetName(), e.TargetId, suffix)
+	return models.DataKey(ev, dest, prefix, suffix)
 }
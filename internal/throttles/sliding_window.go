@@ -0,0 +1,113 @@
+package throttles
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	interfaces "github.com/shoplineapp/captin/interfaces"
+	log "github.com/sirupsen/logrus"
+)
+
+var swLogger = log.WithFields(log.Fields{"class": "SlidingWindowThrottler"})
+
+// SlidingWindowThrottler - Event Throttler allowing Limit events in any period.
+// Events are counted per fixed window in store, count of sliding window is estimated from
+// the current window and the weighted count of previous window.
+type SlidingWindowThrottler struct {
+	interfaces.ThrottleInterface
+	store interfaces.StoreInterface
+	Limit int
+}
+
+// NewSlidingWindowThrottler - Create new SlidingWindowThrottler
+func NewSlidingWindowThrottler(store interfaces.StoreInterface, limit int) *SlidingWindowThrottler {
+	return &SlidingWindowThrottler{
+		store: store,
+		Limit: limit,
+	}
+}
+
+// CanTrigger - Check if can trigger with Limit of throttler
+func (t *SlidingWindowThrottler) CanTrigger(id string, period time.Duration) (bool, time.Duration, error) {
+	return t.CanTriggerWithLimit(id, period, t.Limit)
+}
+
+// CanTriggerWithLimit - Count event if less than limit events in period, return with time until count drops otherwise
+func (t *SlidingWindowThrottler) CanTriggerWithLimit(id string, period time.Duration, limit int) (bool, time.Duration, error) {
+	// ignore throttle if no period is given
+	if period == time.Duration(0) {
+		return true, time.Duration(0), nil
+	}
+	if limit <= 0 {
+		limit = 1
+	}
+
+	now := time.Now().UnixNano()
+	window := now / int64(period)
+	offset := time.Duration(now % int64(period))
+
+	current, err := t.count(windowKey(id, window))
+	if err != nil {
+		return true, time.Duration(0), err
+	}
+	previous, err := t.count(windowKey(id, window-1))
+	if err != nil {
+		return true, time.Duration(0), err
+	}
+
+	weight := 1 - float64(offset)/float64(period)
+	estimated := float64(previous)*weight + float64(current)
+	swLogger.WithFields(log.Fields{"id": id, "estimated": estimated, "limit": limit}).Debug("Check sliding window on CanTrigger")
+
+	if estimated < float64(limit) {
+		counted, err := t.increase(windowKey(id, window), current, float64(previous)*weight, limit, period*2)
+		if err != nil || counted {
+			return true, time.Duration(0), err
+		}
+		current = limit
+	}
+
+	// estimated count drops below limit within current window as previous window slides out
+	remain := period - offset
+	if previous > 0 && current < limit {
+		drop := time.Duration(float64(period)*(1-float64(limit-current)/float64(previous))) - offset
+		if drop > 0 && drop < remain {
+			remain = drop
+		}
+	}
+	return false, remain, nil
+}
+
+// increase - Count event in current window, return false if limit is reached by other callers in between
+func (t *SlidingWindowThrottler) increase(key string, current int, weighted float64, limit int, ttl time.Duration) (bool, error) {
+	atomicStore, ok := t.store.(interfaces.AtomicStoreInterface)
+	if !ok {
+		_, err := t.store.Set(key, strconv.Itoa(current+1), ttl)
+		return true, err
+	}
+
+	count, err := atomicStore.Incr(key, 1, ttl)
+	if err != nil {
+		return false, err
+	}
+	if weighted+float64(count-1) < float64(limit) {
+		return true, nil
+	}
+	// roll back as the event is not sent
+	_, err = atomicStore.Incr(key, -1, ttl)
+	return false, err
+}
+
+func (t *SlidingWindowThrottler) count(key string) (int, error) {
+	val, ok, _, err := t.store.Get(key)
+	if err != nil || !ok {
+		return 0, err
+	}
+	count, _ := strconv.Atoi(val)
+	return count, nil
+}
+
+func windowKey(id string, window int64) string {
+	return fmt.Sprintf("%s-%d", id, window)
+}
@@ -0,0 +1,50 @@
+package throttles
+
+import (
+	"time"
+
+	interfaces "github.com/shoplineapp/captin/interfaces"
+	models "github.com/shoplineapp/captin/models"
+	log "github.com/sirupsen/logrus"
+)
+
+var stLogger = log.WithFields(log.Fields{"class": "StrategyThrottler"})
+
+// StrategyThrottler - Event Throttler with throttle_strategy, throttle_limit and throttle_burst of destination
+type StrategyThrottler struct {
+	interfaces.ThrottleInterface
+	interfaces.DestinationThrottleInterface
+	period        *Throttler
+	tokenBucket   *TokenBucketThrottler
+	slidingWindow *SlidingWindowThrottler
+}
+
+// NewStrategyThrottler - Create new StrategyThrottler
+func NewStrategyThrottler(store interfaces.StoreInterface) *StrategyThrottler {
+	return &StrategyThrottler{
+		period:        NewThrottler(store),
+		tokenBucket:   NewTokenBucketThrottler(store, 1, 1),
+		slidingWindow: NewSlidingWindowThrottler(store, 1),
+	}
+}
+
+// CanTrigger - Check if can trigger with default strategy
+func (t *StrategyThrottler) CanTrigger(id string, period time.Duration) (bool, time.Duration, error) {
+	return t.period.CanTrigger(id, period)
+}
+
+// CanTriggerDestination - Check if can trigger with throttle strategy of destination
+func (t *StrategyThrottler) CanTriggerDestination(id string, dest interfaces.DestinationInterface) (bool, time.Duration, error) {
+	config := dest.GetConfig()
+	options := models.ThrottleStrategyOptionsOf(config)
+	switch options.GetThrottleStrategy() {
+	case models.ThrottleStrategyTokenBucket:
+		return t.tokenBucket.CanTriggerWithLimit(id, config.GetThrottleValue(), options.GetThrottleLimit(), options.GetThrottleBurst())
+	case models.ThrottleStrategySlidingWindow:
+		return t.slidingWindow.CanTriggerWithLimit(id, config.GetThrottleValue(), options.GetThrottleLimit())
+	case models.ThrottleStrategyPeriod:
+	default:
+		stLogger.WithFields(log.Fields{"hook": config.GetName(), "strategy": options.GetThrottleStrategy()}).Warn("Unknown throttle strategy, fallback to period")
+	}
+	return t.period.CanTrigger(id, config.GetThrottleValue())
+}
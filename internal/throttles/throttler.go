@@ -29,6 +29,10 @@ func (t *Throttler) CanTrigger(id string, period time.Duration) (bool, time.Dura
 		return true, time.Duration(0), nil
 	}
 
+	if atomicStore, ok := t.store.(interfaces.AtomicStoreInterface); ok {
+		return t.canTriggerAtomic(atomicStore, id, period)
+	}
+
 	val, ok, duration, err := t.store.Get(id)
 
 	if err != nil {
@@ -43,3 +47,21 @@ func (t *Throttler) CanTrigger(id string, period time.Duration) (bool, time.Dura
 
 	return false, duration, nil
 }
+
+// canTriggerAtomic - Only the first caller setting the key can trigger
+func (t *Throttler) canTriggerAtomic(store interfaces.AtomicStoreInterface, id string, period time.Duration) (bool, time.Duration, error) {
+	created, err := store.SetNX(id, "1", period)
+	if err != nil {
+		return true, time.Duration(0), err
+	}
+	if created {
+		tLogger.WithFields(log.Fields{"period": period}).Debug("Throttle value not set, created")
+		return true, time.Duration(0), nil
+	}
+
+	_, _, duration, err := t.store.Get(id)
+	if err != nil {
+		return true, time.Duration(0), err
+	}
+	return false, duration, nil
+}
@@ -0,0 +1,125 @@
+package throttles
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	interfaces "github.com/shoplineapp/captin/interfaces"
+	log "github.com/sirupsen/logrus"
+)
+
+var tbLogger = log.WithFields(log.Fields{"class": "TokenBucketThrottler"})
+
+// maxBucketAttempts - Attempts of compare-and-swap on bucket before rejecting the event
+const maxBucketAttempts = 10
+
+// TokenBucketThrottler - Event Throttler refilling Limit tokens per period, up to Burst tokens.
+// Bucket is stored as "<tokens>:<last refill in unix nano>" in store, so that it is shared across replicas.
+type TokenBucketThrottler struct {
+	interfaces.ThrottleInterface
+	store interfaces.StoreInterface
+	Limit int
+	Burst int
+}
+
+// NewTokenBucketThrottler - Create new TokenBucketThrottler
+func NewTokenBucketThrottler(store interfaces.StoreInterface, limit int, burst int) *TokenBucketThrottler {
+	return &TokenBucketThrottler{
+		store: store,
+		Limit: limit,
+		Burst: burst,
+	}
+}
+
+// CanTrigger - Check if can trigger with Limit and Burst of throttler
+func (t *TokenBucketThrottler) CanTrigger(id string, period time.Duration) (bool, time.Duration, error) {
+	return t.CanTriggerWithLimit(id, period, t.Limit, t.Burst)
+}
+
+// CanTriggerWithLimit - Take a token from bucket, return with time until next token when bucket is empty
+func (t *TokenBucketThrottler) CanTriggerWithLimit(id string, period time.Duration, limit int, burst int) (bool, time.Duration, error) {
+	// ignore throttle if no period is given
+	if period == time.Duration(0) {
+		return true, time.Duration(0), nil
+	}
+	if limit <= 0 {
+		limit = 1
+	}
+	if burst <= 0 {
+		burst = limit
+	}
+
+	interval := period / time.Duration(limit)
+	// bucket is full again after ttl, which is the same as not stored
+	ttl := interval * time.Duration(burst)
+	atomicStore, isAtomic := t.store.(interfaces.AtomicStoreInterface)
+
+	for attempt := 0; attempt < maxBucketAttempts; attempt++ {
+		now := time.Now()
+		val, ok, _, err := t.store.Get(id)
+		if err != nil {
+			return true, time.Duration(0), err
+		}
+
+		tokens := float64(burst)
+		if ok {
+			if stored, last, parsed := parseBucket(val); parsed {
+				tokens = math.Min(float64(burst), stored+float64(now.Sub(last))/float64(interval))
+			}
+		}
+		tbLogger.WithFields(log.Fields{"id": id, "tokens": tokens}).Debug("Check token bucket on CanTrigger")
+
+		allowed := tokens >= 1
+		if allowed {
+			tokens--
+		}
+
+		var saved bool
+		switch {
+		case !isAtomic:
+			saved, err = t.store.Set(id, formatBucket(tokens, now), ttl)
+		case ok:
+			saved, err = atomicStore.CompareAndSwap(id, val, formatBucket(tokens, now), ttl)
+		default:
+			saved, err = atomicStore.SetNX(id, formatBucket(tokens, now), ttl)
+		}
+		if err != nil {
+			return true, time.Duration(0), err
+		}
+		// bucket is updated by another caller, retry with its value
+		if !saved {
+			continue
+		}
+
+		if allowed {
+			return true, time.Duration(0), nil
+		}
+		return false, time.Duration((1 - tokens) * float64(interval)), nil
+	}
+
+	tbLogger.WithFields(log.Fields{"id": id}).Warn("Too many concurrent updates on token bucket")
+	return false, interval, nil
+}
+
+func formatBucket(tokens float64, at time.Time) string {
+	return fmt.Sprintf("%s:%d", strconv.FormatFloat(tokens, 'f', -1, 64), at.UnixNano())
+}
+
+func parseBucket(val string) (float64, time.Time, bool) {
+	parts := strings.SplitN(val, ":", 2)
+	if len(parts) != 2 {
+		return 0, time.Time{}, false
+	}
+	tokens, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	nanos, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	return tokens, time.Unix(0, nanos), true
+}
@@ -0,0 +1,158 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Wildcard segments of action patterns
+const (
+	ActionWildcard = "*"  // any one segment
+	ActionGlobstar = "**" // any number of segments, including none
+	ActionExclude  = "!"  // prefix of patterns excluding matched actions
+)
+
+// actionPattern - Action of hook by dot separated segments, with wildcards or exclusion
+type actionPattern struct {
+	segments []string
+	exclude  bool
+	literals int
+	globstar int
+}
+
+// parseActionPattern - Parse action of hook, wildcards must be whole segments, e.g. product.* or !*.view
+func parseActionPattern(action string) (actionPattern, error) {
+	pattern := actionPattern{exclude: strings.HasPrefix(action, ActionExclude)}
+	pattern.segments = strings.Split(strings.TrimPrefix(action, ActionExclude), ".")
+	for _, segment := range pattern.segments {
+		switch {
+		case segment == ActionGlobstar:
+			pattern.globstar++
+		case segment == ActionWildcard:
+		case segment == "" || strings.Contains(segment, ActionWildcard):
+			return pattern, fmt.Errorf("invalid action pattern \"%s\"", action)
+		default:
+			pattern.literals++
+		}
+	}
+	return pattern, nil
+}
+
+// IsActionPattern - Check if action has wildcards or exclusion, otherwise it is matched exactly
+func IsActionPattern(action string) bool {
+	return strings.HasPrefix(action, ActionExclude) || strings.Contains(action, ActionWildcard)
+}
+
+// precedes - Check if pattern takes precedence over other when both match an action
+//
+// Patterns with more literal segments win, then with fewer globstars, and exclusion wins ties
+func (p actionPattern) precedes(other actionPattern) bool {
+	if p.literals != other.literals {
+		return p.literals > other.literals
+	}
+	if p.globstar != other.globstar {
+		return p.globstar < other.globstar
+	}
+	return p.exclude && !other.exclude
+}
+
+// actionRule - Pattern of hook at index of configurations
+type actionRule struct {
+	index   int
+	pattern actionPattern
+}
+
+// actionTrie - Trie of action patterns by segments
+type actionTrie struct {
+	children map[string]*actionTrie
+	wildcard *actionTrie
+	globstar *actionTrie
+	rules    []actionRule
+}
+
+func newActionTrie() *actionTrie {
+	return &actionTrie{children: map[string]*actionTrie{}}
+}
+
+func (t *actionTrie) insert(rule actionRule) {
+	node := t
+	for _, segment := range rule.pattern.segments {
+		switch segment {
+		case ActionWildcard:
+			if node.wildcard == nil {
+				node.wildcard = newActionTrie()
+			}
+			node = node.wildcard
+		case ActionGlobstar:
+			if node.globstar == nil {
+				node.globstar = newActionTrie()
+			}
+			node = node.globstar
+		default:
+			if node.children[segment] == nil {
+				node.children[segment] = newActionTrie()
+			}
+			node = node.children[segment]
+		}
+	}
+	node.rules = append(node.rules, rule)
+}
+
+// match - Indexes of hooks matching action, by the rule taking precedence for each hook
+func (t *actionTrie) match(action string) map[int]bool {
+	matched := map[int]actionPattern{}
+	t.visit(strings.Split(action, "."), func(rule actionRule) {
+		if current, exists := matched[rule.index]; !exists || rule.pattern.precedes(current) {
+			matched[rule.index] = rule.pattern
+		}
+	})
+
+	result := map[int]bool{}
+	for index, pattern := range matched {
+		result[index] = !pattern.exclude
+	}
+	return result
+}
+
+func (t *actionTrie) visit(segments []string, fn func(actionRule)) {
+	if len(segments) == 0 {
+		for _, rule := range t.rules {
+			fn(rule)
+		}
+	} else {
+		if child, exists := t.children[segments[0]]; exists {
+			child.visit(segments[1:], fn)
+		}
+		if t.wildcard != nil {
+			t.wildcard.visit(segments[1:], fn)
+		}
+	}
+	if t.globstar != nil {
+		for i := 0; i <= len(segments); i++ {
+			t.globstar.visit(segments[i:], fn)
+		}
+	}
+}
+
+// ActionMatcher - Match actions with patterns in the syntax of hook actions, e.g. product.*, **.delete or !*.view
+type ActionMatcher struct {
+	trie *actionTrie
+}
+
+// NewActionMatcher - Create ActionMatcher matching actions as a hook with patterns as its actions
+func NewActionMatcher(patterns []string) (*ActionMatcher, error) {
+	trie := newActionTrie()
+	for _, action := range patterns {
+		pattern, err := parseActionPattern(action)
+		if err != nil {
+			return nil, err
+		}
+		trie.insert(actionRule{pattern: pattern})
+	}
+	return &ActionMatcher{trie: trie}, nil
+}
+
+// Match - Check if action matches patterns, and no exclusion taking precedence
+func (m *ActionMatcher) Match(action string) bool {
+	return m.trie.match(action)[0]
+}
@@ -7,7 +7,44 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
 	"github.com/shoplineapp/captin/interfaces"
+	log "github.com/sirupsen/logrus"
+)
+
+var configLogger = log.WithFields(log.Fields{"class": "Configuration"})
+
+var timeValuePattern = regexp.MustCompile(`^(\d+(\.\d+)?(ms|s|m|h))+$`)
+var timeValuePartPattern = regexp.MustCompile(`(\d+(?:\.\d+)?)(ms|s|m|h)`)
+
+var timeUnits = map[string]time.Duration{
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+// Policies on failures of document store
+const (
+	DocumentPolicyError = "error" // report dispatcher error, default
+	DocumentPolicySkip  = "skip"  // skip sending to destination
+	DocumentPolicySend  = "send"  // send with empty document
+)
+
+// Strategies of throttling events in period
+const (
+	ThrottleStrategyPeriod        = "period"         // first event in period is sent, the rest trail, default
+	ThrottleStrategyTokenBucket   = "token_bucket"   // throttle_limit tokens refilled per period, up to throttle_burst
+	ThrottleStrategySlidingWindow = "sliding_window" // throttle_limit events in any period
+)
+
+// Strategies of merging throttled payloads into payload of the trailing event
+const (
+	ThrottledPayloadsMergeNone      = ""           // payload of the latest event, default
+	ThrottledPayloadsMergeDeep      = "deep_merge" // deep merge in order, later values win
+	ThrottledPayloadsMergeUnion     = "union"      // union of top level keys in order, later values win
+	ThrottledPayloadsMergeKeepFirst = "keep_first" // payload of the first throttled event
+	ThrottledPayloadsMergeKeepLast  = "keep_last"  // payload of the last throttled event
 )
 
 // Configuration - Webhook Configuration Model
@@ -21,22 +58,42 @@ type Configuration struct {
 	Source                   string            `json:"source"`
 	Throttle                 string            `json:"throttle"`
 	Delay                    string            `json:"delay"`
+	ThrottleStrategy         string            `json:"throttle_strategy"`
+	ThrottleLimit            int               `json:"throttle_limit"`
+	ThrottleBurst            int               `json:"throttle_burst"`
+	ThrottleKey              string            `json:"throttle_key"`
+	ThrottleLeading          *bool             `json:"throttle_leading"`
 	ThrottleTrailingDisabled bool              `json:"throttle_trailing_disabled"`
+	Debounce                 string            `json:"debounce"`
+	MaxWait                  string            `json:"max_wait"`
 	KeepThrottledPayloads    bool              `json:"keep_throttled_payloads"`
+	ThrottledPayloadsMerge   string            `json:"throttled_payloads_merge"`
+	ThrottledPayloadsLimit   int               `json:"throttled_payloads_limit"`
 	KeepThrottledDocuments   bool              `json:"keep_throttled_documents"`
 	IncludeDocument          bool              `json:"include_document"`
 	Name                     string            `json:"name"`
 	AllowLoopback            bool              `json:"allow_loopback"`
 	Sender                   string            `json:"sender"`
 	DocumentStore            string            `json:"document_store"`
+	DocumentTimeout          string            `json:"document_timeout"`
+	OnDocumentError          string            `json:"on_document_error"`
+	OnDocumentNotFound       string            `json:"on_document_not_found"`
 	RetryBackoff             string            `json:"retry_backoff"`
 	IncludeDocumentAttrs     []string          `json:"include_document_attrs"`
 	ExcludeDocumentAttrs     []string          `json:"exclude_document_attrs"`
 	IncludePayloadAttrs      []string          `json:"include_payload_attrs"`
 	ExcludePayloadAttrs      []string          `json:"exclude_payload_attrs"`
+	PayloadSchema            string            `json:"payload_schema"`
 	Extras                   map[string]string `json:"extras"`
+	Tenant                   string            `json:"tenant"`
+
+	// secrets - Values of secrets resolved, redacted when configuration is logged
+	secrets []string
+	// overrides - Fields overridden by environment variables, see ApplyEnvOverrides
+	overrides []string
 }
 
+// GetByEnv - Get name and value of environment variable HOOK_<NAME>_<KEY> overriding key of hook
 func (c Configuration) GetByEnv(key string) (string, string) {
 	envKey := fmt.Sprintf("HOOK_%s_%s", strings.ToUpper(c.Name), strings.ToUpper(key))
 	return envKey, os.Getenv(envKey)
@@ -52,35 +109,50 @@ func (c Configuration) GetDelayValue() time.Duration {
 	return c.GetTimeValueMillis(c.Delay)
 }
 
-// GetTimeValueMillis - Get millisecond from time value string
+// GetDebounceValue - Get period without new events before sending in millisecond
+func (c Configuration) GetDebounceValue() time.Duration {
+	return c.GetTimeValueMillis(c.Debounce)
+}
+
+// GetMaxWaitValue - Get max time of debounced events waiting to be sent in millisecond
+func (c Configuration) GetMaxWaitValue() time.Duration {
+	return c.GetTimeValueMillis(c.MaxWait)
+}
+
+// GetDocumentTimeoutValue - Get timeout of fetching document in millisecond
+func (c Configuration) GetDocumentTimeoutValue() time.Duration {
+	return c.GetTimeValueMillis(c.DocumentTimeout)
+}
+
+// GetTimeValueMillis - Get millisecond from time value string, 0 if it is invalid
 func (c Configuration) GetTimeValueMillis(timeValue string) time.Duration {
-	match := regexp.MustCompile("(\\d+(?:\\.\\d+)?)(s|ms|m|h)")
-	res := match.FindAllStringSubmatch(timeValue, -1)
+	value, err := ParseTimeValue(timeValue)
+	if err != nil {
+		configLogger.WithFields(log.Fields{"name": c.Name, "error": err}).Warn("Invalid time value")
+		return 0
+	}
+	return value
+}
 
-	for i := range res {
-		value, err := strconv.Atoi(res[i][1])
+// ParseTimeValue - Parse time value string like 500ms, 1.5s or 1m30s, 0 for empty string
+func ParseTimeValue(timeValue string) (time.Duration, error) {
+	timeValue = strings.TrimSpace(timeValue)
+	if timeValue == "" {
+		return 0, nil
+	}
+	if !timeValuePattern.MatchString(timeValue) {
+		return 0, fmt.Errorf("invalid time value \"%s\", expected number with unit ms, s, m or h", timeValue)
+	}
 
+	result := time.Duration(0)
+	for _, match := range timeValuePartPattern.FindAllStringSubmatch(timeValue, -1) {
+		value, err := strconv.ParseFloat(match[1], 64)
 		if err != nil {
-			panic(err)
-		}
-
-		unit := res[i][2]
-
-		switch unit {
-		case "ms":
-			return time.Millisecond * time.Duration(value)
-		case "s":
-			return time.Second * time.Duration(value)
-		case "m":
-			return time.Minute * time.Duration(value)
-		case "h":
-			return time.Hour * time.Duration(value)
-		default:
-			panic("unrecognized time unit")
+			return 0, fmt.Errorf("invalid time value \"%s\", %s", timeValue, err)
 		}
+		result += time.Duration(value * float64(timeUnits[match[2]]))
 	}
-
-	return 0
+	return result, nil
 }
 
 func (c Configuration) GetActions() []string {
@@ -111,14 +183,74 @@ func (c Configuration) GetDelay() string {
 	return c.Delay
 }
 
+// GetThrottleStrategy - Get throttle strategy, default to period
+func (c Configuration) GetThrottleStrategy() string {
+	if c.ThrottleStrategy == "" {
+		return ThrottleStrategyPeriod
+	}
+	return c.ThrottleStrategy
+}
+
+// GetThrottleLimit - Get number of events allowed in throttle period, default to 1
+func (c Configuration) GetThrottleLimit() int {
+	if c.ThrottleLimit <= 0 {
+		return 1
+	}
+	return c.ThrottleLimit
+}
+
+// GetThrottleBurst - Get capacity of token bucket, default to throttle limit
+func (c Configuration) GetThrottleBurst() int {
+	if c.ThrottleBurst <= 0 {
+		return c.GetThrottleLimit()
+	}
+	return c.ThrottleBurst
+}
+
+// GetThrottleLeading - Get if first event in throttle period is sent immediately, default to true
+//
+// Always true when trailing edge is disabled, otherwise no event would be sent
+func (c Configuration) GetThrottleLeading() bool {
+	if c.ThrottleLeading == nil || c.ThrottleTrailingDisabled {
+		return true
+	}
+	return *c.ThrottleLeading
+}
+
+// GetThrottleKey - Get comma separated fields identifying events throttled together, see ThrottleKey
+func (c Configuration) GetThrottleKey() string {
+	return c.ThrottleKey
+}
+
 func (c Configuration) GetThrottleTrailingDisabled() bool {
 	return c.ThrottleTrailingDisabled
 }
 
+func (c Configuration) GetDebounce() string {
+	return c.Debounce
+}
+
+func (c Configuration) GetMaxWait() string {
+	return c.MaxWait
+}
+
 func (c Configuration) GetKeepThrottledPayloads() bool {
 	return c.KeepThrottledPayloads
 }
 
+// GetThrottledPayloadsMerge - Get strategy of merging throttled payloads into payload
+func (c Configuration) GetThrottledPayloadsMerge() string {
+	return c.ThrottledPayloadsMerge
+}
+
+// GetThrottledPayloadsLimit - Get max number of latest throttled payloads sent, 0 for unlimited
+func (c Configuration) GetThrottledPayloadsLimit() int {
+	if c.ThrottledPayloadsLimit < 0 {
+		return 0
+	}
+	return c.ThrottledPayloadsLimit
+}
+
 func (c Configuration) GetKeepThrottledDocuments() bool {
 	return c.KeepThrottledDocuments
 }
@@ -143,6 +275,26 @@ func (c Configuration) GetDocumentStore() string {
 	return c.DocumentStore
 }
 
+func (c Configuration) GetDocumentTimeout() string {
+	return c.DocumentTimeout
+}
+
+// GetOnDocumentError - Get policy on document store errors, default to report error
+func (c Configuration) GetOnDocumentError() string {
+	if c.OnDocumentError == "" {
+		return DocumentPolicyError
+	}
+	return c.OnDocumentError
+}
+
+// GetOnDocumentNotFound - Get policy when document does not exist, default to policy on document errors
+func (c Configuration) GetOnDocumentNotFound() string {
+	if c.OnDocumentNotFound == "" {
+		return c.GetOnDocumentError()
+	}
+	return c.OnDocumentNotFound
+}
+
 func (c Configuration) GetRetryBackoff() []string {
 	return strings.Split(c.RetryBackoff, ",")
 }
@@ -163,6 +315,15 @@ func (c Configuration) GetExcludePayloadAttrs() []string {
 	return c.ExcludePayloadAttrs
 }
 
+func (c Configuration) GetPayloadSchema() string {
+	return c.PayloadSchema
+}
+
 func (c Configuration) GetExtras() map[string]string {
 	return c.Extras
 }
+
+// GetTenant - Get id of tenant which hook is scoped to, empty for global hooks
+func (c Configuration) GetTenant() string {
+	return c.Tenant
+}
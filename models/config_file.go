@@ -0,0 +1,367 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	toml "github.com/pelletier/go-toml"
+	helpers "github.com/shoplineapp/captin/internal/helpers"
+	yaml "gopkg.in/yaml.v3"
+)
+
+var yamlErrorPattern = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+var tomlErrorPattern = regexp.MustCompile(`^\((\d+), \d+\): (.*)$`)
+
+// ConfigurationFileError - Invalid configuration file, with line of the invalid value if known
+type ConfigurationFileError struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e ConfigurationFileError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+	}
+	return fmt.Sprintf("%s: %s", e.File, e.Msg)
+}
+
+// configDocument - Configuration file decoded into JSON compatible values, with line of values by path
+//
+// Paths are keys and array indexes joined by dot, e.g. hooks.0.name
+type configDocument struct {
+	file  string
+	value interface{}
+	lines map[string]int
+}
+
+// decodeConfigDocument - Decode JSON, YAML (.yaml, .yml) or TOML (.toml) file by extension
+func decodeConfigDocument(file string, data []byte) (*configDocument, error) {
+	doc := &configDocument{file: file, lines: map[string]int{}}
+	var err error
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		err = doc.decodeYAML(data)
+	case ".toml":
+		err = doc.decodeTOML(data)
+	default:
+		err = doc.decodeJSON(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// loadedHook - Configuration of hook with its location
+type loadedHook struct {
+	config Configuration
+	file   string
+	line   int
+}
+
+// object - Top level object of document, nil if document is an array of hooks
+func (d *configDocument) object() map[string]interface{} {
+	object, _ := d.value.(map[string]interface{})
+	return object
+}
+
+// hooks - Decode hooks of document with defaults, document is an array of hooks or an object with hooks
+func (d *configDocument) hooks(defaults map[string]interface{}) ([]loadedHook, error) {
+	hooks, path := d.value, ""
+	if object := d.object(); object != nil {
+		if hooks, path = object["hooks"], "hooks"; hooks == nil {
+			if _, hasInclude := object["include"]; hasInclude {
+				return []loadedHook{}, nil
+			}
+			return nil, d.errorAt("", "missing hooks")
+		}
+	}
+
+	list, ok := hooks.([]interface{})
+	if !ok {
+		return nil, d.errorAt(path, "hooks must be an array")
+	}
+
+	result := []loadedHook{}
+	for i, hook := range list {
+		hookPath := joinPath(path, strconv.Itoa(i))
+		fields, ok := hook.(map[string]interface{})
+		if !ok {
+			return nil, d.errorAt(hookPath, fmt.Sprintf("hook %d must be an object", i))
+		}
+		config, err := d.configuration(helpers.DeepMerge(defaults, fields), hookPath)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, loadedHook{config: config, file: d.file, line: d.lineOf(hookPath)})
+	}
+	return result, nil
+}
+
+func (d *configDocument) configuration(hook interface{}, path string) (Configuration, error) {
+	config := Configuration{}
+	data, err := json.Marshal(hook)
+	if err != nil {
+		return config, d.errorAt(path, err.Error())
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		if typeErr, ok := err.(*json.UnmarshalTypeError); ok && typeErr.Field != "" {
+			return config, d.errorAt(joinPath(path, typeErr.Field), fmt.Sprintf("invalid %s, cannot use %s as %s", typeErr.Field, typeErr.Value, typeErr.Type))
+		}
+		return config, d.errorAt(path, err.Error())
+	}
+	return config, nil
+}
+
+// errorAt - Error on value of path
+func (d *configDocument) errorAt(path string, msg string) ConfigurationFileError {
+	return ConfigurationFileError{File: d.file, Line: d.lineOf(path), Msg: msg}
+}
+
+// lineOf - Line of value at path or its nearest parent known, 0 if unknown
+func (d *configDocument) lineOf(path string) int {
+	for {
+		if line, ok := d.lines[path]; ok {
+			return line
+		}
+		if path == "" {
+			return 0
+		}
+		if i := strings.LastIndex(path, "."); i >= 0 {
+			path = path[:i]
+		} else {
+			path = ""
+		}
+	}
+}
+
+func (d *configDocument) decodeJSON(data []byte) error {
+	// Validate first for errors of the whole document with offset
+	var probe interface{}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		if syntaxErr, ok := err.(*json.SyntaxError); ok {
+			return ConfigurationFileError{File: d.file, Line: lineAt(data, syntaxErr.Offset), Msg: err.Error()}
+		}
+		return ConfigurationFileError{File: d.file, Msg: err.Error()}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	value, err := d.jsonValue(decoder, data, "")
+	if err != nil {
+		return ConfigurationFileError{File: d.file, Line: lineAt(data, decoder.InputOffset()), Msg: err.Error()}
+	}
+	d.value = value
+	return nil
+}
+
+func (d *configDocument) jsonValue(decoder *json.Decoder, data []byte, path string) (interface{}, error) {
+	offset := decoder.InputOffset()
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	d.lines[path] = lineAt(data, skipSeparators(data, offset))
+
+	switch token {
+	case json.Delim('{'):
+		object := map[string]interface{}{}
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := d.jsonValue(decoder, data, joinPath(path, key.(string)))
+			if err != nil {
+				return nil, err
+			}
+			object[key.(string)] = value
+		}
+		_, err = decoder.Token()
+		return object, err
+	case json.Delim('['):
+		array := []interface{}{}
+		for decoder.More() {
+			value, err := d.jsonValue(decoder, data, joinPath(path, strconv.Itoa(len(array))))
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		_, err = decoder.Token()
+		return array, err
+	}
+	return token, nil
+}
+
+func (d *configDocument) decodeYAML(data []byte) error {
+	root := yaml.Node{}
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		if match := yamlErrorPattern.FindStringSubmatch(err.Error()); match != nil {
+			line, _ := strconv.Atoi(match[1])
+			return ConfigurationFileError{File: d.file, Line: line, Msg: match[2]}
+		}
+		return ConfigurationFileError{File: d.file, Msg: err.Error()}
+	}
+	value, err := d.yamlValue(&root, "")
+	if err != nil {
+		return err
+	}
+	d.value = value
+	return nil
+}
+
+// yamlValue - Convert node into JSON compatible value, resolving aliases and merge keys (<<)
+func (d *configDocument) yamlValue(node *yaml.Node, path string) (interface{}, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return d.yamlValue(node.Content[0], path)
+	case yaml.AliasNode:
+		value, err := d.yamlValue(node.Alias, path)
+		d.lines[path] = node.Line
+		return value, err
+	case yaml.MappingNode:
+		d.lines[path] = node.Line
+		object := map[string]interface{}{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Tag == "!!merge" {
+				continue
+			}
+			converted, err := d.yamlValue(value, joinPath(path, key.Value))
+			if err != nil {
+				return nil, err
+			}
+			object[key.Value] = converted
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Tag == "!!merge" {
+				if err := d.yamlMerge(object, node.Content[i+1], path); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return object, nil
+	case yaml.SequenceNode:
+		d.lines[path] = node.Line
+		array := []interface{}{}
+		for i, item := range node.Content {
+			converted, err := d.yamlValue(item, joinPath(path, strconv.Itoa(i)))
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, converted)
+		}
+		return array, nil
+	}
+
+	d.lines[path] = node.Line
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		return nil, ConfigurationFileError{File: d.file, Line: node.Line, Msg: err.Error()}
+	}
+	return value, nil
+}
+
+// yamlMerge - Merge mappings of merge key into object, keys set explicitly or by earlier mappings win
+func (d *configDocument) yamlMerge(object map[string]interface{}, node *yaml.Node, path string) error {
+	sources := []*yaml.Node{node}
+	if node.Kind == yaml.SequenceNode {
+		sources = node.Content
+	}
+	for _, source := range sources {
+		value, err := d.yamlValue(source, path+".<<")
+		if err != nil {
+			return err
+		}
+		mapping, ok := value.(map[string]interface{})
+		if !ok {
+			return ConfigurationFileError{File: d.file, Line: source.Line, Msg: "merge key must refer to a mapping"}
+		}
+		for key, item := range mapping {
+			if _, exists := object[key]; exists {
+				continue
+			}
+			object[key] = item
+			if line, ok := d.lines[path+".<<."+key]; ok {
+				d.lines[joinPath(path, key)] = line
+			}
+		}
+	}
+	return nil
+}
+
+func (d *configDocument) decodeTOML(data []byte) error {
+	tree, err := toml.LoadBytes(data)
+	if err != nil {
+		if match := tomlErrorPattern.FindStringSubmatch(err.Error()); match != nil {
+			line, _ := strconv.Atoi(match[1])
+			return ConfigurationFileError{File: d.file, Line: line, Msg: match[2]}
+		}
+		return ConfigurationFileError{File: d.file, Msg: err.Error()}
+	}
+	d.value = d.tomlTree(tree, "")
+	return nil
+}
+
+func (d *configDocument) tomlTree(tree *toml.Tree, path string) map[string]interface{} {
+	d.lines[path] = tree.Position().Line
+	object := map[string]interface{}{}
+	for _, key := range tree.Keys() {
+		keyPath := joinPath(path, key)
+		d.lines[keyPath] = tree.GetPositionPath([]string{key}).Line
+		object[key] = d.tomlValue(tree.GetPath([]string{key}), keyPath)
+	}
+	return object
+}
+
+func (d *configDocument) tomlValue(value interface{}, path string) interface{} {
+	switch v := value.(type) {
+	case *toml.Tree:
+		return d.tomlTree(v, path)
+	case []*toml.Tree:
+		array := []interface{}{}
+		for i, item := range v {
+			array = append(array, d.tomlTree(item, joinPath(path, strconv.Itoa(i))))
+		}
+		return array
+	case []interface{}:
+		array := []interface{}{}
+		for i, item := range v {
+			array = append(array, d.tomlValue(item, joinPath(path, strconv.Itoa(i))))
+		}
+		return array
+	}
+	return value
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// skipSeparators - Offset of next token after whitespaces, commas and colons
+func skipSeparators(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) && strings.IndexByte(" \t\r\n,:", data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
@@ -0,0 +1,297 @@
+package models
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	helpers "github.com/shoplineapp/captin/internal/helpers"
+)
+
+// Extensions of configuration files loaded from directory
+var configExtensions = map[string]bool{".json": true, ".yaml": true, ".yml": true, ".toml": true}
+
+// configLoader - Load hooks from configuration files and directories
+//
+// Files may include other files relative to them with "include", and set "defaults" for their hooks.
+// Within a directory, the defaults file (defaults.json, defaults.yaml, ...) applies to hooks of every file.
+type configLoader struct {
+	defaults map[string]interface{}
+	loaded   map[string]bool
+	stack    []string
+	hooks    []loadedHook
+}
+
+// loadConfigurations - Load configurations from file or directory, with no duplicated hook id or name
+func loadConfigurations(path string) ([]Configuration, error) {
+	configs, _, err := loadConfigurationFiles(path)
+	return configs, err
+}
+
+// loadConfigurationFiles - Load configurations from file or directory, with absolute paths of files loaded,
+// including defaults and included files
+func loadConfigurationFiles(path string) ([]Configuration, []string, error) {
+	loader := &configLoader{defaults: map[string]interface{}{}, loaded: map[string]bool{}}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.IsDir() {
+		err = loader.loadDir(path)
+	} else {
+		err = loader.loadFile(path)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	configs, err := loader.configurations()
+	if err != nil {
+		return nil, nil, err
+	}
+	files := []string{}
+	for file := range loader.loaded {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return configs, files, nil
+}
+
+// configurations - Configurations of loaded hooks with environment overrides applied and secrets resolved,
+// and no duplicated hook id or name
+func (l *configLoader) configurations() ([]Configuration, error) {
+	configs := []Configuration{}
+	for _, hook := range l.hooks {
+		configs = append(configs, hook.config)
+	}
+	configs, err := ApplyEnvOverrides(configs)
+	if err != nil {
+		return nil, err
+	}
+	// ids overridden may duplicate ids of other hooks
+	for i := range l.hooks {
+		l.hooks[i].config = configs[i]
+	}
+	if err := l.checkDuplicates(); err != nil {
+		return nil, err
+	}
+	return DefaultSecretResolver.ResolveConfigurations(configs)
+}
+
+// loadConfigurationsFromData - Load configurations of document named with extension of its format, JSON if unknown
+//
+// Documents not loaded from files cannot include other files
+func loadConfigurationsFromData(name string, data []byte) ([]Configuration, error) {
+	loader := &configLoader{defaults: map[string]interface{}{}, loaded: map[string]bool{}}
+	doc, err := decodeConfigDocument(name, data)
+	if err != nil {
+		return nil, err
+	}
+	if _, hasInclude := doc.object()["include"]; hasInclude {
+		return nil, doc.errorAt("include", "include is only supported in files")
+	}
+	if err := loader.loadDocument(doc); err != nil {
+		return nil, err
+	}
+	return loader.configurations()
+}
+
+// loadDir - Load defaults file and then other configuration files in directory and subdirectories, by name
+func (l *configLoader) loadDir(dir string) error {
+	files := []string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name := info.Name()
+		if path != dir && strings.HasPrefix(name, ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// JSON schemas are placed in the schemas directory
+		if info.IsDir() && path == filepath.Join(dir, "schemas") {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() && configExtensions[strings.ToLower(filepath.Ext(name))] {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		if filepath.Dir(file) == filepath.Clean(dir) && strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)) == "defaults" {
+			if err := l.loadDefaults(file); err != nil {
+				return err
+			}
+		}
+	}
+	for _, file := range files {
+		if err := l.loadFile(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadDefaults - Load fields of defaults file applied to every hook
+func (l *configLoader) loadDefaults(file string) error {
+	doc, err := readConfigDocument(file)
+	if err != nil {
+		return err
+	}
+	defaults := doc.object()
+	if defaults == nil {
+		return doc.errorAt("", "defaults must be an object")
+	}
+	if _, err := doc.configuration(defaults, ""); err != nil {
+		return err
+	}
+	l.defaults = helpers.DeepMerge(l.defaults, defaults)
+	l.loaded[absPath(file)] = true
+	return nil
+}
+
+// loadFile - Load hooks of file with its defaults, and then its includes, every file is loaded once
+func (l *configLoader) loadFile(file string) error {
+	abs := absPath(file)
+	for i, loading := range l.stack {
+		if loading == abs {
+			cycle := append(append([]string{}, l.stack[i:]...), abs)
+			return fmt.Errorf("include cycle %s", strings.Join(cycle, " -> "))
+		}
+	}
+	if l.loaded[abs] {
+		return nil
+	}
+	l.loaded[abs] = true
+	l.stack = append(l.stack, abs)
+	defer func() { l.stack = l.stack[:len(l.stack)-1] }()
+
+	doc, err := readConfigDocument(file)
+	if err != nil {
+		return err
+	}
+	if err := l.loadDocument(doc); err != nil {
+		return err
+	}
+
+	includes, err := doc.includes()
+	if err != nil {
+		return err
+	}
+	for _, include := range includes {
+		pattern := include
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(file), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil || len(matches) == 0 {
+			return doc.errorAt("include", fmt.Sprintf("include %s not found", include))
+		}
+		for _, match := range matches {
+			if err := l.loadFile(match); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// loadDocument - Load hooks of document with its defaults, without includes
+func (l *configLoader) loadDocument(doc *configDocument) error {
+	defaults := l.defaults
+	object := doc.object()
+	if fileDefaults, exists := object["defaults"]; exists {
+		fields, ok := fileDefaults.(map[string]interface{})
+		if !ok {
+			return doc.errorAt("defaults", "defaults must be an object")
+		}
+		if _, err := doc.configuration(fields, "defaults"); err != nil {
+			return err
+		}
+		defaults = helpers.DeepMerge(defaults, fields)
+	}
+
+	hooks, err := doc.hooks(defaults)
+	if err != nil {
+		return err
+	}
+	l.hooks = append(l.hooks, hooks...)
+	return nil
+}
+
+// checkDuplicates - Hooks must have unique id and name, within their tenant
+func (l *configLoader) checkDuplicates() error {
+	ids := map[string]loadedHook{}
+	names := map[string]loadedHook{}
+	for _, hook := range l.hooks {
+		tenant := TenantOf(hook.config)
+		if id := hook.config.GetConfigID(); id != "" {
+			if first, exists := ids[tenant+":"+id]; exists {
+				return hook.duplicateError("id", id, first)
+			}
+			ids[tenant+":"+id] = hook
+		}
+		if name := hook.config.GetName(); name != "" {
+			if first, exists := names[tenant+":"+name]; exists {
+				return hook.duplicateError("name", name, first)
+			}
+			names[tenant+":"+name] = hook
+		}
+	}
+	return nil
+}
+
+func (h loadedHook) duplicateError(field string, value string, first loadedHook) ConfigurationFileError {
+	return ConfigurationFileError{
+		File: h.file,
+		Line: h.line,
+		Msg:  fmt.Sprintf("duplicate hook %s \"%s\", already defined at %s:%d", field, value, first.file, first.line),
+	}
+}
+
+// includes - Paths or glob patterns of files included by document
+func (d *configDocument) includes() ([]string, error) {
+	switch include := d.object()["include"].(type) {
+	case nil:
+		return []string{}, nil
+	case string:
+		return []string{include}, nil
+	case []interface{}:
+		paths := []string{}
+		for _, path := range include {
+			str, ok := path.(string)
+			if !ok {
+				return nil, d.errorAt("include", "include must be a path or an array of paths")
+			}
+			paths = append(paths, str)
+		}
+		return paths, nil
+	}
+	return nil, d.errorAt("include", "include must be a path or an array of paths")
+}
+
+func readConfigDocument(file string) (*configDocument, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return decodeConfigDocument(file, data)
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
@@ -1,8 +1,7 @@
 package models
 
 import (
-	"encoding/json"
-	"io/ioutil"
+	"sort"
 
 	log "github.com/sirupsen/logrus"
 	interfaces "github.com/shoplineapp/captin/interfaces"
@@ -11,49 +10,182 @@ import (
 var cmLogger = log.WithFields(log.Fields{"class": "ConfigurationMapper"})
 
 // ConfigurationMapper - Action to configuration mapper
+//
+// Actions of hooks are matched exactly with ActionMap, unless hooks have patterns with wildcards or exclusion,
+// which are matched with trie of action segments. Hooks scoped to tenants are mapped separately by tenant.
 type ConfigurationMapper struct {
+	interfaces.TenantConfigMapperInterface
+
 	ActionMap map[string][]interfaces.ConfigurationInterface
+	configs   []interfaces.ConfigurationInterface
+	indexed   []interfaces.ConfigurationInterface
+	exact     map[string][]int
+	patterns  *actionTrie
+	tenants   map[string]*ConfigurationMapper
+
+	// schemaRegistry - Schemas loaded with configurations, nil if there is none
+	schemaRegistry interfaces.SchemaRegistryInterface
+	// files - Absolute paths of configuration files loaded
+	files []string
 }
 
 // NewConfigurationMapper - Create ConfigurationMapper with array of Configurations
 func NewConfigurationMapper(configs []interfaces.ConfigurationInterface) *ConfigurationMapper {
+	global := []interfaces.ConfigurationInterface{}
+	scoped := map[string][]interfaces.ConfigurationInterface{}
+	for _, config := range configs {
+		if tenant := TenantOf(config); tenant != "" {
+			scoped[tenant] = append(scoped[tenant], config)
+		} else {
+			global = append(global, config)
+		}
+	}
+
+	result := newActionMapper(global)
+	result.configs = configs
+	for tenant, tenantConfigs := range scoped {
+		result.tenants[tenant] = newActionMapper(tenantConfigs)
+	}
+	return result
+}
+
+// newActionMapper - Map actions of configurations regardless of their tenants
+func newActionMapper(configs []interfaces.ConfigurationInterface) *ConfigurationMapper {
 	result := ConfigurationMapper{
 		ActionMap: make(map[string][]interfaces.ConfigurationInterface),
+		configs:   configs,
+		indexed:   configs,
+		exact:     make(map[string][]int),
+		tenants:   make(map[string]*ConfigurationMapper),
 	}
-	for _, config := range configs {
+	for i, config := range configs {
+		if hasActionPattern(config) {
+			result.addPatterns(i, config)
+			continue
+		}
 		for _, action := range config.GetActions() {
 			list := result.ActionMap[action]
 			list = append(list, config)
 			result.ActionMap[action] = list
+			result.exact[action] = append(result.exact[action], i)
 		}
 	}
 	return &result
 }
 
-// NewConfigurationMapperFromPath - Read Configuration from path
+func (cm *ConfigurationMapper) addPatterns(index int, config interfaces.ConfigurationInterface) {
+	if cm.patterns == nil {
+		cm.patterns = newActionTrie()
+	}
+	for _, action := range config.GetActions() {
+		pattern, err := parseActionPattern(action)
+		if err != nil {
+			cmLogger.WithFields(log.Fields{"hook_name": config.GetName(), "error": err}).Warn("Ignored invalid action pattern")
+			continue
+		}
+		cm.patterns.insert(actionRule{index: index, pattern: pattern})
+	}
+}
+
+func hasActionPattern(config interfaces.ConfigurationInterface) bool {
+	for _, action := range config.GetActions() {
+		if IsActionPattern(action) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadConfigurationMapper - Load and validate configurations from file or directory of files,
+// in JSON, YAML (.yaml, .yml) or TOML (.toml)
+func LoadConfigurationMapper(path string) (*ConfigurationMapper, error) {
+	configs, err := loadValidConfigurations(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewConfigurationMapper(configs), nil
+}
+
+// NewConfigurationMapperFromPath - Read Configuration from file or directory of files, panic on error
+//
+// Deprecated: use LoadConfigurationMapper, which validates configurations and returns errors
 func NewConfigurationMapperFromPath(path string) *ConfigurationMapper {
 	pathLogger := cmLogger.WithFields(log.Fields{"path": path})
-	data, err := ioutil.ReadFile(path)
 
+	raw, err := loadConfigurations(path)
 	if err != nil {
-		pathLogger.Error("Failed to load file")
+		pathLogger.WithFields(log.Fields{"error": err}).Error("Failed to load configurations")
 		panic(err)
 	}
 
-	raw := []Configuration{}
-	jsonErr := json.Unmarshal(data, &raw)
-	if jsonErr != nil {
-		pathLogger.Error("Invalid configuration file format")
-		panic(jsonErr)
+	configs := []interfaces.ConfigurationInterface{}
+	for _, c := range raw {
+		configs = append(configs, c)
+	}
+	return NewConfigurationMapper(configs)
+}
+
+// loadValidConfigurations - Load configurations passing ValidateConfigurations without keys of Captin
+func loadValidConfigurations(path string) ([]interfaces.ConfigurationInterface, error) {
+	raw, err := loadConfigurations(path)
+	if err != nil {
+		return nil, err
 	}
+	return validConfigurations(raw, ConfigurationRules{})
+}
 
+func validConfigurations(raw []Configuration, rules ConfigurationRules) ([]interfaces.ConfigurationInterface, error) {
 	configs := []interfaces.ConfigurationInterface{}
 	for _, c := range raw {
 		configs = append(configs, c)
 	}
-	return NewConfigurationMapper(configs)
+	if err := ValidateConfigurations(configs, rules); err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
+
+// SchemaRegistry - Get schemas loaded with configurations, nil if there is none
+func (cm ConfigurationMapper) SchemaRegistry() interfaces.SchemaRegistryInterface {
+	return cm.schemaRegistry
 }
 
+// Configs - Get all configurations in mapper
+func (cm ConfigurationMapper) Configs() []interfaces.ConfigurationInterface {
+	return cm.configs
+}
+
+// ConfigsForKey - Get global configurations with actions matching event key, in order of configurations
 func (cm ConfigurationMapper) ConfigsForKey(eventKey string) []interfaces.ConfigurationInterface {
-	return cm.ActionMap[eventKey]
+	if cm.patterns == nil {
+		return cm.ActionMap[eventKey]
+	}
+
+	matched := cm.patterns.match(eventKey)
+	for _, index := range cm.exact[eventKey] {
+		matched[index] = true
+	}
+	indexes := []int{}
+	for index, included := range matched {
+		if included {
+			indexes = append(indexes, index)
+		}
+	}
+	sort.Ints(indexes)
+
+	result := []interfaces.ConfigurationInterface{}
+	for _, index := range indexes {
+		result = append(result, cm.indexed[index])
+	}
+	return result
+}
+
+// ConfigsForTenantKey - Get global configurations and then configurations of tenant with actions matching event key
+func (cm ConfigurationMapper) ConfigsForTenantKey(tenant string, eventKey string) []interfaces.ConfigurationInterface {
+	tenantMapper, exists := cm.tenants[tenant]
+	if !exists {
+		return cm.ConfigsForKey(eventKey)
+	}
+	result := append([]interfaces.ConfigurationInterface{}, cm.ConfigsForKey(eventKey)...)
+	return append(result, tenantMapper.ConfigsForKey(eventKey)...)
 }
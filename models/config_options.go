@@ -0,0 +1,69 @@
+package models
+
+import (
+	interfaces "github.com/shoplineapp/captin/interfaces"
+)
+
+// PayloadSchemaOf - Schema of payloads of configuration, empty if not implemented
+func PayloadSchemaOf(config interfaces.ConfigurationInterface) string {
+	if schema, ok := config.(interfaces.SchemaConfigurationInterface); ok {
+		return schema.GetPayloadSchema()
+	}
+	return ""
+}
+
+// DocumentOptionsOf - Document options of configuration, defaults of Configuration if not implemented
+func DocumentOptionsOf(config interfaces.ConfigurationInterface) interfaces.DocumentConfigurationInterface {
+	if options, ok := config.(interfaces.DocumentConfigurationInterface); ok {
+		return options
+	}
+	return Configuration{}
+}
+
+// ThrottleStrategyOptionsOf - Throttle strategy options of configuration, defaults of Configuration if not implemented
+func ThrottleStrategyOptionsOf(config interfaces.ConfigurationInterface) interfaces.ThrottleStrategyConfigurationInterface {
+	if options, ok := config.(interfaces.ThrottleStrategyConfigurationInterface); ok {
+		return options
+	}
+	return Configuration{}
+}
+
+// DebounceOptionsOf - Debounce options of configuration, not debounced if not implemented
+func DebounceOptionsOf(config interfaces.ConfigurationInterface) interfaces.DebounceConfigurationInterface {
+	if options, ok := config.(interfaces.DebounceConfigurationInterface); ok {
+		return options
+	}
+	return Configuration{}
+}
+
+// ThrottleLeadingOf - Check if first event in throttle period is sent immediately, true if not implemented
+func ThrottleLeadingOf(config interfaces.ConfigurationInterface) bool {
+	if options, ok := config.(interfaces.ThrottleLeadingConfigurationInterface); ok {
+		return options.GetThrottleLeading()
+	}
+	return true
+}
+
+// ThrottleKeyOf - Throttle key expression of configuration, empty if not implemented
+func ThrottleKeyOf(config interfaces.ConfigurationInterface) string {
+	if options, ok := config.(interfaces.ThrottleKeyConfigurationInterface); ok {
+		return options.GetThrottleKey()
+	}
+	return ""
+}
+
+// ThrottledPayloadsOptionsOf - Options of throttled payloads of configuration, defaults of Configuration if not implemented
+func ThrottledPayloadsOptionsOf(config interfaces.ConfigurationInterface) interfaces.ThrottledPayloadsConfigurationInterface {
+	if options, ok := config.(interfaces.ThrottledPayloadsConfigurationInterface); ok {
+		return options
+	}
+	return Configuration{}
+}
+
+// TenantOf - Tenant of configuration, empty for global configurations or if not implemented
+func TenantOf(config interfaces.ConfigurationInterface) string {
+	if options, ok := config.(interfaces.TenantConfigurationInterface); ok {
+		return options.GetTenant()
+	}
+	return ""
+}
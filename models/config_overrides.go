@@ -0,0 +1,216 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	interfaces "github.com/shoplineapp/captin/interfaces"
+	log "github.com/sirupsen/logrus"
+)
+
+// envOverrideFixed - Fields identifying hooks, which cannot be overridden
+var envOverrideFixed = map[string]bool{"name": true, "tenant": true}
+
+// ApplyEnvOverrides - Override fields of configurations with environment variables HOOK_<NAME>_<FIELD>,
+// e.g. HOOK_PRODUCT_SYNC_THROTTLE_LIMIT=10, and extras with HOOK_<NAME>_EXTRAS_<KEY>
+//
+// Values are parsed by type of fields, lists are comma separated, and invalid values are returned as ConfigurationErrors,
+// as well as variables overriding hooks of different names, e.g. HOOK_A_B_THROTTLE of hooks a_b and a with extras_b_throttle.
+func ApplyEnvOverrides(configs []Configuration) ([]Configuration, error) {
+	environ := os.Environ()
+	errors := ambiguousEnvOverrides(configs, environ)
+	result := []Configuration{}
+	for _, config := range configs {
+		overridden, configErrors := applyEnvOverrides(config, environ)
+		errors = append(errors, configErrors...)
+		result = append(result, overridden)
+	}
+	if len(errors) > 0 {
+		return nil, errors
+	}
+	return result, nil
+}
+
+func applyEnvOverrides(config Configuration, environ []string) (Configuration, ConfigurationErrors) {
+	errors := ConfigurationErrors{}
+	if config.Name == "" {
+		return config, errors
+	}
+
+	fields := reflect.ValueOf(&config).Elem()
+	for i := 0; i < fields.NumField(); i++ {
+		field := fields.Field(i)
+		name := configurationFieldName(fields.Type().Field(i))
+		if !isEnvOverridable(fields.Type().Field(i)) {
+			continue
+		}
+		envKey, _ := config.GetByEnv(name)
+		value, exists := os.LookupEnv(envKey)
+		if !exists {
+			continue
+		}
+		if err := setEnvOverride(field, value); err != nil {
+			errors = append(errors, ConfigurationError{
+				Hook:  strconv.Quote(config.Name),
+				Field: name,
+				Msg:   fmt.Sprintf("invalid %s \"%s\", %s", envKey, value, err),
+			})
+			continue
+		}
+		config.overrides = append(config.overrides, name)
+	}
+
+	prefix, _ := config.GetByEnv("extras_")
+	for _, entry := range environ {
+		if !strings.HasPrefix(entry, prefix) {
+			continue
+		}
+		pair := strings.SplitN(strings.TrimPrefix(entry, prefix), "=", 2)
+		if len(pair) != 2 || pair[0] == "" {
+			continue
+		}
+		extras := map[string]string{}
+		for key, value := range config.Extras {
+			extras[key] = value
+		}
+		key := strings.ToLower(pair[0])
+		extras[key] = pair[1]
+		config.Extras = extras
+		config.overrides = append(config.overrides, "extras."+key)
+	}
+	return config, errors
+}
+
+// isEnvOverridable - Exported fields other than maps and fields identifying hooks can be overridden
+func isEnvOverridable(field reflect.StructField) bool {
+	return field.PkgPath == "" && field.Type.Kind() != reflect.Map && !envOverrideFixed[configurationFieldName(field)]
+}
+
+// envOverrideOf - Field of hook overridden by environment variable, extras.<key> for extras, empty if none
+func envOverrideOf(config Configuration, envKey string) string {
+	if config.Name == "" {
+		return ""
+	}
+	if prefix, _ := config.GetByEnv("extras_"); strings.HasPrefix(envKey, prefix) && len(envKey) > len(prefix) {
+		return "extras." + strings.ToLower(strings.TrimPrefix(envKey, prefix))
+	}
+	fields := reflect.TypeOf(config)
+	for i := 0; i < fields.NumField(); i++ {
+		name := configurationFieldName(fields.Field(i))
+		if key, _ := config.GetByEnv(name); key == envKey && isEnvOverridable(fields.Field(i)) {
+			return name
+		}
+	}
+	return ""
+}
+
+// ambiguousEnvOverrides - Errors of environment variables overriding fields of hooks of different names
+func ambiguousEnvOverrides(configs []Configuration, environ []string) ConfigurationErrors {
+	errors := ConfigurationErrors{}
+	for _, entry := range environ {
+		envKey := strings.SplitN(entry, "=", 2)[0]
+		if !strings.HasPrefix(envKey, "HOOK_") {
+			continue
+		}
+		var first Configuration
+		var firstField string
+		for _, config := range configs {
+			field := envOverrideOf(config, envKey)
+			if field == "" {
+				continue
+			}
+			if firstField == "" {
+				first, firstField = config, field
+			} else if config.Name != first.Name {
+				errors = append(errors, ConfigurationError{
+					Hook:  strconv.Quote(first.Name),
+					Field: firstField,
+					Msg:   fmt.Sprintf("%s is ambiguous, it also overrides %s of hook \"%s\"", envKey, field, config.Name),
+				})
+				break
+			}
+		}
+	}
+	return errors
+}
+
+// setEnvOverride - Set field to value of environment variable parsed by type of field
+func setEnvOverride(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int:
+		parsed, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("expected integer")
+		}
+		field.SetInt(int64(parsed))
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("expected true or false")
+		}
+		field.SetBool(parsed)
+	case reflect.Ptr:
+		parsed := reflect.New(field.Type().Elem())
+		if err := setEnvOverride(parsed.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(parsed)
+	case reflect.Slice:
+		items := []string{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("field cannot be overridden")
+	}
+	return nil
+}
+
+// GetOverrides - Get fields overridden by environment variables when configuration was loaded
+func (c Configuration) GetOverrides() []string {
+	return c.overrides
+}
+
+// EffectiveFields - Fields of configuration which are set, by their names in configuration files, with secrets redacted
+func (c Configuration) EffectiveFields() map[string]interface{} {
+	result := map[string]interface{}{}
+	fields := reflect.ValueOf(c.Redacted())
+	for i := 0; i < fields.NumField(); i++ {
+		field := fields.Field(i)
+		structField := fields.Type().Field(i)
+		if structField.PkgPath != "" || field.IsZero() {
+			continue
+		}
+		if field.Kind() == reflect.Ptr {
+			field = field.Elem()
+		}
+		result[configurationFieldName(structField)] = field.Interface()
+	}
+	return result
+}
+
+// LogEffectiveConfigurations - Log effective configuration of every hook with fields overridden by environment variables
+func LogEffectiveConfigurations(configs []interfaces.ConfigurationInterface) {
+	for _, config := range configs {
+		fields := log.Fields{"hook": config.GetName()}
+		if tenant := TenantOf(config); tenant != "" {
+			fields["tenant"] = tenant
+		}
+		if c, ok := config.(Configuration); ok {
+			overrides := append([]string{}, c.GetOverrides()...)
+			sort.Strings(overrides)
+			fields["config"] = c.EffectiveFields()
+			fields["overrides"] = overrides
+		}
+		configLogger.WithFields(fields).Info("Effective configuration")
+	}
+}
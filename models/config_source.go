@@ -0,0 +1,52 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	interfaces "github.com/shoplineapp/captin/interfaces"
+)
+
+// ErrConfigurationNotModified - Document of configuration source is unchanged since last fetch
+var ErrConfigurationNotModified = errors.New("configuration not modified")
+
+// StoreConfigSource - Configuration document stored as value of key in store
+type StoreConfigSource struct {
+	interfaces.ConfigSourceInterface
+
+	store   interfaces.StoreInterface
+	key     string
+	last    string
+	fetched string
+}
+
+// NewStoreConfigSource - Create source of configuration document at key of store, format is given by extension of key
+func NewStoreConfigSource(store interfaces.StoreInterface, key string) *StoreConfigSource {
+	return &StoreConfigSource{store: store, key: key}
+}
+
+// Name - Key of document
+func (s *StoreConfigSource) Name() string {
+	return s.key
+}
+
+// Fetch - Get document from store, ErrConfigurationNotModified if value is unchanged
+func (s *StoreConfigSource) Fetch() ([]byte, error) {
+	value, exists, _, err := s.store.Get(s.key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("configuration key %s does not exist", s.key)
+	}
+	if value == s.last {
+		return nil, ErrConfigurationNotModified
+	}
+	s.fetched = value
+	return []byte(value), nil
+}
+
+// Commit - Keep value of last Fetch as unchanged
+func (s *StoreConfigSource) Commit() {
+	s.last = s.fetched
+}
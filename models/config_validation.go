@@ -0,0 +1,190 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/robertkrimen/otto/parser"
+	interfaces "github.com/shoplineapp/captin/interfaces"
+	helpers "github.com/shoplineapp/captin/internal/helpers"
+)
+
+var throttleStrategies = []string{ThrottleStrategyPeriod, ThrottleStrategyTokenBucket, ThrottleStrategySlidingWindow}
+
+var throttledPayloadsMerges = []string{
+	ThrottledPayloadsMergeNone,
+	ThrottledPayloadsMergeDeep,
+	ThrottledPayloadsMergeUnion,
+	ThrottledPayloadsMergeKeepFirst,
+	ThrottledPayloadsMergeKeepLast,
+}
+
+var documentPolicies = []string{DocumentPolicyError, DocumentPolicySkip, DocumentPolicySend}
+
+// ConfigurationError - Invalid field of hook
+type ConfigurationError struct {
+	Hook  string
+	Field string
+	Msg   string
+}
+
+func (e ConfigurationError) Error() string {
+	return fmt.Sprintf("hook %s: %s: %s", e.Hook, e.Field, e.Msg)
+}
+
+// ConfigurationErrors - All problems found in configurations
+type ConfigurationErrors []ConfigurationError
+
+func (e ConfigurationErrors) Error() string {
+	messages := []string{}
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// ConfigurationRules - Keys known by Captin, unchecked when nil
+type ConfigurationRules struct {
+	Senders        []string
+	DocumentStores []string
+	// Schemas - Schemas referred by payload_schema
+	Schemas interfaces.SchemaRegistryInterface
+}
+
+// ValidateConfigurations - Validate every hook, return ConfigurationErrors with all problems found or nil
+func ValidateConfigurations(configs []interfaces.ConfigurationInterface, rules ConfigurationRules) error {
+	errors := ConfigurationErrors{}
+	for i, config := range configs {
+		errors = append(errors, validateConfiguration(i, config, rules)...)
+	}
+	if len(errors) == 0 {
+		return nil
+	}
+	return errors
+}
+
+func validateConfiguration(index int, config interfaces.ConfigurationInterface, rules ConfigurationRules) ConfigurationErrors {
+	hook := strconv.Quote(config.GetName())
+	if config.GetName() == "" {
+		hook = fmt.Sprintf("#%d", index)
+	}
+	throttle, debounce := ThrottleStrategyOptionsOf(config), DebounceOptionsOf(config)
+	throttledPayloads, document := ThrottledPayloadsOptionsOf(config), DocumentOptionsOf(config)
+	errors := ConfigurationErrors{}
+	invalid := func(field string, msg string) {
+		errors = append(errors, ConfigurationError{Hook: hook, Field: field, Msg: msg})
+	}
+
+	if config.GetName() == "" {
+		invalid("name", "is required")
+	}
+	if len(config.GetActions()) == 0 {
+		invalid("actions", "is required")
+	}
+	for _, action := range config.GetActions() {
+		if IsActionPattern(action) {
+			if _, err := parseActionPattern(action); err != nil {
+				invalid("actions", err.Error())
+			}
+		}
+	}
+
+	timeValues := []struct {
+		field string
+		value string
+	}{
+		{"throttle", config.GetThrottle()},
+		{"delay", config.GetDelay()},
+		{"debounce", debounce.GetDebounce()},
+		{"max_wait", debounce.GetMaxWait()},
+		{"document_timeout", document.GetDocumentTimeout()},
+	}
+	for _, timeValue := range timeValues {
+		if _, err := ParseTimeValue(timeValue.value); err != nil {
+			invalid(timeValue.field, err.Error())
+		}
+	}
+
+	// Retry backoff is comma separated seconds
+	for _, seconds := range trimArray(config.GetRetryBackoff()) {
+		if value, err := strconv.ParseInt(strings.TrimSpace(seconds), 10, 64); err != nil || value < 0 {
+			invalid("retry_backoff", fmt.Sprintf("invalid seconds \"%s\"", seconds))
+		}
+	}
+
+	if !contains(throttleStrategies, throttle.GetThrottleStrategy()) {
+		invalid("throttle_strategy", fmt.Sprintf("unknown strategy \"%s\"", throttle.GetThrottleStrategy()))
+	}
+	if !contains(throttledPayloadsMerges, throttledPayloads.GetThrottledPayloadsMerge()) {
+		invalid("throttled_payloads_merge", fmt.Sprintf("unknown strategy \"%s\"", throttledPayloads.GetThrottledPayloadsMerge()))
+	}
+	if expression := ThrottleKeyOf(config); expression != "" {
+		if _, err := ParseThrottleKey(expression); err != nil {
+			invalid("throttle_key", err.Error())
+		}
+	}
+	if !contains(documentPolicies, document.GetOnDocumentError()) {
+		invalid("on_document_error", fmt.Sprintf("unknown policy \"%s\"", document.GetOnDocumentError()))
+	}
+	if !contains(documentPolicies, document.GetOnDocumentNotFound()) {
+		invalid("on_document_not_found", fmt.Sprintf("unknown policy \"%s\"", document.GetOnDocumentNotFound()))
+	}
+
+	attrs := []struct {
+		field  string
+		values []string
+	}{
+		{"include_payload_attrs", config.GetIncludePayloadAttrs()},
+		{"exclude_payload_attrs", config.GetExcludePayloadAttrs()},
+		{"include_document_attrs", config.GetIncludeDocumentAttrs()},
+		{"exclude_document_attrs", config.GetExcludeDocumentAttrs()},
+	}
+	for _, attr := range attrs {
+		if err := helpers.ValidateFields(attr.values); err != nil {
+			invalid(attr.field, err.Error())
+		}
+	}
+	if schema := PayloadSchemaOf(config); schema != "" && rules.Schemas != nil && !rules.Schemas.Has(schema) {
+		invalid("payload_schema", fmt.Sprintf("unknown schema \"%s\"", schema))
+	}
+
+	if script := config.GetValidate(); script != "" {
+		if _, err := parser.ParseFile(nil, "", script, 0); err != nil {
+			invalid("validate", err.Error())
+		}
+	}
+
+	// urls are never put in errors, as they may contain secrets resolved
+	if callbackURL := config.GetCallbackURL(); callbackURL != "" {
+		if parsed, err := url.Parse(callbackURL); err != nil {
+			invalid("callback_url", "invalid url")
+		} else if parsed.Scheme == "" || parsed.Host == "" {
+			invalid("callback_url", "invalid url, scheme and host are required")
+		}
+	}
+
+	// Events are sent with http sender by default
+	sender := config.GetSender()
+	if sender == "" {
+		sender = "http"
+	}
+	if rules.Senders != nil && !contains(rules.Senders, sender) {
+		invalid("sender", fmt.Sprintf("unknown sender \"%s\"", sender))
+	}
+	if store := config.GetDocumentStore(); store != "" && rules.DocumentStores != nil && !contains(rules.DocumentStores, store) {
+		invalid("document_store", fmt.Sprintf("unknown document store \"%s\"", store))
+	}
+
+	return errors
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
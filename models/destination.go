@@ -1,6 +1,8 @@
 package models
 
 import (
+	"encoding/json"
+
 	interfaces "github.com/shoplineapp/captin/interfaces"
 	"os"
 	"fmt"
@@ -38,11 +40,52 @@ func (d Destination) GetCallbackURL() string {
 	return d.Config.GetCallbackURL()
 }
 
+// GetSqsSenderConfig - Get config of SQS sender from env, or extras of hook like sqs_sender_aws_secret_access_key,
+// which may reference secrets
 func (d Destination) GetSqsSenderConfig(key string) string {
 	_, value := d.Config.GetByEnv(fmt.Sprintf("SQS_SENDER_%s", key))
+	if len(value) == 0 {
+		value = d.Config.GetExtras()[fmt.Sprintf("sqs_sender_%s", strings.ToLower(key))]
+	}
 	return value
 }
 
+// Redacted - Copy of destination with secrets of configuration redacted
+func (d Destination) Redacted() Destination {
+	config, ok := d.Config.(Configuration)
+	if !ok {
+		return d
+	}
+	d.Config = config.Redacted()
+	for _, secret := range config.secrets {
+		d.callbackUrl = strings.ReplaceAll(d.callbackUrl, secret, RedactedValue)
+	}
+	return d
+}
+
+// Redact - Replace secrets of configuration with RedactedValue in value, e.g. in errors of senders
+func (d Destination) Redact(value string) string {
+	if config, ok := d.Config.(Configuration); ok {
+		return config.Redact(value)
+	}
+	return value
+}
+
+// String - Format destination with secrets redacted
+func (d Destination) String() string {
+	redacted := d.Redacted()
+	return fmt.Sprintf("{Config:%v callbackUrl:%s}", redacted.Config, redacted.callbackUrl)
+}
+
+// MarshalJSON - Encode destination with secrets redacted, e.g. in JSON logs
+func (d Destination) MarshalJSON() ([]byte, error) {
+	redacted := d.Redacted()
+	return json.Marshal(map[string]interface{}{
+		"config":       redacted.Config,
+		"callback_url": redacted.GetCallbackURL(),
+	})
+}
+
 func (d Destination) GetDocumentStore() string {
 	_, value := d.Config.GetByEnv("document_store")
 	if len(value) == 0 {
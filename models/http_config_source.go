@@ -0,0 +1,143 @@
+package models
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	interfaces "github.com/shoplineapp/captin/interfaces"
+)
+
+// SignatureHeader - Header of HMAC-SHA256 signature of configuration document, in hex with optional sha256= prefix
+const SignatureHeader = "X-Captin-Signature"
+
+// HTTPConfigSource - Configuration document fetched from HTTP endpoint
+//
+// Requests are conditional with ETag of the last response, and documents are verified with signature
+// when secret is set.
+type HTTPConfigSource struct {
+	interfaces.ConfigSourceInterface
+
+	url     string
+	name    string
+	client  *http.Client
+	headers map[string]string
+	secret  []byte
+	etag    string
+	last    []byte
+
+	fetchedETag string
+	fetched     []byte
+}
+
+// NewHTTPConfigSource - Create source of configuration document at url
+func NewHTTPConfigSource(url string) *HTTPConfigSource {
+	return &HTTPConfigSource{
+		url:     url,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		headers: map[string]string{},
+	}
+}
+
+// SetClient - Set client of requests
+func (s *HTTPConfigSource) SetClient(client *http.Client) {
+	s.client = client
+}
+
+// SetHeader - Set header of requests, e.g. Authorization
+func (s *HTTPConfigSource) SetHeader(key string, value string) {
+	s.headers[key] = value
+}
+
+// SetSecret - Set secret for verifying signature of documents, unsigned documents are rejected
+func (s *HTTPConfigSource) SetSecret(secret string) {
+	s.secret = []byte(secret)
+}
+
+// SetName - Set name of document for its format, when url has no extension, e.g. hooks.yaml
+func (s *HTTPConfigSource) SetName(name string) {
+	s.name = name
+}
+
+// Name - Name of document, default to last segment of url path
+func (s *HTTPConfigSource) Name() string {
+	if s.name != "" {
+		return s.name
+	}
+	if parsed, err := url.Parse(s.url); err == nil && parsed.Path != "" {
+		return path.Base(parsed.Path)
+	}
+	return s.url
+}
+
+// Fetch - Request document, ErrConfigurationNotModified if ETag or document is unchanged
+func (s *HTTPConfigSource) Fetch() ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range s.headers {
+		req.Header.Set(key, value)
+	}
+	if s.etag != "" {
+		req.Header.Set("If-None-Match", s.etag)
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotModified {
+		return nil, ErrConfigurationNotModified
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch configurations from %s, status %d", s.url, res.StatusCode)
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verify(data, res.Header.Get(SignatureHeader)); err != nil {
+		return nil, err
+	}
+
+	etag := res.Header.Get("ETag")
+	if s.last != nil && bytes.Equal(data, s.last) {
+		s.etag = etag
+		return nil, ErrConfigurationNotModified
+	}
+	s.fetchedETag, s.fetched = etag, data
+	return data, nil
+}
+
+// Commit - Keep ETag and document of last Fetch as unchanged, so that requests are conditional with them
+func (s *HTTPConfigSource) Commit() {
+	s.etag, s.last = s.fetchedETag, s.fetched
+}
+
+// verify - Check signature of document with secret, if set
+func (s *HTTPConfigSource) verify(data []byte, signature string) error {
+	if len(s.secret) == 0 {
+		return nil
+	}
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || signature == "" {
+		return fmt.Errorf("invalid signature of configurations from %s", s.url)
+	}
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(data)
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return fmt.Errorf("invalid signature of configurations from %s", s.url)
+	}
+	return nil
+}
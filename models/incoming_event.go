@@ -25,6 +25,7 @@ type IncomingEvent struct {
 	// Optional with payload, Captin will try to fetch the document from the default database
 	TargetType         string                   `json:"target_type"`
 	TargetId           string                   `json:"target_id"`
+	Tenant             string                   `json:"tenant,omitempty"` // Optional, resolved from tenant path of Captin if not given
 	TargetDocument     map[string]interface{}   `json:"target_document,omitempty"`
 	ThrottledDocuments []map[string]interface{} `json:"throttled_documents,omitempty"` // for response only
 }
@@ -41,13 +42,22 @@ func NewIncomingEvent(data []byte) IncomingEvent {
 }
 
 func (e IncomingEvent) GetTraceInfo() map[string]interface{} {
-	return map[string]interface{}{
+	info := map[string]interface{}{
 		"trace_id": e.TraceId,
 		"key":      e.Key,
 		"source":   e.Source,
 		"type":     e.TargetType,
 		"id":       e.TargetId,
 	}
+	if e.Tenant != "" {
+		info["tenant"] = e.Tenant
+	}
+	return info
+}
+
+// Field - Get value of field in throttle_key format, e.g. source or payload.shop.id, empty if not found
+func (e IncomingEvent) Field(path string) string {
+	return throttleKeyValue(e, path)
 }
 
 func (e IncomingEvent) GetControl() map[string]interface{} {
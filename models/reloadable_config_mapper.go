@@ -0,0 +1,345 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	interfaces "github.com/shoplineapp/captin/interfaces"
+	schemas "github.com/shoplineapp/captin/schemas"
+	log "github.com/sirupsen/logrus"
+)
+
+var rcmLogger = log.WithFields(log.Fields{"class": "ReloadableConfigurationMapper"})
+
+// reloadDelay - Wait for burst of file events, e.g. editors writing and renaming files, before reloading
+const reloadDelay = 100 * time.Millisecond
+
+// ConfigurationValidator - Validate configurations before they are used, rejecting the whole set on error
+type ConfigurationValidator func(configs []interfaces.ConfigurationInterface) error
+
+// ConfigurationDiff - Names of hooks added, removed and changed on reload
+type ConfigurationDiff struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+// IsEmpty - Check if there is no change
+func (d ConfigurationDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// ReloadableConfigurationMapper - Config mapper reloading configurations of path without restart
+//
+// Reloaded configurations are validated and swapped atomically with schemas of path, while events being dispatched keep
+// the configurations they got from ConfigsForKey. Invalid configurations are logged and the current ones kept.
+type ReloadableConfigurationMapper struct {
+	interfaces.ReloadableConfigMapperInterface
+	interfaces.TenantConfigMapperInterface
+	interfaces.SchemaConfigMapperInterface
+
+	path      string
+	source    interfaces.ConfigSourceInterface
+	load      func() (*ConfigurationMapper, error)
+	swapped   func()
+	current   atomic.Value
+	validator ConfigurationValidator
+	lock      sync.Mutex
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewReloadableConfigurationMapper - Load and validate configurations from file or directory at path,
+// with schemas in the schemas directory alongside the file or inside the directory
+func NewReloadableConfigurationMapper(path string) (*ReloadableConfigurationMapper, error) {
+	m := &ReloadableConfigurationMapper{
+		path: path,
+		load: func() (*ConfigurationMapper, error) {
+			return loadFileConfigurationMapper(path)
+		},
+		stop: make(chan struct{}),
+	}
+	mapper, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+	m.current.Store(mapper)
+	return m, nil
+}
+
+// loadFileConfigurationMapper - Load valid configurations of path with their schemas,
+// payload_schema of hooks are checked against the schemas if there are any
+func loadFileConfigurationMapper(path string) (*ConfigurationMapper, error) {
+	raw, files, err := loadConfigurationFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	registry, err := schemas.NewSchemaRegistryForConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	rules := ConfigurationRules{}
+	if registry != nil {
+		rules.Schemas = registry
+	}
+	configs, err := validConfigurations(raw, rules)
+	if err != nil {
+		return nil, err
+	}
+
+	mapper := NewConfigurationMapper(configs)
+	mapper.files = files
+	mapper.schemaRegistry = rules.Schemas
+	return mapper, nil
+}
+
+// SetValidator - Set validator of configurations on reload
+func (m *ReloadableConfigurationMapper) SetValidator(validator ConfigurationValidator) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.validator = validator
+}
+
+// Current - Get mapper of current configurations
+func (m *ReloadableConfigurationMapper) Current() *ConfigurationMapper {
+	return m.current.Load().(*ConfigurationMapper)
+}
+
+// ConfigsForKey - Get current configurations for event key
+func (m *ReloadableConfigurationMapper) ConfigsForKey(eventKey string) []interfaces.ConfigurationInterface {
+	return m.Current().ConfigsForKey(eventKey)
+}
+
+// Snapshot - Get current configurations and schemas, unchanged by later reloads
+func (m *ReloadableConfigurationMapper) Snapshot() interfaces.ConfigMapperInterface {
+	return m.Current()
+}
+
+// ConfigsForTenantKey - Get current global configurations and configurations of tenant for event key
+func (m *ReloadableConfigurationMapper) ConfigsForTenantKey(tenant string, eventKey string) []interfaces.ConfigurationInterface {
+	return m.Current().ConfigsForTenantKey(tenant, eventKey)
+}
+
+// SchemaRegistry - Get schemas loaded with current configurations, nil if there is none
+func (m *ReloadableConfigurationMapper) SchemaRegistry() interfaces.SchemaRegistryInterface {
+	return m.Current().SchemaRegistry()
+}
+
+// Reload - Load, validate and swap configurations and schemas, keeping current ones on error
+func (m *ReloadableConfigurationMapper) Reload() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	pathLogger := rcmLogger.WithFields(log.Fields{"path": m.path})
+	mapper, err := m.load()
+	if err == ErrConfigurationNotModified {
+		pathLogger.Debug("Configurations not modified")
+		return nil
+	}
+	if err == nil && m.validator != nil {
+		err = m.validator(mapper.Configs())
+	}
+	if err != nil {
+		pathLogger.WithFields(log.Fields{"error": err}).Error("Failed to reload configurations, keeping current ones")
+		return err
+	}
+
+	diff := DiffConfigurations(m.Current().Configs(), mapper.Configs())
+	m.current.Store(mapper)
+	if m.swapped != nil {
+		m.swapped()
+	}
+	pathLogger.WithFields(log.Fields{
+		"added":   diff.Added,
+		"removed": diff.Removed,
+		"changed": diff.Changed,
+	}).Info("Configurations reloaded")
+	return nil
+}
+
+// Watch - Reload on changes of configuration files, included files and schemas, and on SIGHUP until Close
+func (m *ReloadableConfigurationMapper) Watch() error {
+	if m.source != nil {
+		return fmt.Errorf("configurations of %s are not local files, use Poll", m.path)
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	for _, dir := range watchDirs(m.path) {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return err
+		}
+	}
+	m.watchLoaded(watcher)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go m.watch(watcher, signals)
+	return nil
+}
+
+// watchLoaded - Watch schemas and directories of current files, which may be included from outside of path
+func (m *ReloadableConfigurationMapper) watchLoaded(watcher *fsnotify.Watcher) {
+	dirs := []string{schemas.DirForConfig(m.path)}
+	for _, file := range m.Current().files {
+		dirs = append(dirs, filepath.Dir(file))
+	}
+	for _, dir := range dirs {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			rcmLogger.WithFields(log.Fields{"path": m.path, "dir": dir, "error": err}).Error("Failed to watch directory")
+		}
+	}
+}
+
+func (m *ReloadableConfigurationMapper) watch(watcher *fsnotify.Watcher, signals chan os.Signal) {
+	defer watcher.Close()
+	defer signal.Stop(signals)
+	reloadAndWatch := func() {
+		if m.Reload() == nil {
+			m.watchLoaded(watcher)
+		}
+	}
+
+	var reload <-chan time.Time
+	for {
+		select {
+		case <-m.stop:
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if info, err := os.Stat(event.Name); err == nil && info.IsDir() && event.Op&fsnotify.Create != 0 {
+				watcher.Add(event.Name)
+			}
+			if configExtensions[strings.ToLower(filepath.Ext(event.Name))] {
+				reload = time.After(reloadDelay)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			rcmLogger.WithFields(log.Fields{"path": m.path, "error": err}).Error("Failed to watch configurations")
+		case <-signals:
+			rcmLogger.WithFields(log.Fields{"path": m.path}).Info("Reloading configurations on SIGHUP")
+			reloadAndWatch()
+		case <-reload:
+			reload = nil
+			reloadAndWatch()
+		}
+	}
+}
+
+// Poll - Reload every interval until Close, for sources without change notification
+func (m *ReloadableConfigurationMapper) Poll(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				m.Reload()
+			}
+		}
+	}()
+}
+
+// Close - Stop watching and polling configurations
+func (m *ReloadableConfigurationMapper) Close() error {
+	m.closeOnce.Do(func() {
+		close(m.stop)
+	})
+	return nil
+}
+
+// DiffConfigurations - Compare configurations by hook name, or id for hooks without name, prefixed by tenant
+func DiffConfigurations(before []interfaces.ConfigurationInterface, after []interfaces.ConfigurationInterface) ConfigurationDiff {
+	previous := map[string]interfaces.ConfigurationInterface{}
+	for _, config := range before {
+		previous[hookKey(config)] = config
+	}
+
+	diff := ConfigurationDiff{Added: []string{}, Removed: []string{}, Changed: []string{}}
+	current := map[string]bool{}
+	for _, config := range after {
+		key := hookKey(config)
+		current[key] = true
+		if old, exists := previous[key]; !exists {
+			diff.Added = append(diff.Added, key)
+		} else if !configurationsEqual(old, config) {
+			diff.Changed = append(diff.Changed, key)
+		}
+	}
+	for key := range previous {
+		if !current[key] {
+			diff.Removed = append(diff.Removed, key)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff
+}
+
+// configurationsEqual - Compare exported fields of configurations, ignoring secrets and overrides kept for logging
+func configurationsEqual(a interfaces.ConfigurationInterface, b interfaces.ConfigurationInterface) bool {
+	ca, okA := a.(Configuration)
+	cb, okB := b.(Configuration)
+	if !okA || !okB {
+		return reflect.DeepEqual(a, b)
+	}
+	va, vb := reflect.ValueOf(ca), reflect.ValueOf(cb)
+	for i := 0; i < va.NumField(); i++ {
+		if va.Type().Field(i).PkgPath != "" {
+			continue
+		}
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			return false
+		}
+	}
+	return true
+}
+
+func hookKey(config interfaces.ConfigurationInterface) string {
+	key := config.GetName()
+	if key == "" {
+		key = config.GetConfigID()
+	}
+	if tenant := TenantOf(config); tenant != "" {
+		return tenant + ":" + key
+	}
+	return key
+}
+
+// watchDirs - Directories to watch for path, parent directory of files as editors may replace them on save
+func watchDirs(path string) []string {
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		return []string{filepath.Dir(path)}
+	}
+	dirs := []string{}
+	filepath.Walk(path, func(dir string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() {
+			dirs = append(dirs, dir)
+		}
+		return nil
+	})
+	return dirs
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	interfaces "github.com/shoplineapp/captin/interfaces"
+	log "github.com/sirupsen/logrus"
+)
+
+// NewRemoteConfigurationMapper - Load and validate configurations from source, reloaded with Reload or Poll
+//
+// Configurations swapped are kept at cachePath as last known good configurations, which are loaded when source
+// is unavailable or invalid on start. Nothing is kept if cachePath is empty.
+func NewRemoteConfigurationMapper(source interfaces.ConfigSourceInterface, cachePath string) (*ReloadableConfigurationMapper, error) {
+	var fetched []byte
+	m := &ReloadableConfigurationMapper{
+		path:   source.Name(),
+		source: source,
+		load: func() (*ConfigurationMapper, error) {
+			data, err := source.Fetch()
+			if err != nil {
+				return nil, err
+			}
+			configs, err := loadValidConfigurationsFromData(source.Name(), data)
+			if err != nil {
+				return nil, err
+			}
+			fetched = data
+			return NewConfigurationMapper(configs), nil
+		},
+		stop: make(chan struct{}),
+	}
+	m.swapped = func() {
+		if committable, ok := source.(interfaces.CommittableConfigSourceInterface); ok {
+			committable.Commit()
+		}
+		if cachePath == "" {
+			return
+		}
+		if err := writeFileAtomic(cachePath, fetched); err != nil {
+			rcmLogger.WithFields(log.Fields{"path": m.path, "cache_path": cachePath, "error": err}).Error("Failed to keep last known good configurations")
+		}
+	}
+
+	mapper, err := m.load()
+	if err == nil {
+		m.swapped()
+	} else {
+		if cachePath == "" {
+			return nil, err
+		}
+		cached, cacheErr := ioutil.ReadFile(cachePath)
+		if cacheErr != nil {
+			return nil, err
+		}
+		rcmLogger.WithFields(log.Fields{"path": m.path, "cache_path": cachePath, "error": err}).Warn("Failed to load configurations, using last known good configurations")
+		configs, err := loadValidConfigurationsFromData(source.Name(), cached)
+		if err != nil {
+			return nil, err
+		}
+		mapper = NewConfigurationMapper(configs)
+	}
+	m.current.Store(mapper)
+	return m, nil
+}
+
+func loadValidConfigurationsFromData(name string, data []byte) ([]interfaces.ConfigurationInterface, error) {
+	raw, err := loadConfigurationsFromData(name, data)
+	if err != nil {
+		return nil, err
+	}
+	return validConfigurations(raw, ConfigurationRules{})
+}
+
+// writeFileAtomic - Write file by renaming temporary file, so that the file is never partially written
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
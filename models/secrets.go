@@ -0,0 +1,180 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	interfaces "github.com/shoplineapp/captin/interfaces"
+)
+
+// RedactedValue - Placeholder of secrets in logged configurations and destinations
+const RedactedValue = "[REDACTED]"
+
+// EnvSecretProvider - Secrets from environment variables, e.g. env:SQS_SECRET_KEY
+type EnvSecretProvider struct {
+	interfaces.SecretProviderInterface
+}
+
+// Resolve - Get value of environment variable, which must be set
+func (p EnvSecretProvider) Resolve(name string) (string, error) {
+	value, exists := os.LookupEnv(name)
+	if !exists {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+// FileSecretProvider - Secrets from files, e.g. file:/run/secrets/sqs_secret_key
+type FileSecretProvider struct {
+	interfaces.SecretProviderInterface
+}
+
+// Resolve - Get content of file without trailing newline
+func (p FileSecretProvider) Resolve(name string) (string, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// SecretResolver - Resolve references to secrets in configurations, as <scheme>:<name> of registered providers
+//
+// Values with other schemes, e.g. http://, are kept as is.
+type SecretResolver struct {
+	providers map[string]interfaces.SecretProviderInterface
+}
+
+// DefaultSecretResolver - Resolver of configurations loaded, with env and file providers
+var DefaultSecretResolver = NewSecretResolver()
+
+// NewSecretResolver - Create SecretResolver with env and file providers
+func NewSecretResolver() *SecretResolver {
+	return &SecretResolver{
+		providers: map[string]interfaces.SecretProviderInterface{
+			"env":  EnvSecretProvider{},
+			"file": FileSecretProvider{},
+		},
+	}
+}
+
+// SetProvider - Set provider of secrets referenced with scheme
+func (r *SecretResolver) SetProvider(scheme string, provider interfaces.SecretProviderInterface) {
+	r.providers[scheme] = provider
+}
+
+// Resolve - Get secret referenced by value, and if value is a reference
+func (r *SecretResolver) Resolve(value string) (string, bool, error) {
+	i := strings.Index(value, ":")
+	if i <= 0 {
+		return value, false, nil
+	}
+	provider, exists := r.providers[value[:i]]
+	if !exists {
+		return value, false, nil
+	}
+	secret, err := provider.Resolve(value[i+1:])
+	return secret, true, err
+}
+
+// ResolveConfigurations - Resolve references in string fields and extras of configurations,
+// returning ConfigurationErrors with all references failed
+func (r *SecretResolver) ResolveConfigurations(configs []Configuration) ([]Configuration, error) {
+	errors := ConfigurationErrors{}
+	result := []Configuration{}
+	for _, config := range configs {
+		resolved, configErrors := r.resolveConfiguration(config)
+		errors = append(errors, configErrors...)
+		result = append(result, resolved)
+	}
+	if len(errors) > 0 {
+		return nil, errors
+	}
+	return result, nil
+}
+
+func (r *SecretResolver) resolveConfiguration(config Configuration) (Configuration, ConfigurationErrors) {
+	errors := ConfigurationErrors{}
+	resolve := func(field string, value string) string {
+		secret, isReference, err := r.Resolve(value)
+		if err != nil {
+			errors = append(errors, ConfigurationError{Hook: strconv.Quote(config.Name), Field: field, Msg: err.Error()})
+			return value
+		}
+		if isReference && secret != "" {
+			config.secrets = append(config.secrets, secret)
+		}
+		return secret
+	}
+
+	fields := reflect.ValueOf(&config).Elem()
+	for i := 0; i < fields.NumField(); i++ {
+		field := fields.Field(i)
+		if field.Kind() != reflect.String || !field.CanSet() {
+			continue
+		}
+		field.SetString(resolve(configurationFieldName(fields.Type().Field(i)), field.String()))
+	}
+
+	if config.Extras != nil {
+		extras := map[string]string{}
+		for key, value := range config.Extras {
+			extras[key] = resolve("extras."+key, value)
+		}
+		config.Extras = extras
+	}
+	return config, errors
+}
+
+// Redact - Replace secrets resolved in configuration with RedactedValue in value, e.g. in errors of senders
+func (c Configuration) Redact(value string) string {
+	for _, secret := range c.secrets {
+		value = strings.ReplaceAll(value, secret, RedactedValue)
+	}
+	return value
+}
+
+// Redacted - Copy of configuration with secrets resolved replaced by RedactedValue
+func (c Configuration) Redacted() Configuration {
+	if len(c.secrets) == 0 {
+		return c
+	}
+
+	fields := reflect.ValueOf(&c).Elem()
+	for i := 0; i < fields.NumField(); i++ {
+		if field := fields.Field(i); field.Kind() == reflect.String && field.CanSet() {
+			field.SetString(c.Redact(field.String()))
+		}
+	}
+	extras := map[string]string{}
+	for key, value := range c.Extras {
+		extras[key] = c.Redact(value)
+	}
+	c.Extras = extras
+	c.secrets = nil
+	return c
+}
+
+// String - Format configuration with secrets redacted
+func (c Configuration) String() string {
+	type fields Configuration
+	return fmt.Sprintf("%+v", fields(c.Redacted()))
+}
+
+// MarshalJSON - Encode configuration with secrets redacted, e.g. in JSON logs
+func (c Configuration) MarshalJSON() ([]byte, error) {
+	type fields Configuration
+	return json.Marshal(fields(c.Redacted()))
+}
+
+func configurationFieldName(field reflect.StructField) string {
+	if name := strings.Split(field.Tag.Get("json"), ",")[0]; name != "" {
+		return name
+	}
+	return field.Name
+}
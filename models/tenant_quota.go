@@ -0,0 +1,9 @@
+package models
+
+import "time"
+
+// TenantQuota - Max number of events of tenant executed in any period
+type TenantQuota struct {
+	Limit  int
+	Period time.Duration
+}
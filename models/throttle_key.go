@@ -0,0 +1,147 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	interfaces "github.com/shoplineapp/captin/interfaces"
+	log "github.com/sirupsen/logrus"
+)
+
+var tkLogger = log.WithFields(log.Fields{"class": "ThrottleKey"})
+
+// Fields available in throttle_key, payload and control fields are given by path, e.g. payload.shop.id
+const (
+	ThrottleKeyEventKey   = "event_key"
+	ThrottleKeySource     = "source"
+	ThrottleKeyTargetType = "target_type"
+	ThrottleKeyTargetId   = "target_id"
+
+	throttleKeyPayloadPrefix = "payload."
+	throttleKeyControlPrefix = "control."
+)
+
+// ParseThrottleKey - Parse comma separated fields of throttle_key expression
+func ParseThrottleKey(expression string) ([]string, error) {
+	fields := []string{}
+	for _, field := range strings.Split(expression, ",") {
+		field = strings.TrimSpace(field)
+		switch {
+		case field == ThrottleKeyEventKey, field == ThrottleKeySource, field == ThrottleKeyTargetType, field == ThrottleKeyTargetId:
+		case strings.HasPrefix(field, throttleKeyPayloadPrefix) && len(field) > len(throttleKeyPayloadPrefix):
+		case strings.HasPrefix(field, throttleKeyControlPrefix) && len(field) > len(throttleKeyControlPrefix):
+		default:
+			return nil, fmt.Errorf("invalid throttle_key field \"%s\"", field)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// throttleKeyEscaper - Escape separators in parts of keys, so that different parts never compose the same key
+var throttleKeyEscaper = strings.NewReplacer(`\`, `\\`, ".", `\.`, ":", `\:`)
+
+// ThrottleKey - Key of event for throttling in destination, without prefix and suffix of DataKey
+//
+// Default to <event_key>.<hook name>.<target_id>, or <hook name>.<fields of throttle_key>, joined by dot with
+// dots in every part escaped. Keys of events of tenants are namespaced as <tenant>:<key>, so that tenants are throttled separately,
+// and keys of hooks of tenants as <tenant>::<key>, so that they never share keys with global hooks of the same name.
+func ThrottleKey(e IncomingEvent, config interfaces.ConfigurationInterface) string {
+	if tenant := TenantOf(config); tenant != "" {
+		return throttleKeyEscaper.Replace(tenant) + "::" + throttleKey(e, config)
+	}
+	if e.Tenant != "" {
+		return throttleKeyEscaper.Replace(e.Tenant) + ":" + throttleKey(e, config)
+	}
+	return throttleKey(e, config)
+}
+
+// DataKey - ThrottleKey of event in destination with prefix and suffix, for DataKey of stores
+//
+// Events other than IncomingEvent are keyed by their trace info.
+func DataKey(e interfaces.IncomingEventInterface, dest interfaces.DestinationInterface, prefix string, suffix string) string {
+	return prefix + ThrottleKey(incomingEventOf(e), dest.GetConfig()) + suffix
+}
+
+func incomingEventOf(e interfaces.IncomingEventInterface) IncomingEvent {
+	switch event := e.(type) {
+	case IncomingEvent:
+		return event
+	case *IncomingEvent:
+		return *event
+	}
+	info := e.GetTraceInfo()
+	field := func(key string) string {
+		value, _ := info[key].(string)
+		return value
+	}
+	return IncomingEvent{
+		Key:        field("key"),
+		Source:     field("source"),
+		TargetType: field("type"),
+		TargetId:   field("id"),
+		Tenant:     field("tenant"),
+		Control:    e.GetControl(),
+	}
+}
+
+func throttleKey(e IncomingEvent, config interfaces.ConfigurationInterface) string {
+	expression := ThrottleKeyOf(config)
+	if expression == "" {
+		return joinThrottleKey(e.Key, config.GetName(), e.TargetId)
+	}
+
+	fields, err := ParseThrottleKey(expression)
+	if err != nil {
+		tkLogger.WithFields(log.Fields{"hook_name": config.GetName(), "error": err}).Warn("Fallback to default throttle key")
+		return joinThrottleKey(e.Key, config.GetName(), e.TargetId)
+	}
+
+	parts := []string{config.GetName()}
+	for _, field := range fields {
+		parts = append(parts, throttleKeyValue(e, field))
+	}
+	return joinThrottleKey(parts...)
+}
+
+// joinThrottleKey - Join escaped parts of key by dot
+func joinThrottleKey(parts ...string) string {
+	escaped := make([]string, len(parts))
+	for i, part := range parts {
+		escaped[i] = throttleKeyEscaper.Replace(part)
+	}
+	return strings.Join(escaped, ".")
+}
+
+func throttleKeyValue(e IncomingEvent, field string) string {
+	switch {
+	case field == ThrottleKeyEventKey:
+		return e.Key
+	case field == ThrottleKeySource:
+		return e.Source
+	case field == ThrottleKeyTargetType:
+		return e.TargetType
+	case field == ThrottleKeyTargetId:
+		return e.TargetId
+	case strings.HasPrefix(field, throttleKeyPayloadPrefix):
+		return lookupPath(e.Payload, strings.TrimPrefix(field, throttleKeyPayloadPrefix))
+	case strings.HasPrefix(field, throttleKeyControlPrefix):
+		return lookupPath(e.Control, strings.TrimPrefix(field, throttleKeyControlPrefix))
+	}
+	return ""
+}
+
+// lookupPath - Get value in nested map by dot separated path, empty if not found
+func lookupPath(data map[string]interface{}, path string) string {
+	var current interface{} = data
+	for _, key := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		if current, ok = m[key]; !ok || current == nil {
+			return ""
+		}
+	}
+	return fmt.Sprint(current)
+}
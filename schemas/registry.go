@@ -0,0 +1,131 @@
+package schemas
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	interfaces "github.com/shoplineapp/captin/interfaces"
+	log "github.com/sirupsen/logrus"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var sLogger = log.WithFields(log.Fields{"class": "SchemaRegistry"})
+
+// DefaultDirName - Directory name of schemas placed alongside the configuration file
+const DefaultDirName = "schemas"
+
+// SchemaRegistry - JSON schemas registered by event key or schema name
+type SchemaRegistry struct {
+	interfaces.SchemaRegistryInterface
+
+	schemas map[string]*gojsonschema.Schema
+	lock    sync.RWMutex
+}
+
+// NewSchemaRegistry - Create empty SchemaRegistry
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{
+		schemas: map[string]*gojsonschema.Schema{},
+	}
+}
+
+// NewSchemaRegistryFromPath - Load every *.json file in directory as schema, named after the file without extension,
+// e.g. schemas/product.update.json is registered as "product.update"
+func NewSchemaRegistryFromPath(dir string) (*SchemaRegistry, error) {
+	r := NewSchemaRegistry()
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+		path, absErr := filepath.Abs(filepath.Join(dir, file.Name()))
+		if absErr != nil {
+			return nil, absErr
+		}
+		// Load by reference so that relative $ref between schema files are resolved
+		loader := gojsonschema.NewReferenceLoader("file://" + filepath.ToSlash(path))
+		if regErr := r.register(strings.TrimSuffix(file.Name(), ".json"), loader); regErr != nil {
+			return nil, fmt.Errorf("%s: %s", path, regErr)
+		}
+	}
+	sLogger.WithFields(log.Fields{"dir": dir, "count": len(r.schemas)}).Info("Schemas loaded")
+	return r, nil
+}
+
+// DirForConfig - Get the schema directory placed alongside the configuration file, or inside the configuration directory
+func DirForConfig(configPath string) string {
+	if info, err := os.Stat(configPath); err == nil && info.IsDir() {
+		return filepath.Join(configPath, DefaultDirName)
+	}
+	return filepath.Join(filepath.Dir(configPath), DefaultDirName)
+}
+
+// NewSchemaRegistryForConfig - Load schemas alongside the configuration file, return nil if there is no schema directory
+func NewSchemaRegistryForConfig(configPath string) (*SchemaRegistry, error) {
+	dir := DirForConfig(configPath)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, nil
+	}
+	return NewSchemaRegistryFromPath(dir)
+}
+
+// Register - Register schema in JSON string with given name
+func (r *SchemaRegistry) Register(name string, schema string) error {
+	return r.register(name, gojsonschema.NewStringLoader(schema))
+}
+
+func (r *SchemaRegistry) register(name string, loader gojsonschema.JSONLoader) error {
+	schema, err := gojsonschema.NewSchema(loader)
+	if err != nil {
+		return err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.schemas[name] = schema
+	return nil
+}
+
+// Has - Check if a schema is registered with given name
+func (r *SchemaRegistry) Has(name string) bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	_, ok := r.schemas[name]
+	return ok
+}
+
+// Validate - Validate document against schema with given name, return the list of violations
+func (r *SchemaRegistry) Validate(name string, document interface{}) ([]string, error) {
+	r.lock.RLock()
+	schema, ok := r.schemas[name]
+	r.lock.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("schema %s is not registered", name)
+	}
+
+	// Treat missing payload as empty object, events could come with target only
+	if m, isMap := document.(map[string]interface{}); document == nil || (isMap && m == nil) {
+		document = map[string]interface{}{}
+	}
+
+	result, err := schema.Validate(gojsonschema.NewGoLoader(document))
+	if err != nil {
+		return nil, err
+	}
+
+	violations := []string{}
+	for _, desc := range result.Errors() {
+		violations = append(violations, desc.String())
+	}
+	return violations, nil
+}
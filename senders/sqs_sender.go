@@ -35,7 +35,7 @@ func (s *SqsSender) SendEvent(ev interfaces.IncomingEventInterface, dv interface
 	d := dv.(models.Destination)
 
 	queueURL := d.GetCallbackURL()
-	sLogger.WithFields(log.Fields{"queueURL": queueURL}).Debug("Send sqs event")
+	sLogger.WithFields(log.Fields{"queueURL": d.Redact(queueURL)}).Debug("Send sqs event")
 
 	payload, jsonErr := e.ToJson()
 	if jsonErr != nil {
@@ -49,7 +49,7 @@ func (s *SqsSender) SendEvent(ev interfaces.IncomingEventInterface, dv interface
 	})
 
 	if err != nil {
-		sLogger.WithFields(log.Fields{"error": err, "event": e, "destination": d}).Error("Failed to send event with SQS")
+		sLogger.WithFields(log.Fields{"error": d.Redact(err.Error()), "event": e, "destination": d}).Error("Failed to send event with SQS")
 	}
 
 	return err
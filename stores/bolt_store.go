@@ -0,0 +1,347 @@
+package stores
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	interfaces "github.com/shoplineapp/captin/interfaces"
+	models "github.com/shoplineapp/captin/models"
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+var bLogger = log.WithFields(log.Fields{"class": "BoltStore"})
+
+var (
+	valuesBucket = []byte("values")
+	// expiryBucket - Index of keys by expiry time, <8 bytes unix nano><key> => nil
+	expiryBucket = []byte("expiry")
+)
+
+type boltRecord struct {
+	Value     string   `json:"value,omitempty"`
+	Queue     []string `json:"queue,omitempty"`
+	ExpiresAt int64    `json:"expires_at,omitempty"`
+}
+
+func (r boltRecord) expired(now time.Time) bool {
+	return r.ExpiresAt > 0 && r.ExpiresAt <= now.UnixNano()
+}
+
+func (r boltRecord) remaining(now time.Time) time.Duration {
+	if r.ExpiresAt == 0 {
+		return time.Duration(0)
+	}
+	return time.Duration(r.ExpiresAt - now.UnixNano())
+}
+
+// BoltStore - Persistent store with embedded bbolt database for single node deployments
+//
+// Every operation is committed in its own transaction, so that values survive restarts and crashes.
+// Expired keys are never returned and are removed by a background sweeper until Close.
+type BoltStore struct {
+	interfaces.StoreInterface
+	interfaces.AtomicStoreInterface
+
+	db        *bolt.DB
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewBoltStore - Open or create database at path, sweeping expired keys every sweepInterval
+func NewBoltStore(path string, sweepInterval time.Duration) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{valuesBucket, expiryBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	bs := &BoltStore{
+		db:   db,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go bs.sweep(sweepInterval)
+	return bs, nil
+}
+
+// Get - Get value from store, return with remaining time
+func (bs *BoltStore) Get(key string) (string, bool, time.Duration, error) {
+	bLogger.WithFields(log.Fields{"key": key}).Debug("Get key")
+	record, exists, err := bs.view(key)
+	if err != nil || !exists {
+		return "", false, time.Duration(0), err
+	}
+	return record.Value, true, record.remaining(time.Now()), nil
+}
+
+// Set - Set value into store with ttl
+func (bs *BoltStore) Set(key string, value string, ttl time.Duration) (bool, error) {
+	err := bs.db.Update(func(tx *bolt.Tx) error {
+		return putRecord(tx, key, boltRecord{Value: value, ExpiresAt: expiresAt(ttl)})
+	})
+	return err == nil, err
+}
+
+// Update - Update value for key, keeping its ttl
+func (bs *BoltStore) Update(key string, value string) (bool, error) {
+	updated := false
+	err := bs.db.Update(func(tx *bolt.Tx) error {
+		record, exists, err := getRecord(tx, key)
+		if err != nil || !exists {
+			return err
+		}
+		record.Value = value
+		updated = true
+		return putRecord(tx, key, record)
+	})
+	return updated, err
+}
+
+// Remove - Remove value for key
+func (bs *BoltStore) Remove(key string) (bool, error) {
+	err := bs.db.Update(func(tx *bolt.Tx) error {
+		return deleteRecord(tx, key)
+	})
+	return err == nil, err
+}
+
+// Enqueue - Append value to queue, ttl is set when first element is enqueued
+func (bs *BoltStore) Enqueue(key string, value string, ttl time.Duration) (bool, error) {
+	return bs.EnqueueWithLimit(key, value, ttl, 0)
+}
+
+// EnqueueWithLimit - Append value to queue and keep only the latest limit values, unlimited if limit is not positive
+func (bs *BoltStore) EnqueueWithLimit(key string, value string, ttl time.Duration, limit int) (bool, error) {
+	err := bs.db.Update(func(tx *bolt.Tx) error {
+		record, exists, err := getRecord(tx, key)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			record = boltRecord{ExpiresAt: expiresAt(ttl)}
+		}
+		record.Queue = append(record.Queue, value)
+		if limit > 0 && len(record.Queue) > limit {
+			record.Queue = record.Queue[len(record.Queue)-limit:]
+		}
+		return putRecord(tx, key, record)
+	})
+	return err == nil, err
+}
+
+// GetQueue - Get values of queue, return with remaining time
+func (bs *BoltStore) GetQueue(key string) ([]string, bool, time.Duration, error) {
+	record, exists, err := bs.view(key)
+	if err != nil || !exists || len(record.Queue) == 0 {
+		return []string{}, false, time.Duration(0), err
+	}
+	return record.Queue, true, record.remaining(time.Now()), nil
+}
+
+// SetNX - Set value into store with ttl only if key does not exist
+func (bs *BoltStore) SetNX(key string, value string, ttl time.Duration) (bool, error) {
+	created := false
+	err := bs.db.Update(func(tx *bolt.Tx) error {
+		_, exists, err := getRecord(tx, key)
+		if err != nil || exists {
+			return err
+		}
+		created = true
+		return putRecord(tx, key, boltRecord{Value: value, ExpiresAt: expiresAt(ttl)})
+	})
+	return created, err
+}
+
+// Incr - Increase integer value for key by delta, key is created with ttl if not exist
+func (bs *BoltStore) Incr(key string, delta int64, ttl time.Duration) (int64, error) {
+	var result int64
+	err := bs.db.Update(func(tx *bolt.Tx) error {
+		record, exists, err := getRecord(tx, key)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			record = boltRecord{Value: "0", ExpiresAt: expiresAt(ttl)}
+		}
+		current, err := strconv.ParseInt(record.Value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("BoltStore: value of %s is not an integer", key)
+		}
+		result = current + delta
+		record.Value = strconv.FormatInt(result, 10)
+		return putRecord(tx, key, record)
+	})
+	return result, err
+}
+
+// CompareAndSwap - Set value into store with ttl only if current value equals old
+func (bs *BoltStore) CompareAndSwap(key string, old string, value string, ttl time.Duration) (bool, error) {
+	swapped := false
+	err := bs.db.Update(func(tx *bolt.Tx) error {
+		record, exists, err := getRecord(tx, key)
+		if err != nil || !exists || record.Value != old {
+			return err
+		}
+		swapped = true
+		return putRecord(tx, key, boltRecord{Value: value, ExpiresAt: expiresAt(ttl)})
+	})
+	return swapped, err
+}
+
+// DataKey - Generate DataKey with events and destination
+func (bs *BoltStore) DataKey(ev interfaces.IncomingEventInterface, dest interfaces.DestinationInterface, prefix string, suffix string) string {
+	return models.DataKey(ev, dest, prefix, suffix)
+}
+
+// Len - Number of keys in store, including expired ones not yet swept
+func (bs *BoltStore) Len() int {
+	count := 0
+	bs.db.View(func(tx *bolt.Tx) error {
+		count = tx.Bucket(valuesBucket).Stats().KeyN
+		return nil
+	})
+	return count
+}
+
+// Close - Stop sweeper and close database
+func (bs *BoltStore) Close() error {
+	var err error
+	bs.closeOnce.Do(func() {
+		close(bs.stop)
+		<-bs.done
+		err = bs.db.Close()
+	})
+	return err
+}
+
+func (bs *BoltStore) view(key string) (boltRecord, bool, error) {
+	var record boltRecord
+	var exists bool
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		var err error
+		record, exists, err = getRecord(tx, key)
+		return err
+	})
+	return record, exists, err
+}
+
+func (bs *BoltStore) sweep(interval time.Duration) {
+	defer close(bs.done)
+	if interval <= 0 {
+		<-bs.stop
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-bs.stop:
+			return
+		case <-ticker.C:
+			if err := bs.removeExpired(); err != nil {
+				bLogger.WithFields(log.Fields{"error": err}).Error("Failed to remove expired keys")
+			}
+		}
+	}
+}
+
+// removeExpired - Remove keys expired by now with expiry index
+func (bs *BoltStore) removeExpired() error {
+	now := time.Now()
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		expired := []string{}
+		cursor := tx.Bucket(expiryBucket).Cursor()
+		for k, _ := cursor.First(); k != nil && int64(binary.BigEndian.Uint64(k[:8])) <= now.UnixNano(); k, _ = cursor.Next() {
+			expired = append(expired, string(k[8:]))
+		}
+		for _, key := range expired {
+			if err := deleteRecord(tx, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// getRecord - Get record of key, expired records do not exist
+func getRecord(tx *bolt.Tx, key string) (boltRecord, bool, error) {
+	var record boltRecord
+	data := tx.Bucket(valuesBucket).Get([]byte(key))
+	if data == nil {
+		return record, false, nil
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return record, false, err
+	}
+	if record.expired(time.Now()) {
+		return boltRecord{}, false, nil
+	}
+	return record, true, nil
+}
+
+func putRecord(tx *bolt.Tx, key string, record boltRecord) error {
+	if err := deleteRecord(tx, key); err != nil {
+		return err
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(valuesBucket).Put([]byte(key), data); err != nil {
+		return err
+	}
+	if record.ExpiresAt > 0 {
+		return tx.Bucket(expiryBucket).Put(expiryKey(record.ExpiresAt, key), nil)
+	}
+	return nil
+}
+
+func deleteRecord(tx *bolt.Tx, key string) error {
+	values := tx.Bucket(valuesBucket)
+	data := values.Get([]byte(key))
+	if data == nil {
+		return nil
+	}
+	var record boltRecord
+	if err := json.Unmarshal(data, &record); err == nil && record.ExpiresAt > 0 {
+		if err := tx.Bucket(expiryBucket).Delete(expiryKey(record.ExpiresAt, key)); err != nil {
+			return err
+		}
+	}
+	return values.Delete([]byte(key))
+}
+
+func expiryKey(expiresAt int64, key string) []byte {
+	buf := bytes.NewBuffer(make([]byte, 0, 8+len(key)))
+	binary.Write(buf, binary.BigEndian, uint64(expiresAt))
+	buf.WriteString(key)
+	return buf.Bytes()
+}
+
+// expiresAt - Expiry time of ttl in unix nano, 0 for no expiry
+func expiresAt(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return time.Now().Add(ttl).UnixNano()
+}
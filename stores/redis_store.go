@@ -0,0 +1,225 @@
+package stores
+
+import (
+	"time"
+
+	"github.com/go-redis/redis"
+	interfaces "github.com/shoplineapp/captin/interfaces"
+	models "github.com/shoplineapp/captin/models"
+	log "github.com/sirupsen/logrus"
+)
+
+var rLogger = log.WithFields(log.Fields{"class": "RedisStore"})
+
+var updateScript = redis.NewScript(`
+local ttl = redis.call("PTTL", KEYS[1])
+if ttl == -2 then
+	return 0
+end
+if ttl > 0 then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ttl)
+else
+	redis.call("SET", KEYS[1], ARGV[1])
+end
+return 1
+`)
+
+var enqueueScript = redis.NewScript(`
+local length = redis.call("RPUSH", KEYS[1], ARGV[1])
+if length == 1 and tonumber(ARGV[2]) > 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+local limit = tonumber(ARGV[3])
+if limit > 0 and length > limit then
+	redis.call("LTRIM", KEYS[1], -limit, -1)
+	length = limit
+end
+return length
+`)
+
+var incrScript = redis.NewScript(`
+local value = redis.call("INCRBY", KEYS[1], ARGV[1])
+if redis.call("PTTL", KEYS[1]) == -1 and tonumber(ARGV[2]) > 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return value
+`)
+
+var compareAndSwapScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+else
+	redis.call("SET", KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+// RedisStore - Redis data store with atomic operations per key
+//
+// Works with single node, cluster and sentinel through redis.UniversalClient.
+// Keys are prefixed with Prefix, e.g. "production:", so that environments can share a Redis.
+type RedisStore struct {
+	interfaces.StoreInterface
+	interfaces.AtomicStoreInterface
+
+	Prefix string
+	client redis.UniversalClient
+}
+
+// NewRedisStore - Create new RedisStore with options, Addrs with multiple nodes for cluster and MasterName for sentinel
+func NewRedisStore(options *redis.UniversalOptions, prefix string) (*RedisStore, error) {
+	client := redis.NewUniversalClient(options)
+	if err := client.Ping().Err(); err != nil {
+		rLogger.WithFields(log.Fields{"addrs": options.Addrs, "error": err}).Error("Failed to connect redis")
+		client.Close()
+		return nil, err
+	}
+	return NewRedisStoreWithClient(client, prefix), nil
+}
+
+// NewRedisStoreWithClient - Create new RedisStore with existing client
+func NewRedisStoreWithClient(client redis.UniversalClient, prefix string) *RedisStore {
+	return &RedisStore{
+		Prefix: prefix,
+		client: client,
+	}
+}
+
+// Get - Get value from store, return with remaining time
+func (rs *RedisStore) Get(key string) (string, bool, time.Duration, error) {
+	rLogger.WithFields(log.Fields{"key": key}).Debug("Get key")
+
+	pipe := rs.client.Pipeline()
+	get := pipe.Get(rs.key(key))
+	ttl := pipe.PTTL(rs.key(key))
+	if _, err := pipe.Exec(); err != nil && err != redis.Nil {
+		return "", false, time.Duration(0), err
+	}
+
+	val, err := get.Result()
+	if err == redis.Nil {
+		return "", false, time.Duration(0), nil
+	}
+	if err != nil {
+		return "", false, time.Duration(0), err
+	}
+	return val, true, remaining(ttl.Val()), nil
+}
+
+// Set - Set value into store with ttl
+func (rs *RedisStore) Set(key string, value string, ttl time.Duration) (bool, error) {
+	rLogger.WithFields(log.Fields{"key": key}).Debug("Set key")
+	if err := rs.client.Set(rs.key(key), value, expiration(ttl)).Err(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Update - Update value for key, keeping its ttl
+func (rs *RedisStore) Update(key string, value string) (bool, error) {
+	rLogger.WithFields(log.Fields{"key": key}).Debug("Update key")
+	updated, err := updateScript.Run(rs.client, []string{rs.key(key)}, value).Int64()
+	if err != nil {
+		return false, err
+	}
+	return updated == 1, nil
+}
+
+// Remove - Remove value for key
+func (rs *RedisStore) Remove(key string) (bool, error) {
+	rLogger.WithFields(log.Fields{"key": key}).Debug("Remove key")
+	if err := rs.client.Del(rs.key(key)).Err(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Enqueue - Append value to list, ttl is set when first element is enqueued
+func (rs *RedisStore) Enqueue(key string, value string, ttl time.Duration) (bool, error) {
+	return rs.EnqueueWithLimit(key, value, ttl, 0)
+}
+
+// EnqueueWithLimit - Append value to list and trim it to the latest limit values, unlimited if limit is not positive
+func (rs *RedisStore) EnqueueWithLimit(key string, value string, ttl time.Duration, limit int) (bool, error) {
+	rLogger.WithFields(log.Fields{"key": key}).Debug("Enqueue key")
+	if err := enqueueScript.Run(rs.client, []string{rs.key(key)}, value, milliseconds(ttl), limit).Err(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetQueue - Get values of list, return with remaining time
+func (rs *RedisStore) GetQueue(key string) ([]string, bool, time.Duration, error) {
+	rLogger.WithFields(log.Fields{"key": key}).Debug("Get queue")
+
+	pipe := rs.client.Pipeline()
+	values := pipe.LRange(rs.key(key), 0, -1)
+	ttl := pipe.PTTL(rs.key(key))
+	if _, err := pipe.Exec(); err != nil {
+		return []string{}, false, time.Duration(0), err
+	}
+
+	if len(values.Val()) == 0 {
+		return []string{}, false, time.Duration(0), nil
+	}
+	return values.Val(), true, remaining(ttl.Val()), nil
+}
+
+// SetNX - Set value into store with ttl only if key does not exist
+func (rs *RedisStore) SetNX(key string, value string, ttl time.Duration) (bool, error) {
+	return rs.client.SetNX(rs.key(key), value, expiration(ttl)).Result()
+}
+
+// Incr - Increase integer value for key by delta, key is created with ttl if not exist
+func (rs *RedisStore) Incr(key string, delta int64, ttl time.Duration) (int64, error) {
+	return incrScript.Run(rs.client, []string{rs.key(key)}, delta, milliseconds(ttl)).Int64()
+}
+
+// CompareAndSwap - Set value into store with ttl only if current value equals old
+func (rs *RedisStore) CompareAndSwap(key string, old string, value string, ttl time.Duration) (bool, error) {
+	swapped, err := compareAndSwapScript.Run(rs.client, []string{rs.key(key)}, old, value, milliseconds(ttl)).Int64()
+	return swapped == 1, err
+}
+
+// DataKey - Generate DataKey with events and destination
+func (rs *RedisStore) DataKey(ev interfaces.IncomingEventInterface, dest interfaces.DestinationInterface, prefix string, suffix string) string {
+	return models.DataKey(ev, dest, prefix, suffix)
+}
+
+// Close - Close redis client
+func (rs *RedisStore) Close() error {
+	return rs.client.Close()
+}
+
+func (rs *RedisStore) key(key string) string {
+	return rs.Prefix + key
+}
+
+// expiration - Redis expiration of ttl, no expiration if ttl is not positive
+func expiration(ttl time.Duration) time.Duration {
+	if ttl < 0 {
+		return 0
+	}
+	return ttl
+}
+
+func milliseconds(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	if ttl < time.Millisecond {
+		return 1
+	}
+	return int64(ttl / time.Millisecond)
+}
+
+// remaining - Remaining time of PTTL result, which is negative for keys without expiration
+func remaining(ttl time.Duration) time.Duration {
+	if ttl < 0 {
+		return time.Duration(0)
+	}
+	return ttl
+}
@@ -2,12 +2,19 @@ package models_test
 
 import (
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	. "github.com/shoplineapp/captin/core"
+	document_stores "github.com/shoplineapp/captin/document_stores"
 	captin_errors "github.com/shoplineapp/captin/errors"
 	interfaces "github.com/shoplineapp/captin/interfaces"
 	models "github.com/shoplineapp/captin/models"
+	schemas "github.com/shoplineapp/captin/schemas"
+	mocks "github.com/shoplineapp/captin/test/mocks"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -64,3 +71,224 @@ func TestSetDocumentStoreMapping(t *testing.T) {
 	captin.SetDocumentStoreMapping(storeMapping)
 	assert.Equal(t, captin.DocumentStoreMapping["mock"], mockStore)
 }
+
+func TestExecute_SchemaViolation(t *testing.T) {
+	registry := schemas.NewSchemaRegistry()
+	registry.Register("product.update", `{"type": "object", "required": ["_id"]}`)
+
+	captin := NewCaptin(models.ConfigurationMapper{})
+	captin.SetSchemaRegistry(registry)
+
+	result, errors := captin.Execute(models.IncomingEvent{
+		Key:     "product.update",
+		Source:  "core",
+		Payload: map[string]interface{}{"field1": 1},
+	})
+
+	assert.False(t, result)
+	if assert.Equal(t, 1, len(errors)) {
+		assert.IsType(t, &captin_errors.ExecutionError{}, errors[0])
+		assert.Equal(t, []string{"(root): _id is required"}, errors[0].(*captin_errors.ExecutionError).Violations)
+	}
+}
+
+func TestExecute_SchemaValid(t *testing.T) {
+	registry := schemas.NewSchemaRegistry()
+	registry.Register("product.update", `{"type": "object", "required": ["_id"]}`)
+
+	captin := NewCaptin(models.ConfigurationMapper{})
+	captin.SetSchemaRegistry(registry)
+
+	result, errors := captin.Execute(models.IncomingEvent{
+		Key:     "product.update",
+		Source:  "core",
+		Payload: map[string]interface{}{"_id": "1"},
+	})
+
+	assert.True(t, result)
+	assert.Empty(t, errors)
+}
+
+func TestExecute_SchemaOfConfigMapper(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "schemas"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "schemas", "product.update.json"), []byte(`{"type": "object", "required": ["_id"]}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "hooks.yaml"), []byte("- name: sync\n  actions: [product.update]\n"), 0644)
+	configMapper, err := models.NewReloadableConfigurationMapper(dir)
+	assert.Nil(t, err)
+
+	// schemas loaded with configurations are preferred
+	captin := NewCaptin(configMapper)
+	captin.SetSchemaRegistry(schemas.NewSchemaRegistry())
+	result, errors := captin.Execute(models.IncomingEvent{
+		Key:     "product.update",
+		Source:  "core",
+		Payload: map[string]interface{}{"field1": 1},
+	})
+
+	assert.False(t, result)
+	if assert.Equal(t, 1, len(errors)) {
+		assert.Equal(t, []string{"(root): _id is required"}, errors[0].(*captin_errors.ExecutionError).Violations)
+	}
+}
+
+// snapshotConfigMapper - Config mapper looking up configurations only in snapshots, counting snapshots taken
+type snapshotConfigMapper struct {
+	interfaces.ConfigMapperInterface
+	snapshot  interfaces.ConfigMapperInterface
+	snapshots int
+}
+
+func (m *snapshotConfigMapper) Snapshot() interfaces.ConfigMapperInterface {
+	m.snapshots++
+	return m.snapshot
+}
+
+func TestExecute_SnapshotOfConfigMapper(t *testing.T) {
+	configMapper := &snapshotConfigMapper{snapshot: models.NewConfigurationMapper([]interfaces.ConfigurationInterface{})}
+	captin := NewCaptin(configMapper)
+	result, errors := captin.Execute(models.IncomingEvent{Key: "product.update", Source: "core", Payload: map[string]interface{}{"field1": 1}})
+
+	// schemas and configurations are looked up in one snapshot
+	assert.True(t, result)
+	assert.Empty(t, errors)
+	assert.Equal(t, 1, configMapper.snapshots)
+}
+
+func TestValidateConfigurations(t *testing.T) {
+	captin := NewCaptin(models.ConfigurationMapper{})
+	configs := []interfaces.ConfigurationInterface{
+		models.Configuration{Name: "sync", Actions: []string{"product.update"}},
+		models.Configuration{Name: "queue", Actions: []string{"product.update"}, Sender: "sqs", DocumentStore: "mongo"},
+	}
+	assert.EqualError(t, captin.ValidateConfigurations(configs), "hook \"queue\": sender: unknown sender \"sqs\"; hook \"queue\": document_store: unknown document store \"mongo\"")
+
+	captin.SetSenderMapping(map[string]interfaces.EventSenderInterface{"http": EventSenderMock{}, "sqs": EventSenderMock{}})
+	captin.SetDocumentStoreMapping(map[string]interfaces.DocumentStoreInterface{"mongo": DocumentStoreMock{}})
+	assert.Nil(t, captin.ValidateConfigurations(configs))
+}
+
+func TestValidateConfigurations_PayloadSchema(t *testing.T) {
+	captin := NewCaptin(models.ConfigurationMapper{})
+	configs := []interfaces.ConfigurationInterface{
+		models.Configuration{Name: "partner_sync", Actions: []string{"product.update"}, PayloadSchema: "partner.product"},
+	}
+	assert.EqualError(t, captin.ValidateConfigurations(configs), "hook \"partner_sync\": payload_schema: unknown schema \"partner.product\"")
+
+	registry := schemas.NewSchemaRegistry()
+	registry.Register("partner.product", `{"type": "object"}`)
+	captin.SetSchemaRegistry(registry)
+	assert.Nil(t, captin.ValidateConfigurations(configs))
+}
+
+func TestExecute_InvalidateDocuments(t *testing.T) {
+	documentStore := new(mocks.DocumentStoreMock)
+	documentStore.On("GetDocument", mock.Anything).Return(map[string]interface{}{})
+	cache := document_stores.NewCachedDocumentStore(documentStore, time.Minute, 10)
+	cache.InvalidateKeys = []string{"*.delete"}
+	product := models.IncomingEvent{Key: "product.update", Source: "core", TargetType: "Product", TargetId: "p1"}
+	cache.GetDocument(product)
+
+	captin := NewCaptin(models.NewConfigurationMapper([]interfaces.ConfigurationInterface{}))
+	captin.SetDocumentStoreMapping(map[string]interfaces.DocumentStoreInterface{"default": cache})
+
+	// cached documents are invalidated without the cache middleware
+	captin.Execute(product)
+	assert.Equal(t, 1, cache.Len())
+	product.Key = "product.delete"
+	captin.Execute(product)
+	assert.Equal(t, 0, cache.Len())
+}
+
+func TestExecute_Tenant(t *testing.T) {
+	configMapper := models.NewConfigurationMapper([]interfaces.ConfigurationInterface{
+		models.Configuration{Name: "global_sync", Actions: []string{"product.update"}, Sender: "mock"},
+		models.Configuration{Name: "merchant_sync", Actions: []string{"product.update"}, Sender: "mock", Tenant: "merchant_a"},
+	})
+	sender := new(mocks.SenderMock)
+	sender.On("SendEvent", mock.Anything, mock.Anything).Return(nil)
+
+	captin := NewCaptin(configMapper)
+	captin.SetSenderMapping(map[string]interfaces.EventSenderInterface{"mock": sender})
+	captin.SetTenantPath("payload.merchant_id")
+	captin.SetTenantQuota("", models.TenantQuota{Limit: 2, Period: time.Minute})
+	captin.SetTenantQuota("merchant_b", models.TenantQuota{Limit: 1, Period: time.Minute})
+
+	execute := func(merchantID string) (bool, []interfaces.ErrorInterface, []string) {
+		sender.Calls = nil
+		result, errors := captin.Execute(models.IncomingEvent{
+			Key:     "product.update",
+			Source:  "core",
+			Payload: map[string]interface{}{"merchant_id": merchantID},
+		})
+		hooks := []string{}
+		for _, call := range sender.Calls {
+			e := call.Arguments.Get(0).(models.IncomingEvent)
+			assert.Equal(t, merchantID, e.Tenant)
+			hooks = append(hooks, call.Arguments.Get(1).(models.Destination).Config.GetName())
+		}
+		return result, errors, hooks
+	}
+
+	// global hooks and hooks of tenant are dispatched
+	result, errors, hooks := execute("merchant_a")
+	assert.True(t, result)
+	assert.Empty(t, errors)
+	assert.ElementsMatch(t, []string{"global_sync", "merchant_sync"}, hooks)
+
+	_, _, hooks = execute("merchant_b")
+	assert.Equal(t, []string{"global_sync"}, hooks)
+
+	// quota of tenant, or default quota of tenants
+	result, errors, hooks = execute("merchant_b")
+	assert.False(t, result)
+	assert.EqualError(t, errors[0], "ExecutionError: caused by quota of tenant merchant_b exceeded")
+	assert.Empty(t, hooks)
+
+	_, errors, _ = execute("merchant_a")
+	assert.Empty(t, errors)
+	_, errors, _ = execute("merchant_a")
+	assert.EqualError(t, errors[0], "ExecutionError: caused by quota of tenant merchant_a exceeded")
+
+	// tenant of event must match tenant at tenant path
+	sender.Calls = nil
+	result, errors = captin.Execute(models.IncomingEvent{
+		Key:     "product.update",
+		Source:  "core",
+		Tenant:  "merchant_c",
+		Payload: map[string]interface{}{"merchant_id": "merchant_b"},
+	})
+	assert.False(t, result)
+	assert.EqualError(t, errors[0], "ExecutionError: caused by tenant merchant_c of event does not match tenant at payload.merchant_id")
+	assert.Empty(t, sender.Calls)
+}
+
+func TestExecute_Tenant_WithoutTenantPath(t *testing.T) {
+	configMapper := models.NewConfigurationMapper([]interfaces.ConfigurationInterface{
+		models.Configuration{Name: "global_sync", Actions: []string{"product.update"}, Sender: "mock"},
+		models.Configuration{Name: "merchant_sync", Actions: []string{"product.update"}, Sender: "mock", Tenant: "merchant_a"},
+	})
+	sender := new(mocks.SenderMock)
+	sender.On("SendEvent", mock.Anything, mock.Anything).Return(nil)
+
+	captin := NewCaptin(configMapper)
+	captin.SetSenderMapping(map[string]interfaces.EventSenderInterface{"mock": sender})
+	captin.SetTenantQuota("merchant_a", models.TenantQuota{Limit: 1, Period: time.Minute})
+
+	// tenant chosen by client is ignored, neither dispatched to hooks of tenant nor counted in its quota
+	for i := 0; i < 2; i++ {
+		sender.Calls = nil
+		result, errors := captin.Execute(models.IncomingEvent{
+			Key:     "product.update",
+			Source:  "core",
+			Tenant:  "merchant_a",
+			Payload: map[string]interface{}{"field1": 1},
+		})
+		assert.True(t, result)
+		assert.Empty(t, errors)
+		if assert.Equal(t, 1, len(sender.Calls)) {
+			assert.Equal(t, "", sender.Calls[0].Arguments.Get(0).(models.IncomingEvent).Tenant)
+			assert.Equal(t, "global_sync", sender.Calls[0].Arguments.Get(1).(models.Destination).Config.GetName())
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package document_stores_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	document_stores "github.com/shoplineapp/captin/document_stores"
+	models "github.com/shoplineapp/captin/models"
+	mocks "github.com/shoplineapp/captin/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func productEvent(key string, id string) models.IncomingEvent {
+	return models.IncomingEvent{Key: key, Source: "core", TargetType: "Product", TargetId: id}
+}
+
+func TestCachedDocumentStore_GetDocument(t *testing.T) {
+	store := new(mocks.DocumentStoreMock)
+	store.On("GetDocument", mock.Anything).Return(map[string]interface{}{"_id": "p1", "tags": []interface{}{"a"}})
+	subject := document_stores.NewCachedDocumentStore(store, time.Minute, 10)
+
+	document := subject.GetDocument(productEvent("product.update", "p1"))
+	document["_id"] = "mutated"
+	assert.Equal(t, map[string]interface{}{"_id": "p1", "tags": []interface{}{"a"}}, subject.GetDocument(productEvent("product.create", "p1")))
+
+	store.AssertNumberOfCalls(t, "GetDocument", 1)
+	hits, misses := subject.Stats()
+	assert.Equal(t, uint64(1), hits)
+	assert.Equal(t, uint64(1), misses)
+}
+
+func TestCachedDocumentStore_TTL(t *testing.T) {
+	store := new(mocks.DocumentStoreMock)
+	store.On("GetDocument", mock.Anything).Return(map[string]interface{}{"_id": "p1"})
+	subject := document_stores.NewCachedDocumentStore(store, 20*time.Millisecond, 10)
+
+	subject.GetDocument(productEvent("product.update", "p1"))
+	time.Sleep(30 * time.Millisecond)
+	subject.GetDocument(productEvent("product.update", "p1"))
+
+	store.AssertNumberOfCalls(t, "GetDocument", 2)
+}
+
+func TestCachedDocumentStore_MaxSize(t *testing.T) {
+	store := new(mocks.DocumentStoreMock)
+	store.On("GetDocument", mock.Anything).Return(map[string]interface{}{})
+	subject := document_stores.NewCachedDocumentStore(store, time.Minute, 2)
+
+	subject.GetDocument(productEvent("product.update", "p1"))
+	subject.GetDocument(productEvent("product.update", "p2"))
+	// p1 is recently used, p2 is evicted
+	subject.GetDocument(productEvent("product.update", "p1"))
+	subject.GetDocument(productEvent("product.update", "p3"))
+	assert.Equal(t, 2, subject.Len())
+
+	subject.GetDocument(productEvent("product.update", "p1"))
+	subject.GetDocument(productEvent("product.update", "p2"))
+
+	store.AssertNumberOfCalls(t, "GetDocument", 4)
+}
+
+func TestCachedDocumentStore_ErrorNotCached(t *testing.T) {
+	store := new(mocks.DocumentStoreV2Mock)
+	e := productEvent("product.update", "p1")
+	store.On("GetDocumentContext", e).Return(nil, errors.New("timeout")).Once()
+	store.On("GetDocumentContext", e).Return(map[string]interface{}{"_id": "p1"}, nil).Once()
+	subject := document_stores.NewCachedDocumentStore(store, time.Minute, 10)
+
+	assert.Equal(t, map[string]interface{}{}, subject.GetDocument(e))
+	assert.Equal(t, map[string]interface{}{"_id": "p1"}, subject.GetDocument(e))
+	assert.Equal(t, map[string]interface{}{"_id": "p1"}, subject.GetDocument(e))
+	store.AssertNumberOfCalls(t, "GetDocumentContext", 2)
+}
+
+func TestCachedDocumentStore_Invalidate(t *testing.T) {
+	store := new(mocks.DocumentStoreMock)
+	store.On("GetDocument", mock.Anything).Return(map[string]interface{}{})
+	subject := document_stores.NewCachedDocumentStore(store, time.Minute, 10)
+	subject.InvalidateKeys = []string{"*.delete"}
+
+	subject.GetDocument(productEvent("product.update", "p1"))
+	subject.GetDocument(productEvent("product.update", "p2"))
+
+	e := productEvent("product.delete", "p1")
+	destinations := []models.Destination{{Config: models.Configuration{Name: "hook"}}}
+	assert.Equal(t, destinations, subject.Middleware().Apply(&e, destinations))
+	assert.False(t, subject.InvalidateEvent(productEvent("product.update", "p2")))
+	assert.Equal(t, 1, subject.Len())
+
+	subject.GetDocument(productEvent("product.update", "p1"))
+	subject.GetDocument(productEvent("product.update", "p2"))
+	store.AssertNumberOfCalls(t, "GetDocument", 3)
+
+	// patterns in the syntax of hook actions
+	subject.InvalidateKeys = []string{"**.delete", "!product.variant.delete"}
+	assert.True(t, subject.InvalidateEvent(productEvent("product.media.delete", "p1")))
+	assert.False(t, subject.InvalidateEvent(productEvent("product.variant.delete", "p2")))
+	assert.Equal(t, 1, subject.Len())
+
+	// invalid patterns never invalidate
+	subject.InvalidateKeys = []string{"*delete"}
+	assert.False(t, subject.InvalidateEvent(productEvent("product.delete", "p2")))
+	assert.Equal(t, 1, subject.Len())
+}
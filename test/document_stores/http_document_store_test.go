@@ -0,0 +1,119 @@
+package document_stores_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	document_stores "github.com/shoplineapp/captin/document_stores"
+	interfaces "github.com/shoplineapp/captin/interfaces"
+	models "github.com/shoplineapp/captin/models"
+	"github.com/stretchr/testify/assert"
+)
+
+var event = models.IncomingEvent{
+	Key:        "product.update",
+	Source:     "core",
+	TargetType: "Product",
+	TargetId:   "product id",
+}
+
+func TestHTTPDocumentStore_URL(t *testing.T) {
+	subject := document_stores.NewHTTPDocumentStore("http://api/{target_type}/{target_id}?event={event_key}&source={source}")
+	assert.Equal(t, "http://api/Product/product%20id?event=product.update&source=core", subject.URL(event))
+}
+
+func TestHTTPDocumentStore_GetDocument(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/products/product%20id", r.URL.EscapedPath())
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		w.Write([]byte(`{"data": {"product": {"_id": "product id", "title": "T-shirt"}}}`))
+	}))
+	defer server.Close()
+
+	subject := document_stores.NewHTTPDocumentStore(server.URL + "/products/{target_id}")
+	subject.Headers["Authorization"] = "Bearer token"
+	subject.ResponsePath = "data.product"
+
+	assert.Equal(t, map[string]interface{}{"_id": "product id", "title": "T-shirt"}, subject.GetDocument(event))
+}
+
+func TestHTTPDocumentStore_GetDocument_Retry(t *testing.T) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&count, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"_id": "product id"}`))
+	}))
+	defer server.Close()
+
+	subject := document_stores.NewHTTPDocumentStore(server.URL + "/{target_id}")
+	subject.RetryBackoff = time.Millisecond
+
+	assert.Equal(t, map[string]interface{}{"_id": "product id"}, subject.GetDocument(event))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&count))
+}
+
+func TestHTTPDocumentStore_GetDocument_Error(t *testing.T) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	subject := document_stores.NewHTTPDocumentStore(server.URL + "/{target_id}")
+
+	// Client errors are not retried and surfaced to dispatcher
+	_, err := subject.GetDocumentContext(context.Background(), event)
+	assert.EqualError(t, err, "HTTPDocumentStore: unexpected status 401 from "+server.URL+"/product%20id")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&count))
+	assert.Equal(t, map[string]interface{}{}, subject.GetDocument(event))
+}
+
+func TestHTTPDocumentStore_GetDocument_Timeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	subject := document_stores.NewHTTPDocumentStore(server.URL + "/{target_id}")
+	subject.Timeout = 10 * time.Millisecond
+	subject.Retry = 0
+
+	_, err := subject.GetDocumentContext(context.Background(), event)
+	assert.Error(t, err)
+}
+
+func TestHTTPDocumentStore_GetDocument_InvalidResponsePath(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": [1, 2]}`))
+	}))
+	defer server.Close()
+
+	subject := document_stores.NewHTTPDocumentStore(server.URL + "/{target_id}")
+	subject.ResponsePath = "data.product"
+	_, err := subject.GetDocumentContext(context.Background(), event)
+	assert.EqualError(t, err, "HTTPDocumentStore: response path data.product not found")
+
+	subject.ResponsePath = "data"
+	_, err = subject.GetDocumentContext(context.Background(), event)
+	assert.EqualError(t, err, "HTTPDocumentStore: document at response path data is not an object")
+}
+
+// otherEvent - Event implementation other than IncomingEvent
+type otherEvent struct {
+	interfaces.IncomingEventInterface
+}
+
+func TestHTTPDocumentStore_GetDocument_UnsupportedEvent(t *testing.T) {
+	subject := document_stores.NewHTTPDocumentStore("http://api/{target_type}/{target_id}")
+	_, err := subject.GetDocumentContext(context.Background(), otherEvent{})
+	assert.EqualError(t, err, "HTTPDocumentStore: unsupported event type document_stores_test.otherEvent")
+}
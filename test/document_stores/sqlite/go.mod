@@ -0,0 +1,11 @@
+module github.com/shoplineapp/captin/test/document_stores/sqlite
+
+go 1.15
+
+require (
+	github.com/mattn/go-sqlite3 v1.14.6
+	github.com/shoplineapp/captin v0.0.0-00010101000000-000000000000
+	github.com/stretchr/testify v1.7.0
+)
+
+replace github.com/shoplineapp/captin => ../../../
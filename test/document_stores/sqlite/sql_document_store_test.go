@@ -0,0 +1,156 @@
+package document_stores_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	document_stores "github.com/shoplineapp/captin/document_stores"
+	captin_errors "github.com/shoplineapp/captin/errors"
+	interfaces "github.com/shoplineapp/captin/interfaces"
+	document_stores_internal "github.com/shoplineapp/captin/internal/document_stores"
+	models "github.com/shoplineapp/captin/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupSQL(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Keep single connection as every connection has its own in-memory database
+	db.SetMaxOpenConns(1)
+
+	statements := []string{
+		`CREATE TABLE products (id TEXT PRIMARY KEY, title TEXT, price INTEGER, variations JSON, tags TEXT)`,
+		`INSERT INTO products VALUES ('p1', 'T-shirt', 100, '[{"sku": "S"}, {"sku": "M"}]', '["summer"]')`,
+		`CREATE TABLE orders (number TEXT, total REAL)`,
+		`INSERT INTO orders VALUES ('o1', 12.5)`,
+		`INSERT INTO orders VALUES ('o2', 20)`,
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func TestSQLDocumentStore_GetDocument_Table(t *testing.T) {
+	db := setupSQL(t)
+	defer db.Close()
+
+	subject := document_stores.NewSQLDocumentStore(db)
+	subject.Tables["Product"] = "products"
+	subject.JSONColumns = []string{"tags"}
+
+	document := subject.GetDocument(models.IncomingEvent{TargetType: "Product", TargetId: "p1"})
+	assert.Equal(t, map[string]interface{}{
+		"id":    "p1",
+		"title": "T-shirt",
+		"price": int64(100),
+		"variations": []interface{}{
+			map[string]interface{}{"sku": "S"},
+			map[string]interface{}{"sku": "M"},
+		},
+		"tags": []interface{}{"summer"},
+	}, document)
+}
+
+func TestSQLDocumentStore_GetDocument_Query(t *testing.T) {
+	db := setupSQL(t)
+	defer db.Close()
+
+	subject := document_stores.NewSQLDocumentStore(db)
+	subject.Queries["Order"] = "SELECT number, total FROM orders WHERE number = ?"
+
+	document := subject.GetDocument(models.IncomingEvent{TargetType: "Order", TargetId: "o1"})
+	assert.Equal(t, map[string]interface{}{"number": "o1", "total": 12.5}, document)
+}
+
+func TestSQLDocumentStore_GetDocument_Errors(t *testing.T) {
+	db := setupSQL(t)
+	defer db.Close()
+
+	subject := document_stores.NewSQLDocumentStore(db)
+	subject.Tables["Product"] = "products"
+	subject.Tables["User"] = "users"
+	subject.Queries["Order"] = "SELECT number FROM orders WHERE total > ?"
+
+	ctx := context.Background()
+	_, err := subject.GetDocumentContext(ctx, models.IncomingEvent{TargetType: "Product", TargetId: "p2"})
+	assert.EqualError(t, err, "SQLDocumentStore: Product p2 document not found")
+	assert.True(t, errors.Is(err, captin_errors.ErrDocumentNotFound))
+	_, err = subject.GetDocumentContext(ctx, models.IncomingEvent{TargetType: "Merchant", TargetId: "m1"})
+	assert.EqualError(t, err, "SQLDocumentStore: target type Merchant is not mapped")
+	_, err = subject.GetDocumentContext(ctx, models.IncomingEvent{TargetType: "User", TargetId: "u1"})
+	assert.Error(t, err)
+	_, err = subject.GetDocumentContext(ctx, models.IncomingEvent{TargetType: "Order", TargetId: "0"})
+	assert.EqualError(t, err, "SQLDocumentStore: Order 0 matches more than one row")
+
+	// errors are logged and documents are empty without context
+	assert.Equal(t, map[string]interface{}{}, subject.GetDocument(models.IncomingEvent{TargetType: "User", TargetId: "u1"}))
+}
+
+func TestSQLDocumentStore_Query(t *testing.T) {
+	subject := document_stores.NewSQLDocumentStore(nil)
+	subject.Tables["Product"] = "products"
+	subject.IDColumn = "_id"
+	subject.Placeholder = "$1"
+
+	query, err := subject.Query("Product")
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT * FROM products WHERE _id = $1 LIMIT 1", query)
+}
+
+func TestSQLDocumentStore_GetDocuments(t *testing.T) {
+	db := setupSQL(t)
+	defer db.Close()
+
+	subject := document_stores.NewSQLDocumentStore(db)
+	subject.Tables["Product"] = "products"
+	subject.Queries["Order"] = "SELECT number, total FROM orders WHERE number = ?"
+
+	documents, err := subject.GetDocuments(context.Background(), []interfaces.IncomingEventInterface{
+		models.IncomingEvent{TargetType: "Product", TargetId: "p1"},
+		models.IncomingEvent{TargetType: "Order", TargetId: "o2"},
+		models.IncomingEvent{TargetType: "Product", TargetId: "missing"},
+		models.IncomingEvent{TargetType: "Order", TargetId: "missing"},
+		models.IncomingEvent{TargetType: "Merchant", TargetId: "m1"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(documents))
+	assert.Equal(t, "T-shirt", documents[0]["title"])
+	assert.Equal(t, map[string]interface{}{"number": "o2", "total": 20.0}, documents[1])
+	assert.Nil(t, documents[2])
+	assert.Nil(t, documents[3])
+
+	// targets of types which are not mapped are not found, without failing other targets
+	assert.Nil(t, documents[4])
+
+	// lookups of throttled events are batched
+	batcher := document_stores_internal.NewDocumentBatcher(subject, 10*time.Millisecond, 0)
+	document, err := batcher.GetDocumentContext(context.Background(), models.IncomingEvent{TargetType: "Product", TargetId: "p1"})
+	assert.Nil(t, err)
+	assert.Equal(t, "p1", document["id"])
+	_, err = batcher.GetDocumentContext(context.Background(), models.IncomingEvent{TargetType: "Product", TargetId: "p2"})
+	assert.True(t, errors.Is(err, captin_errors.ErrDocumentNotFound))
+	_, err = batcher.GetDocumentContext(context.Background(), models.IncomingEvent{TargetType: "Merchant", TargetId: "m1"})
+	assert.True(t, errors.Is(err, captin_errors.ErrDocumentNotFound))
+}
+
+func TestSQLDocumentStore_BatchQuery(t *testing.T) {
+	subject := document_stores.NewSQLDocumentStore(nil)
+	subject.Tables["Product"] = "products"
+
+	query, err := subject.BatchQuery("Product", 3)
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT * FROM products WHERE id IN (?, ?, ?)", query)
+
+	subject.Placeholder = "$1"
+	query, _ = subject.BatchQuery("Product", 2)
+	assert.Equal(t, "SELECT * FROM products WHERE id IN ($1, $2)", query)
+}
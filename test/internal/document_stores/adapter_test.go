@@ -0,0 +1,55 @@
+package document_stores_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	captin_errors "github.com/shoplineapp/captin/errors"
+	document_stores "github.com/shoplineapp/captin/internal/document_stores"
+	models "github.com/shoplineapp/captin/models"
+	mocks "github.com/shoplineapp/captin/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAdapt_DocumentStore(t *testing.T) {
+	e := models.IncomingEvent{TargetType: "Product", TargetId: "product_id"}
+	store := new(mocks.DocumentStoreMock)
+	store.On("GetDocument", e).Return(map[string]interface{}{"_id": "product_id"})
+
+	document, err := document_stores.Adapt(store).GetDocumentContext(context.Background(), e)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"_id": "product_id"}, document)
+}
+
+func TestAdapt_DocumentStoreV2(t *testing.T) {
+	store := new(mocks.DocumentStoreV2Mock)
+	assert.Same(t, store, document_stores.Adapt(store))
+}
+
+func TestAdapt_Panic(t *testing.T) {
+	e := models.IncomingEvent{TargetType: "Product", TargetId: "product_id"}
+	store := new(mocks.DocumentStoreMock)
+	store.On("GetDocument", e).Run(func(_ mock.Arguments) {
+		panic(fmt.Errorf("lookup failed, %w", captin_errors.ErrDocumentNotFound))
+	})
+
+	document, err := document_stores.Adapt(store).GetDocumentContext(context.Background(), e)
+	assert.Nil(t, document)
+	assert.True(t, errors.Is(err, captin_errors.ErrDocumentNotFound))
+}
+
+func TestAdapt_Timeout(t *testing.T) {
+	e := models.IncomingEvent{TargetType: "Product", TargetId: "product_id"}
+	store := new(mocks.DocumentStoreMock)
+	store.On("GetDocument", e).After(200 * time.Millisecond).Return(map[string]interface{}{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	document, err := document_stores.Adapt(store).GetDocumentContext(ctx, e)
+	assert.Nil(t, document)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
@@ -0,0 +1,164 @@
+package document_stores_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	captin_errors "github.com/shoplineapp/captin/errors"
+	interfaces "github.com/shoplineapp/captin/interfaces"
+	document_stores "github.com/shoplineapp/captin/internal/document_stores"
+	models "github.com/shoplineapp/captin/models"
+	mocks "github.com/shoplineapp/captin/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func getDocumentsConcurrently(batcher *document_stores.DocumentBatcher, ids []string) ([]map[string]interface{}, []error) {
+	documents := make([]map[string]interface{}, len(ids))
+	errs := make([]error, len(ids))
+	wg := sync.WaitGroup{}
+	for i, id := range ids {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			documents[i], errs[i] = batcher.GetDocumentContext(context.Background(), models.IncomingEvent{TargetType: "Product", TargetId: id})
+		}(i, id)
+	}
+	wg.Wait()
+	return documents, errs
+}
+
+func TestDocumentBatcher_GetDocuments(t *testing.T) {
+	store := new(mocks.BatchDocumentStoreMock)
+	store.On("GetDocuments", mock.Anything).Return(func(events []interfaces.IncomingEventInterface) []map[string]interface{} {
+		documents := []map[string]interface{}{}
+		for _, e := range events {
+			id := e.(models.IncomingEvent).TargetId
+			if id == "missing" {
+				documents = append(documents, nil)
+			} else {
+				documents = append(documents, map[string]interface{}{"_id": id})
+			}
+		}
+		return documents
+	}, nil)
+	batcher := document_stores.NewDocumentBatcher(store, 50*time.Millisecond, 0)
+
+	documents, errs := getDocumentsConcurrently(batcher, []string{"p1", "p2", "p1", "missing"})
+
+	assert.Equal(t, map[string]interface{}{"_id": "p1"}, documents[0])
+	assert.Equal(t, map[string]interface{}{"_id": "p2"}, documents[1])
+	assert.Equal(t, map[string]interface{}{"_id": "p1"}, documents[2])
+	assert.Nil(t, errs[0])
+	assert.True(t, errors.Is(errs[3], captin_errors.ErrDocumentNotFound))
+
+	store.AssertNumberOfCalls(t, "GetDocuments", 1)
+	assert.Len(t, store.Calls[0].Arguments.Get(0), 3)
+	store.AssertNotCalled(t, "GetDocument", mock.Anything)
+}
+
+func TestDocumentBatcher_MaxBatch(t *testing.T) {
+	store := new(mocks.BatchDocumentStoreMock)
+	store.On("GetDocuments", mock.Anything).Return([]map[string]interface{}{{}, {}}, nil)
+	batcher := document_stores.NewDocumentBatcher(store, time.Minute, 2)
+
+	_, errs := getDocumentsConcurrently(batcher, []string{"p1", "p2"})
+	assert.Equal(t, []error{nil, nil}, errs)
+	store.AssertNumberOfCalls(t, "GetDocuments", 1)
+}
+
+func TestDocumentBatcher_Error(t *testing.T) {
+	store := new(mocks.BatchDocumentStoreMock)
+	store.On("GetDocuments", mock.Anything).Return(nil, errors.New("connection refused"))
+	batcher := document_stores.NewDocumentBatcher(store, 10*time.Millisecond, 0)
+
+	documents, errs := getDocumentsConcurrently(batcher, []string{"p1", "p2"})
+	assert.Equal(t, []map[string]interface{}{nil, nil}, documents)
+	assert.EqualError(t, errs[0], "connection refused")
+	assert.EqualError(t, errs[1], "connection refused")
+}
+
+func TestDocumentBatcher_Fallback(t *testing.T) {
+	store := new(mocks.DocumentStoreMock)
+	store.On("GetDocument", mock.Anything).Return(map[string]interface{}{"title": "T-shirt"})
+	batcher := document_stores.NewDocumentBatcher(store, 10*time.Millisecond, 0)
+
+	documents, errs := getDocumentsConcurrently(batcher, []string{"p1", "p2", "p2"})
+	assert.Equal(t, []error{nil, nil, nil}, errs)
+	assert.Equal(t, map[string]interface{}{"title": "T-shirt"}, documents[2])
+	store.AssertNumberOfCalls(t, "GetDocument", 2)
+}
+
+// deadlineBatchStore - Batch store recording deadline of batches, answering when context is done
+type deadlineBatchStore struct {
+	mocks.DocumentStoreMock
+	deadlines chan time.Time
+}
+
+func (s *deadlineBatchStore) GetDocuments(ctx context.Context, events []interfaces.IncomingEventInterface) ([]map[string]interface{}, error) {
+	deadline, _ := ctx.Deadline()
+	s.deadlines <- deadline
+	if deadline.IsZero() {
+		return make([]map[string]interface{}, len(events)), nil
+	}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestDocumentBatcher_Deadline(t *testing.T) {
+	store := &deadlineBatchStore{deadlines: make(chan time.Time, 2)}
+	batcher := document_stores.NewDocumentBatcher(store, 10*time.Millisecond, 0)
+	e := models.IncomingEvent{TargetType: "Product", TargetId: "p1"}
+
+	// batch is cancelled at the latest deadline of lookups of the same target
+	now := time.Now()
+	deadlines := []time.Time{now.Add(30 * time.Millisecond), now.Add(60 * time.Millisecond)}
+	wg := sync.WaitGroup{}
+	for _, deadline := range deadlines {
+		wg.Add(1)
+		go func(deadline time.Time) {
+			defer wg.Done()
+			ctx, cancel := context.WithDeadline(context.Background(), deadline)
+			defer cancel()
+			_, err := batcher.GetDocumentContext(ctx, e)
+			assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		}(deadline)
+	}
+	wg.Wait()
+	assert.True(t, deadlines[1].Equal(<-store.deadlines))
+
+	// without deadline if any lookup has none
+	go batcher.GetDocumentContext(context.Background(), e)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	batcher.GetDocumentContext(ctx, e)
+	assert.True(t, (<-store.deadlines).IsZero())
+}
+
+// otherEvent - Event implementation other than IncomingEvent
+type otherEvent struct {
+	interfaces.IncomingEventInterface
+}
+
+// otherEventStore - Batch store with documents of any event implementation
+type otherEventStore struct {
+	*mocks.BatchDocumentStoreMock
+}
+
+func (s otherEventStore) GetDocument(ie interfaces.IncomingEventInterface) map[string]interface{} {
+	return map[string]interface{}{"id": "p1"}
+}
+
+func TestDocumentBatcher_OtherEvent(t *testing.T) {
+	store := otherEventStore{new(mocks.BatchDocumentStoreMock)}
+	batcher := document_stores.NewDocumentBatcher(store, 10*time.Millisecond, 0)
+
+	// fetched without batching
+	document, err := batcher.GetDocumentContext(context.Background(), otherEvent{})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"id": "p1"}, document)
+	store.AssertNotCalled(t, "GetDocuments", mock.Anything)
+}
@@ -0,0 +1,48 @@
+package helpers_test
+
+import (
+	"testing"
+
+	helpers "github.com/shoplineapp/captin/internal/helpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeepMerge(t *testing.T) {
+	first := map[string]interface{}{"name": "a", "price": map[string]interface{}{"amount": 1, "currency": "USD"}, "tags": []interface{}{"x"}}
+	second := map[string]interface{}{"price": map[string]interface{}{"amount": 2}, "tags": []interface{}{"y"}, "stock": 3}
+
+	result := helpers.DeepMerge(first, second)
+	assert.Equal(t, map[string]interface{}{
+		"name":  "a",
+		"price": map[string]interface{}{"amount": 2, "currency": "USD"},
+		"tags":  []interface{}{"y"},
+		"stock": 3,
+	}, result)
+
+	// objects are not modified
+	assert.Equal(t, map[string]interface{}{"amount": 1, "currency": "USD"}, first["price"])
+	result["price"].(map[string]interface{})["amount"] = 3
+	assert.Equal(t, map[string]interface{}{"amount": 2}, second["price"])
+}
+
+func TestDeepMerge_ReplaceValueWithObject(t *testing.T) {
+	result := helpers.DeepMerge(
+		map[string]interface{}{"price": 1},
+		map[string]interface{}{"price": map[string]interface{}{"amount": 2}},
+		map[string]interface{}{"price": map[string]interface{}{"currency": "USD"}},
+	)
+	assert.Equal(t, map[string]interface{}{"price": map[string]interface{}{"amount": 2, "currency": "USD"}}, result)
+	assert.Equal(t, map[string]interface{}{}, helpers.DeepMerge())
+}
+
+func TestShallowMerge(t *testing.T) {
+	result := helpers.ShallowMerge(
+		map[string]interface{}{"name": "a", "price": map[string]interface{}{"amount": 1, "currency": "USD"}},
+		map[string]interface{}{"price": map[string]interface{}{"amount": 2}, "stock": 3},
+	)
+	assert.Equal(t, map[string]interface{}{
+		"name":  "a",
+		"price": map[string]interface{}{"amount": 2},
+		"stock": 3,
+	}, result)
+}
@@ -10,14 +10,16 @@ import (
 func TestIncludeFields(t *testing.T) {
   fields := []string{"foo"}
   object := map[string]interface{}{"foo": "bar", "foo2": "bar2"}
-  result := helpers.IncludeFields(object, fields)
+  result, err := helpers.IncludeFields(object, fields)
+  assert.Nil(t, err)
   assert.Equal(t, map[string]interface{}{ "foo": "bar" }, result)
 }
 
 func TestExcludeFields(t *testing.T) {
   fields := []string{"foo"}
   object := map[string]interface{}{"foo": "bar", "foo2": "bar2"}
-  result := helpers.ExcludeFields(object, fields)
+  result, err := helpers.ExcludeFields(object, fields)
+  assert.Nil(t, err)
   assert.Equal(t, map[string]interface{}{ "foo2": "bar2" }, result)
 
   // org object not modified
@@ -27,13 +29,117 @@ func TestExcludeFields(t *testing.T) {
 func TestNestedIncludeFields(t *testing.T) {
   fields := []string{"foo.deepfoo", "foo2.common"}
   object := map[string]interface{}{"foo": map[string]interface{}{"deepfoo": "deepbar", "else": "useless"}, "foo2": []interface{}{map[string]interface{}{"arrayfoo1": "arraybar1", "common": "a"}, map[string]interface{}{"arrayfoo2": "arraybar2", "common": "b"}}}
-  result := helpers.IncludeFields(object, fields)
+  result, err := helpers.IncludeFields(object, fields)
+  assert.Nil(t, err)
   assert.Equal(t, map[string]interface {}{"foo":map[string]interface {}{"deepfoo":"deepbar"}, "foo2":[]interface {}{map[string]interface {}{"common":"a"}, map[string]interface {}{"common":"b"}}}, result)
 }
 
 func TestNestedExcludeFields(t *testing.T) {
   fields := []string{"foo.else", "foo2.common"}
   object := map[string]interface{}{"foo": map[string]interface{}{"deepfoo": "deepbar", "else": "useless"}, "foo2": []interface{}{map[string]interface{}{"arrayfoo1": "arraybar1", "common": "a"}, map[string]interface{}{"arrayfoo2": "arraybar2", "common": "b"}}}
-  result := helpers.ExcludeFields(object, fields)
+  result, err := helpers.ExcludeFields(object, fields)
+  assert.Nil(t, err)
   assert.Equal(t, map[string]interface {}{"foo":map[string]interface {}{"deepfoo":"deepbar"}, "foo2":[]interface {}{map[string]interface {}{"arrayfoo1":"arraybar1"}, map[string]interface {}{"arrayfoo2":"arraybar2"}}}, result)
 }
+
+func TestWildcardIncludeFields(t *testing.T) {
+  fields := []string{"items.*.sku", "meta.*"}
+  object := map[string]interface{}{
+    "items": []interface{}{map[string]interface{}{"sku": "A", "price": 1}, map[string]interface{}{"sku": "B", "price": 2}},
+    "meta": map[string]interface{}{"a": 1, "b": 2},
+    "other": "x",
+  }
+  result, err := helpers.IncludeFields(object, fields)
+  assert.Nil(t, err)
+  assert.Equal(t, map[string]interface{}{
+    "items": []interface{}{map[string]interface{}{"sku": "A"}, map[string]interface{}{"sku": "B"}},
+    "meta": map[string]interface{}{"a": 1, "b": 2},
+  }, result)
+}
+
+func TestIndexIncludeFields(t *testing.T) {
+  fields := []string{"items[0].sku", "tags[1]"}
+  object := map[string]interface{}{
+    "items": []interface{}{map[string]interface{}{"sku": "A", "price": 1}, map[string]interface{}{"sku": "B", "price": 2}},
+    "tags": []interface{}{"a", "b", "c"},
+  }
+  result, err := helpers.IncludeFields(object, fields)
+  assert.Nil(t, err)
+  assert.Equal(t, map[string]interface{}{
+    "items": []interface{}{map[string]interface{}{"sku": "A"}},
+    "tags": []interface{}{"b"},
+  }, result)
+}
+
+func TestIndexExcludeFields(t *testing.T) {
+  fields := []string{"items[0].price", "tags[1]"}
+  object := map[string]interface{}{
+    "items": []interface{}{map[string]interface{}{"sku": "A", "price": 1}, map[string]interface{}{"sku": "B", "price": 2}},
+    "tags": []interface{}{"a", "b", "c"},
+  }
+  result, err := helpers.ExcludeFields(object, fields)
+  assert.Nil(t, err)
+  assert.Equal(t, map[string]interface{}{
+    "items": []interface{}{map[string]interface{}{"sku": "A"}, map[string]interface{}{"sku": "B", "price": 2}},
+    "tags": []interface{}{"a", "c"},
+  }, result)
+}
+
+func TestRenameIncludeFields(t *testing.T) {
+  fields := []string{"price as amount", "customer.name as customer_name"}
+  object := map[string]interface{}{"price": 10, "customer": map[string]interface{}{"name": "Peter", "age": 20}}
+  result, err := helpers.IncludeFields(object, fields)
+  assert.Nil(t, err)
+  assert.Equal(t, map[string]interface{}{"amount": 10, "customer": map[string]interface{}{"customer_name": "Peter"}}, result)
+}
+
+func TestTransformIncludeFields(t *testing.T) {
+  fields := []string{"email:mask", "phone:mask", "card:hash as card_digest"}
+  object := map[string]interface{}{"email": "peter@example.com", "phone": "91234567", "card": "4111111111111111", "other": 1}
+  result, err := helpers.IncludeFields(object, fields)
+  assert.Nil(t, err)
+  assert.Equal(t, map[string]interface{}{
+    "email": "p****@example.com",
+    "phone": "****4567",
+    "card_digest": "9bbef19476623ca56c17da75fd57734dbf82530686043a6e491c6d71befe8f6e",
+  }, result)
+}
+
+func TestTransformExcludeFields(t *testing.T) {
+  fields := []string{"password", "customers.*.email:mask", "price as amount"}
+  object := map[string]interface{}{
+    "password": "secret",
+    "price": 10,
+    "customers": []interface{}{map[string]interface{}{"email": "a@b.com", "name": "A"}},
+  }
+  result, err := helpers.ExcludeFields(object, fields)
+  assert.Nil(t, err)
+  assert.Equal(t, map[string]interface{}{
+    "amount": 10,
+    "customers": []interface{}{map[string]interface{}{"email": "a@b.com", "name": "A"}},
+  }, result)
+
+  fields = []string{"customers.*.email:mask", "customers.*.phone:hash"}
+  object = map[string]interface{}{
+    "customers": []interface{}{map[string]interface{}{"email": "amy@b.com", "phone": 12345678, "name": "A"}},
+  }
+  result, err = helpers.ExcludeFields(object, fields)
+  assert.Nil(t, err)
+  assert.Equal(t, map[string]interface{}{
+    "customers": []interface{}{map[string]interface{}{"email": "a**@b.com", "phone": "ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f", "name": "A"}},
+  }, result)
+}
+
+func TestInvalidFields(t *testing.T) {
+  assert.Nil(t, helpers.ValidateFields([]string{"foo", "foo.bar[1]", "*.baz:hash as qux"}))
+  assert.EqualError(t, helpers.ValidateFields([]string{"foo:upper"}), `invalid field "foo:upper": unknown transformation "upper"`)
+  assert.EqualError(t, helpers.ValidateFields([]string{"foo..bar"}), `invalid field "foo..bar"`)
+  assert.EqualError(t, helpers.ValidateFields([]string{"foo as "}), `invalid field "foo as ": missing alias`)
+
+  // invalid selectors are not applied
+  result, err := helpers.IncludeFields(map[string]interface{}{"foo": "bar"}, []string{"foo", "foo[x]"})
+  assert.Nil(t, result)
+  assert.EqualError(t, err, `invalid field "foo[x]"`)
+  _, err = helpers.ExcludeFields(map[string]interface{}{"foo": "bar"}, []string{"foo:upper"})
+  assert.EqualError(t, err, `invalid field "foo:upper": unknown transformation "upper"`)
+}
@@ -0,0 +1,33 @@
+package helpers_test
+
+import (
+	"testing"
+
+	helpers "github.com/shoplineapp/captin/internal/helpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValueAtPath(t *testing.T) {
+	object := map[string]interface{}{
+		"data": map[string]interface{}{
+			"items": []interface{}{map[string]interface{}{"name": "A"}},
+		},
+	}
+
+	value, ok := helpers.ValueAtPath(object, "data.items.0.name")
+	assert.True(t, ok)
+	assert.Equal(t, "A", value)
+
+	value, ok = helpers.ValueAtPath(object, "")
+	assert.True(t, ok)
+	assert.Equal(t, object, value)
+
+	_, ok = helpers.ValueAtPath(object, "data.items.1.name")
+	assert.False(t, ok)
+
+	_, ok = helpers.ValueAtPath(object, "data.missing")
+	assert.False(t, ok)
+
+	_, ok = helpers.ValueAtPath(object, "data.items.0.name.first")
+	assert.False(t, ok)
+}
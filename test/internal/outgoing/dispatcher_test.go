@@ -1,21 +1,32 @@
 package outgoing_test
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 	"unsafe"
 
 	delayers "github.com/shoplineapp/captin/dispatcher/delayers"
+	document_stores "github.com/shoplineapp/captin/document_stores"
 	captin_errors "github.com/shoplineapp/captin/errors"
 	interfaces "github.com/shoplineapp/captin/interfaces"
+	document_stores_internal "github.com/shoplineapp/captin/internal/document_stores"
 	outgoing "github.com/shoplineapp/captin/internal/outgoing"
 	stores "github.com/shoplineapp/captin/internal/stores"
+	throttles "github.com/shoplineapp/captin/internal/throttles"
 	models "github.com/shoplineapp/captin/models"
+	schemas "github.com/shoplineapp/captin/schemas"
 	mocks "github.com/shoplineapp/captin/test/mocks"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -159,7 +170,7 @@ func TestDispatchEvents_Throttled_DelaySend(t *testing.T) {
 
 	sender.AssertNumberOfCalls(t, "SendEvent", 0)
 
-	throttleID := "product.update.service_one.product_id-data"
+	throttleID := `product\.update.service_one.product_id-data`
 	throttlePeriod := time.Millisecond * 500 * 2
 	store.AssertCalled(t, "Get", throttleID)
 	store.AssertCalled(t, "Set", throttleID, `{"control":null,"event_key":"product.update","payload":{"field1":1},"source":"core","target_id":"product_id","target_type":"Product","trace_id":""}`, throttlePeriod)
@@ -195,7 +206,7 @@ func TestDispatchEvents_Delayer_Send(t *testing.T) {
 
 func TestDispatchEvents_Throttled_SkipUpdatingValue(t *testing.T) {
 	store, documentStores, sender, dispatcher, throttler := setup("fixtures/config.single.json")
-	throttleID := "product.update.service_one.product_id-data"
+	throttleID := `product\.update.service_one.product_id-data`
 	throttlePeriod := time.Millisecond * 700 * 2
 
 	sender.On("SendEvent", mock.Anything, mock.Anything).Return(nil)
@@ -221,7 +232,7 @@ func TestDispatchEvents_Throttled_SkipUpdatingValue(t *testing.T) {
 
 func TestDispatchEvents_Throttled_UpdatePayload(t *testing.T) {
 	store, documentStores, sender, dispatcher, throttler := setup("fixtures/config.single.json")
-	throttleID := "product.update.service_one.product_id-data"
+	throttleID := `product\.update.service_one.product_id-data`
 	throttlePeriod := time.Millisecond * 700 * 2
 
 	sender.On("SendEvent", mock.Anything, mock.Anything).Return(nil)
@@ -251,8 +262,8 @@ func TestDispatchEvents_Throttled_KeepThrottledPayloads(t *testing.T) {
 	throttler.On("CanTrigger", mock.Anything, mock.Anything).Return(false, 500*time.Millisecond, nil)
 	sender.On("SendEvent", mock.Anything, mock.Anything).Return(nil)
 
-	throttleID := "product.update.service_one.product_id-data"
-	throttlePayloadsID := "product.update.service_one.product_id-throttled_payloads"
+	throttleID := `product\.update.service_one.product_id-data`
+	throttlePayloadsID := `product\.update.service_one.product_id-throttled_payloads`
 
 	dispatcher.Dispatch(models.IncomingEvent{
 		Key:        "product.update",
@@ -288,8 +299,8 @@ func TestDispatchEvents_Throttled_KeepThrottledPayloads_Multiple(t *testing.T) {
 	throttler.On("CanTrigger", mock.Anything, mock.Anything).Return(false, 500*time.Millisecond, nil)
 	sender.On("SendEvent", mock.Anything, mock.Anything).Return(nil)
 
-	throttleID := "product.update.service_one.product_id-data"
-	throttlePayloadsID := "product.update.service_one.product_id-throttled_payloads"
+	throttleID := `product\.update.service_one.product_id-data`
+	throttlePayloadsID := `product\.update.service_one.product_id-throttled_payloads`
 
 	dispatcher.Dispatch(models.IncomingEvent{
 		Key:        "product.update",
@@ -345,8 +356,8 @@ func TestDispatchEvents_Throttled_KeepThrottledDocuments(t *testing.T) {
 	throttler.On("CanTrigger", mock.Anything, mock.Anything).Return(false, 500*time.Millisecond, nil)
 	sender.On("SendEvent", mock.Anything, mock.Anything).Return(nil)
 
-	throttleID := "product.update.service_one.product_id-data"
-	throttleDocumentsID := "product.update.service_one.product_id-throttled_documents"
+	throttleID := `product\.update.service_one.product_id-data`
+	throttleDocumentsID := `product\.update.service_one.product_id-throttled_documents`
 
 	dispatcher.Dispatch(models.IncomingEvent{
 		Key:        "product.update",
@@ -387,8 +398,8 @@ func TestDispatchEvents_Throttled_KeepThrottledDocuments_Multiple(t *testing.T)
 	throttler.On("CanTrigger", mock.Anything, mock.Anything).Return(false, 500*time.Millisecond, nil)
 	sender.On("SendEvent", mock.Anything, mock.Anything).Return(nil)
 
-	throttleID := "product.update.service_one.product_id-data"
-	throttleDocumentsID := "product.update.service_one.product_id-throttled_documents"
+	throttleID := `product\.update.service_one.product_id-data`
+	throttleDocumentsID := `product\.update.service_one.product_id-throttled_documents`
 
 	dispatcher.Dispatch(models.IncomingEvent{
 		Key:        "product.update",
@@ -548,6 +559,86 @@ func TestDispatchEvents_With_Exclude_Payload_Attrs(t *testing.T) {
 	sender.AssertExpectations(t)
 }
 
+func TestDispatchEvents_With_Invalid_Payload_Attrs(t *testing.T) {
+	store, documentStores, sender, _, throttler := setup("fixtures/config.exclude_payload_attrs.json")
+	config := models.Configuration{Name: "invalid_attrs", Actions: []string{"product.update"}, Sender: "mock", ExcludePayloadAttrs: []string{"email:foo"}}
+	dispatcher := outgoing.NewDispatcherWithDestinations([]models.Destination{{Config: config}}, map[string]interfaces.EventSenderInterface{"mock": sender})
+
+	sender.On("SendEvent", mock.Anything, mock.Anything).Return(nil)
+	throttler.On("CanTrigger", mock.Anything, mock.Anything).Return(true, time.Duration(0), nil)
+
+	dispatcher.Dispatch(models.IncomingEvent{
+		Key:        "product.update",
+		Source:     "core",
+		Payload:    map[string]interface{}{"email": "amy@b.com"},
+		TargetType: "Product",
+		TargetId:   "product_id",
+	}, store, throttler, documentStores)
+
+	// payload is not sent without the field masked
+	sender.AssertNumberOfCalls(t, "SendEvent", 0)
+	assert.Equal(t, 1, len(dispatcher.GetErrors()))
+	assert.EqualError(t, dispatcher.GetErrors()[0], `DispatcherError: Failed to select fields, invalid field "email:foo": unknown transformation "foo"`)
+}
+
+func TestDispatchEvents_With_Invalid_Payload_Attrs_Throttled(t *testing.T) {
+	_, documentStores, sender, _, _ := setup("fixtures/config.json")
+	store := stores.NewMemoryStore()
+	throttler := throttles.NewThrottler(store)
+	sender.On("SendEvent", mock.Anything, mock.Anything).Return(nil)
+
+	disabled := false
+	dispatcher := outgoing.NewDispatcherWithDestinations(
+		[]models.Destination{{Config: models.Configuration{
+			Name: "invalid_attrs", Throttle: "100ms", ThrottleLeading: &disabled, KeepThrottledPayloads: true,
+			ExcludePayloadAttrs: []string{"email:foo"}, Sender: "mock",
+		}}},
+		map[string]interfaces.EventSenderInterface{"mock": sender},
+	)
+
+	dispatcher.Dispatch(models.IncomingEvent{
+		Key:        "product.update",
+		Source:     "core",
+		Payload:    map[string]interface{}{"email": "amy@b.com"},
+		TargetType: "Product",
+		TargetId:   "product_id",
+	}, store, throttler, documentStores)
+	time.Sleep(200 * time.Millisecond)
+
+	// throttled payload is neither stored nor sent, with the error reported once
+	sender.AssertNumberOfCalls(t, "SendEvent", 0)
+	assert.Equal(t, 1, len(dispatcher.GetErrors()))
+	assert.EqualError(t, dispatcher.GetErrors()[0], `DispatcherError: Failed to select fields, invalid field "email:foo": unknown transformation "foo"`)
+}
+
+func TestDispatchEvents_SenderError_Redacted(t *testing.T) {
+	store, documentStores, sender, _, throttler := setup("fixtures/config.exclude_payload_attrs.json")
+	os.Setenv("CAPTIN_TEST_CALLBACK_URL", "http://localhost/sync?token=s3cr3t")
+	defer os.Unsetenv("CAPTIN_TEST_CALLBACK_URL")
+	configs, err := models.NewSecretResolver().ResolveConfigurations([]models.Configuration{{
+		Name: "secret_sync", Actions: []string{"product.update"}, Sender: "mock", CallbackURL: "env:CAPTIN_TEST_CALLBACK_URL",
+	}})
+	assert.Nil(t, err)
+	dispatcher := outgoing.NewDispatcherWithDestinations([]models.Destination{{Config: configs[0]}}, map[string]interfaces.EventSenderInterface{"mock": sender})
+
+	// errors of http clients contain the callback url
+	sender.On("SendEvent", mock.Anything, mock.Anything).Return(&url.Error{Op: "Post", URL: "http://localhost/sync?token=s3cr3t", Err: errors.New("refused")})
+	throttler.On("CanTrigger", mock.Anything, mock.Anything).Return(true, time.Duration(0), nil)
+
+	dispatcher.Dispatch(models.IncomingEvent{
+		Key:        "product.update",
+		Source:     "core",
+		TargetType: "Product",
+		TargetId:   "product_id",
+	}, store, throttler, documentStores)
+
+	if assert.Equal(t, 1, len(dispatcher.GetErrors())) {
+		dispatcherErr := dispatcher.GetErrors()[0].(*captin_errors.DispatcherError)
+		assert.NotContains(t, dispatcherErr.Msg, "s3cr3t")
+		assert.Equal(t, `DispatcherError: Post "[REDACTED]": refused`, dispatcherErr.Error())
+	}
+}
+
 func TestDispatchEvents_WithSpecificDocumentStore(t *testing.T) {
 	store, documentStores, sender, dispatcher, throttler := setup("fixtures/config.specific_document_store.json")
 	defaultDocumentStore := new(mocks.DocumentStoreMock)
@@ -677,3 +768,642 @@ func TestDispatchEvents_DelaySend_NotExist(t *testing.T) {
 	assert.IsType(t, &captin_errors.UnretryableError{}, dispatcher.GetErrors()[0])
 	sender.AssertNumberOfCalls(t, "SendEvent", 0)
 }
+
+func TestDispatchEvents_With_Payload_Schema(t *testing.T) {
+	store, documentStores, sender, dispatcher, throttler := setup("fixtures/config.payload_schema.json")
+	registry := schemas.NewSchemaRegistry()
+	registry.Register("partner.product", `{"type": "object", "required": ["_id"], "additionalProperties": false, "properties": {"_id": {"type": "string"}}}`)
+	dispatcher.SetSchemaRegistry(registry)
+
+	sender.On("SendEvent", mock.Anything, mock.Anything).Return(nil)
+	throttler.On("CanTrigger", mock.Anything, mock.Anything).Return(true, time.Duration(0), nil)
+
+	dispatcher.Dispatch(models.IncomingEvent{
+		Key:        "product.update",
+		Source:     "core",
+		Payload:    map[string]interface{}{"_id": "product_id", "secret": "value"},
+		TargetType: "Product",
+		TargetId:   "product_id",
+	}, store, throttler, documentStores)
+
+	dispatcher.Dispatch(models.IncomingEvent{
+		Key:        "product.update",
+		Source:     "core",
+		Payload:    map[string]interface{}{"secret": "value"},
+		TargetType: "Product",
+		TargetId:   "product_id",
+	}, store, throttler, documentStores)
+
+	sender.AssertNumberOfCalls(t, "SendEvent", 1)
+	assert.Equal(t, 1, len(dispatcher.GetErrors()))
+	assert.IsType(t, &captin_errors.DispatcherError{}, dispatcher.GetErrors()[0])
+	assert.Contains(t, dispatcher.GetErrors()[0].Error(), "_id is required")
+}
+
+func TestDispatchEvents_With_Payload_Schema_NotRegistered(t *testing.T) {
+	store, documentStores, sender, dispatcher, throttler := setup("fixtures/config.payload_schema.json")
+
+	sender.On("SendEvent", mock.Anything, mock.Anything).Return(nil)
+	throttler.On("CanTrigger", mock.Anything, mock.Anything).Return(true, time.Duration(0), nil)
+
+	dispatcher.Dispatch(models.IncomingEvent{
+		Key:        "product.update",
+		Source:     "core",
+		Payload:    map[string]interface{}{"_id": "product_id"},
+		TargetType: "Product",
+		TargetId:   "product_id",
+	}, store, throttler, documentStores)
+
+	sender.AssertNumberOfCalls(t, "SendEvent", 0)
+	assert.Equal(t, 1, len(dispatcher.GetErrors()))
+	assert.Contains(t, dispatcher.GetErrors()[0].Error(), "Payload schema partner.product does not exist")
+}
+
+func TestDispatchEvents_With_Document_Error(t *testing.T) {
+	store, documentStores, sender, dispatcher, throttler := setup("fixtures/config.include_document.json")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	documentStores["default"] = document_stores.NewHTTPDocumentStore(server.URL + "/{target_id}")
+
+	sender.On("SendEvent", mock.Anything, mock.Anything).Return(nil)
+	throttler.On("CanTrigger", mock.Anything, mock.Anything).Return(true, time.Duration(0), nil)
+
+	dispatcher.Dispatch(models.IncomingEvent{
+		Key:        "product.update",
+		Source:     "core",
+		Payload:    map[string]interface{}{"field1": 1},
+		TargetType: "Product",
+		TargetId:   "product_id",
+	}, store, throttler, documentStores)
+
+	// Destination including document is not sent with empty document
+	sender.AssertNotCalled(t, "SendEvent", mock.MatchedBy(func(e models.IncomingEvent) bool { return e.TargetDocument != nil }), mock.Anything)
+	assert.Equal(t, 1, len(dispatcher.GetErrors()))
+	assert.IsType(t, &captin_errors.DispatcherError{}, dispatcher.GetErrors()[0])
+}
+
+func TestDispatchEvents_With_Document_NotFound(t *testing.T) {
+	store, documentStores, sender, dispatcher, throttler := setup("fixtures/config.document_policy.json")
+	documentStore := new(mocks.DocumentStoreV2Mock)
+	documentStores["default"] = documentStore
+
+	e := models.IncomingEvent{
+		Key:        "product.update",
+		Source:     "core",
+		Payload:    map[string]interface{}{"field1": 1},
+		TargetType: "Product",
+		TargetId:   "product_id",
+	}
+	documentStore.On("GetDocumentContext", e).Return(nil, fmt.Errorf("Product %w", captin_errors.ErrDocumentNotFound)).Once()
+	sender.On("SendEvent", mock.Anything, mock.Anything).Return(nil)
+	throttler.On("CanTrigger", mock.Anything, mock.Anything).Return(true, time.Duration(0), nil)
+
+	dispatcher.Dispatch(e, store, throttler, documentStores)
+
+	// service_error reports error, service_skip is skipped, service_send is sent with empty document
+	sender.AssertNumberOfCalls(t, "SendEvent", 1)
+	sender.AssertCalled(t, "SendEvent", mock.MatchedBy(func(e models.IncomingEvent) bool {
+		return reflect.DeepEqual(e.TargetDocument, map[string]interface{}{})
+	}), mock.MatchedBy(func(d models.Destination) bool { return d.Config.GetName() == "service_send" }))
+	assert.Equal(t, 1, len(dispatcher.GetErrors()))
+	assert.Contains(t, dispatcher.GetErrors()[0].Error(), "Failed to get document, Product document not found")
+	documentStore.AssertNumberOfCalls(t, "GetDocumentContext", 1)
+}
+
+func TestDispatchEvents_With_Document_Store_Error(t *testing.T) {
+	store, documentStores, sender, dispatcher, throttler := setup("fixtures/config.document_policy.json")
+	documentStore := new(mocks.DocumentStoreV2Mock)
+	documentStores["default"] = documentStore
+
+	documentStore.On("GetDocumentContext", mock.Anything).Return(nil, errors.New("connection refused"))
+	sender.On("SendEvent", mock.Anything, mock.Anything).Return(nil)
+	throttler.On("CanTrigger", mock.Anything, mock.Anything).Return(true, time.Duration(0), nil)
+
+	dispatcher.Dispatch(models.IncomingEvent{
+		Key:        "product.update",
+		Source:     "core",
+		Payload:    map[string]interface{}{"field1": 1},
+		TargetType: "Product",
+		TargetId:   "product_id",
+	}, store, throttler, documentStores)
+
+	sender.AssertNumberOfCalls(t, "SendEvent", 0)
+	assert.Equal(t, 1, len(dispatcher.GetErrors()))
+	assert.IsType(t, &captin_errors.DispatcherError{}, dispatcher.GetErrors()[0])
+	assert.Contains(t, dispatcher.GetErrors()[0].Error(), "connection refused")
+}
+
+func TestDispatchEvents_With_Document_Timeout(t *testing.T) {
+	store, documentStores, sender, dispatcher, throttler := setup("fixtures/config.document_policy.json")
+	documentStore := documentStores["default"].(*mocks.DocumentStoreMock)
+
+	documentStore.On("GetDocument", mock.Anything).After(500 * time.Millisecond).Return(map[string]interface{}{"_id": "product_id"})
+	sender.On("SendEvent", mock.Anything, mock.Anything).Return(nil)
+	throttler.On("CanTrigger", mock.Anything, mock.Anything).Return(true, time.Duration(0), nil)
+
+	dispatcher.Dispatch(models.IncomingEvent{
+		Key:        "product.update",
+		Source:     "core",
+		Payload:    map[string]interface{}{"field1": 1},
+		TargetType: "Product",
+		TargetId:   "product_id",
+	}, store, throttler, documentStores)
+
+	assert.Equal(t, 1, len(dispatcher.GetErrors()))
+	assert.Contains(t, dispatcher.GetErrors()[0].Error(), "context deadline exceeded")
+}
+
+// slowDocumentStore - Document store answering after delay unless context is done
+type slowDocumentStore struct {
+	interfaces.DocumentStoreInterface
+	delay time.Duration
+}
+
+func (s slowDocumentStore) GetDocumentContext(ctx context.Context, e interfaces.IncomingEventInterface) (map[string]interface{}, error) {
+	select {
+	case <-time.After(s.delay):
+		return map[string]interface{}{"_id": "product_id"}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestDispatchEvents_With_Document_Timeout_PerDestination(t *testing.T) {
+	store, _, sender, _, throttler := setup("fixtures/config.document_policy.json")
+	documentStores := map[string]interfaces.DocumentStoreInterface{"default": slowDocumentStore{delay: 50 * time.Millisecond}}
+	destinations := []models.Destination{}
+	for _, timeout := range []string{"10ms", "1s"} {
+		destinations = append(destinations, models.Destination{Config: models.Configuration{
+			Name: "timeout_" + timeout, Actions: []string{"product.update"}, Sender: "mock", IncludeDocument: true, DocumentTimeout: timeout,
+		}})
+	}
+	dispatcher := outgoing.NewDispatcherWithDestinations(destinations, map[string]interfaces.EventSenderInterface{"mock": sender})
+
+	sender.On("SendEvent", mock.Anything, mock.Anything).Return(nil)
+	// destination with longer timeout gets document after the other one timed out
+	throttler.On("CanTrigger", mock.MatchedBy(func(id string) bool { return strings.Contains(id, "timeout_1s") }), mock.Anything).
+		After(30*time.Millisecond).Return(true, time.Duration(0), nil)
+	throttler.On("CanTrigger", mock.Anything, mock.Anything).Return(true, time.Duration(0), nil)
+
+	dispatcher.Dispatch(models.IncomingEvent{
+		Key:        "product.update",
+		Source:     "core",
+		Payload:    map[string]interface{}{"field1": 1},
+		TargetType: "Product",
+		TargetId:   "product_id",
+	}, store, throttler, documentStores)
+
+	assert.Equal(t, 1, len(dispatcher.GetErrors()))
+	assert.Contains(t, dispatcher.GetErrors()[0].Error(), "context deadline exceeded")
+	sender.AssertNumberOfCalls(t, "SendEvent", 1)
+	assert.Equal(t, "timeout_1s", sender.Calls[0].Arguments.Get(1).(models.Destination).Config.GetName())
+}
+
+func TestDispatchEvents_Throttled_KeepThrottledDocuments_Batch(t *testing.T) {
+	_, documentStores, _, _, throttler := setup("fixtures/config.keep_throttled_documents.json")
+	store := stores.NewMemoryStore()
+
+	batchDocumentStore := new(mocks.BatchDocumentStoreMock)
+	batchDocumentStore.On("GetDocuments", mock.Anything).Return(func(events []interfaces.IncomingEventInterface) []map[string]interface{} {
+		documents := []map[string]interface{}{}
+		for _, e := range events {
+			documents = append(documents, map[string]interface{}{"_id": e.(models.IncomingEvent).TargetId})
+		}
+		return documents
+	}, nil)
+	documentStores["default"] = batchDocumentStore
+	batchers := map[string]interfaces.DocumentStoreV2Interface{
+		"default": document_stores_internal.NewDocumentBatcher(batchDocumentStore, 50*time.Millisecond, 0),
+	}
+
+	throttler.On("CanTrigger", mock.Anything, mock.Anything).Return(false, 500*time.Millisecond, nil)
+
+	// events of different targets dispatched concurrently, as by Captin.Execute
+	ids := []string{"p1", "p2", "p3"}
+	done := make(chan bool, len(ids))
+	for _, id := range ids {
+		go func(id string) {
+			_, _, sender, dispatcher, _ := setup("fixtures/config.keep_throttled_documents.json")
+			sender.On("SendEvent", mock.Anything, mock.Anything).Return(nil)
+			dispatcher.SetDocumentBatchers(batchers)
+			dispatcher.Dispatch(models.IncomingEvent{
+				Key:        "product.update",
+				Source:     "core",
+				TargetType: "Product",
+				TargetId:   id,
+			}, store, throttler, documentStores)
+			done <- true
+		}(id)
+	}
+	for range ids {
+		<-done
+	}
+
+	batchDocumentStore.AssertNumberOfCalls(t, "GetDocuments", 1)
+	batchDocumentStore.AssertNotCalled(t, "GetDocument", mock.Anything)
+	for _, id := range ids {
+		throttledDocuments, _, _, _ := store.GetQueue(fmt.Sprintf(`product\.update.service_one.%s-throttled_documents`, id))
+		assert.Equal(t, []string{fmt.Sprintf(`{"_id":"%s"}`, id)}, throttledDocuments)
+	}
+}
+
+func TestDispatchEvents_Throttle_Strategy(t *testing.T) {
+	_, documentStores, sender, dispatcher, _ := setup("fixtures/config.throttle_strategy.json")
+	store := stores.NewMemoryStore()
+	throttler := throttles.NewStrategyThrottler(store)
+
+	sender.On("SendEvent", mock.Anything, mock.Anything).Return(nil)
+
+	for i := 0; i < 5; i++ {
+		dispatcher.Dispatch(models.IncomingEvent{
+			Key:        "product.update",
+			Source:     "core",
+			TargetType: "Product",
+			TargetId:   "product_id",
+		}, store, throttler, documentStores)
+	}
+
+	// 2 events in throttle period with sliding window
+	sender.AssertNumberOfCalls(t, "SendEvent", 2)
+}
+
+func TestDispatchEvents_Throttled_Concurrent(t *testing.T) {
+	_, documentStores, sender, _, throttler := setup("fixtures/config.json")
+	store := stores.NewMemoryStore()
+
+	sender.On("SendEvent", mock.Anything, mock.Anything).Return(nil)
+	throttler.On("CanTrigger", mock.Anything, mock.Anything).Return(false, 200*time.Millisecond, nil)
+
+	// throttled events on the same key from many dispatchers schedule a single trailing send
+	wg := sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dispatcher := outgoing.NewDispatcherWithDestinations(
+				[]models.Destination{{Config: models.Configuration{Name: "service_one", Throttle: "200ms", Sender: "mock"}}},
+				map[string]interfaces.EventSenderInterface{"mock": sender},
+			)
+			dispatcher.Dispatch(models.IncomingEvent{
+				Key:        "product.update",
+				Source:     "core",
+				TargetType: "Product",
+				TargetId:   "product_id",
+			}, store, throttler, documentStores)
+		}()
+	}
+	wg.Wait()
+
+	time.Sleep(300 * time.Millisecond)
+	sender.AssertNumberOfCalls(t, "SendEvent", 1)
+}
+
+func TestDispatchEvents_Debounce(t *testing.T) {
+	_, documentStores, sender, dispatcher, throttler := setup("fixtures/config.debounce.json")
+	store := stores.NewMemoryStore()
+
+	sender.On("SendEvent", mock.Anything, mock.Anything).Return(nil)
+
+	for i := 1; i <= 3; i++ {
+		dispatcher.Dispatch(models.IncomingEvent{
+			Key:        "product.update",
+			Source:     "core",
+			Payload:    map[string]interface{}{"field1": i},
+			TargetType: "Product",
+			TargetId:   "product_id",
+		}, store, throttler, documentStores)
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// timer is reset by every event
+	sender.AssertNumberOfCalls(t, "SendEvent", 0)
+
+	time.Sleep(200 * time.Millisecond)
+
+	sender.AssertNumberOfCalls(t, "SendEvent", 1)
+	sender.AssertCalled(t, "SendEvent", mock.MatchedBy(func(e models.IncomingEvent) bool {
+		return fmt.Sprint(e.Payload) == fmt.Sprint(map[string]interface{}{"field1": 3}) &&
+			fmt.Sprint(e.ThrottledPayloads) == fmt.Sprint([]map[string]interface{}{
+				{"field1": 1},
+				{"field1": 2},
+				{"field1": 3},
+			})
+	}), mock.Anything)
+
+	// it should clean up after sendEvent
+	_, storedEventExists, _, _ := store.Get(`product\.update.service_one.product_id-data`)
+	_, debounceExists, _, _ := store.Get(`product\.update.service_one.product_id-debounce`)
+	assert.False(t, storedEventExists)
+	assert.False(t, debounceExists)
+	throttler.AssertNotCalled(t, "CanTrigger", mock.Anything, mock.Anything)
+}
+
+func TestDispatchEvents_Debounce_MaxWait(t *testing.T) {
+	_, documentStores, sender, dispatcher, throttler := setup("fixtures/config.debounce.json")
+	store := stores.NewMemoryStore()
+
+	sender.On("SendEvent", mock.Anything, mock.Anything).Return(nil)
+
+	// events keep arriving within debounce period for longer than max_wait
+	for i := 1; i <= 7; i++ {
+		dispatcher.Dispatch(models.IncomingEvent{
+			Key:        "product.update",
+			Source:     "core",
+			Payload:    map[string]interface{}{"field1": i},
+			TargetType: "Product",
+			TargetId:   "product_id",
+		}, store, throttler, documentStores)
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// sent once on reaching max_wait
+	sender.AssertNumberOfCalls(t, "SendEvent", 1)
+	sender.AssertCalled(t, "SendEvent", mock.MatchedBy(func(e models.IncomingEvent) bool {
+		return fmt.Sprint(e.Payload) == fmt.Sprint(map[string]interface{}{"field1": 5})
+	}), mock.Anything)
+
+	time.Sleep(200 * time.Millisecond)
+
+	// rest of events are sent after debounce period
+	sender.AssertNumberOfCalls(t, "SendEvent", 2)
+	sender.AssertCalled(t, "SendEvent", mock.MatchedBy(func(e models.IncomingEvent) bool {
+		return fmt.Sprint(e.Payload) == fmt.Sprint(map[string]interface{}{"field1": 7}) &&
+			fmt.Sprint(e.ThrottledPayloads) == fmt.Sprint([]map[string]interface{}{
+				{"field1": 6},
+				{"field1": 7},
+			})
+	}), mock.Anything)
+}
+
+// racingDebounceStore - Memory store where another replica saves its debounce token right before the first SetNX
+type racingDebounceStore struct {
+	*stores.MemoryStore
+	firstAt time.Time
+	raced   bool
+}
+
+func (s *racingDebounceStore) SetNX(key string, value string, ttl time.Duration) (bool, error) {
+	if strings.HasSuffix(key, "-debounce") && !s.raced {
+		s.raced = true
+		s.MemoryStore.Set(key, fmt.Sprintf("other:%d", s.firstAt.UnixNano()), ttl)
+		return false, nil
+	}
+	return s.MemoryStore.SetNX(key, value, ttl)
+}
+
+func TestDispatchEvents_Debounce_ConcurrentToken(t *testing.T) {
+	_, documentStores, sender, dispatcher, throttler := setup("fixtures/config.debounce.json")
+	store := &racingDebounceStore{MemoryStore: stores.NewMemoryStore(), firstAt: time.Now().Add(-450 * time.Millisecond)}
+
+	sender.On("SendEvent", mock.Anything, mock.Anything).Return(nil)
+
+	dispatcher.Dispatch(models.IncomingEvent{
+		Key:        "product.update",
+		Source:     "core",
+		Payload:    map[string]interface{}{"field1": 1},
+		TargetType: "Product",
+		TargetId:   "product_id",
+	}, store, throttler, documentStores)
+	time.Sleep(100 * time.Millisecond)
+
+	// first debounced event of other replica is kept, so max_wait is already reached
+	assert.True(t, store.raced)
+	sender.AssertNumberOfCalls(t, "SendEvent", 1)
+}
+
+func TestDispatchEvents_Throttle_Edges(t *testing.T) {
+	enabled, disabled := true, false
+	cases := []struct {
+		name            string
+		config          models.Configuration
+		leadingCalls    int
+		trailingPayload map[string]interface{}
+	}{
+		{"Both", models.Configuration{}, 1, map[string]interface{}{"field1": 3}},
+		{"LeadingOnly", models.Configuration{ThrottleTrailingDisabled: true}, 1, nil},
+		{"LeadingOnly_IgnoreLeadingDisabled", models.Configuration{ThrottleLeading: &disabled, ThrottleTrailingDisabled: true}, 1, nil},
+		{"TrailingOnly", models.Configuration{ThrottleLeading: &disabled}, 0, map[string]interface{}{"field1": 3}},
+		{"LeadingEnabled", models.Configuration{ThrottleLeading: &enabled}, 1, map[string]interface{}{"field1": 3}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := stores.NewMemoryStore()
+			defer store.Close()
+			throttler := throttles.NewThrottler(store)
+			sender := new(mocks.SenderMock)
+			sender.On("SendEvent", mock.Anything, mock.Anything).Return(nil)
+
+			config := c.config
+			config.Name = "service_one"
+			config.Throttle = "200ms"
+			config.KeepThrottledPayloads = true
+			config.Sender = "mock"
+			dispatcher := outgoing.NewDispatcherWithDestinations(
+				[]models.Destination{{Config: config}},
+				map[string]interfaces.EventSenderInterface{"mock": sender},
+			)
+
+			for i := 1; i <= 3; i++ {
+				dispatcher.Dispatch(models.IncomingEvent{
+					Key:        "product.update",
+					Source:     "core",
+					Payload:    map[string]interface{}{"field1": i},
+					TargetType: "Product",
+					TargetId:   "product_id",
+				}, store, throttler, map[string]interfaces.DocumentStoreInterface{})
+			}
+
+			sender.AssertNumberOfCalls(t, "SendEvent", c.leadingCalls)
+			if c.leadingCalls > 0 {
+				sender.AssertCalled(t, "SendEvent", mock.MatchedBy(func(e models.IncomingEvent) bool {
+					return fmt.Sprint(e.Payload) == fmt.Sprint(map[string]interface{}{"field1": 1})
+				}), mock.Anything)
+			}
+
+			time.Sleep(300 * time.Millisecond)
+
+			if c.trailingPayload == nil {
+				sender.AssertNumberOfCalls(t, "SendEvent", c.leadingCalls)
+				return
+			}
+			sender.AssertNumberOfCalls(t, "SendEvent", c.leadingCalls+1)
+			sender.AssertCalled(t, "SendEvent", mock.MatchedBy(func(e models.IncomingEvent) bool {
+				return fmt.Sprint(e.Payload) == fmt.Sprint(c.trailingPayload)
+			}), mock.Anything)
+		})
+	}
+}
+
+func TestDispatchEvents_Throttle_TrailingOnly_MergedPayloads(t *testing.T) {
+	_, documentStores, sender, _, _ := setup("fixtures/config.json")
+	store := stores.NewMemoryStore()
+	throttler := throttles.NewThrottler(store)
+	sender.On("SendEvent", mock.Anything, mock.Anything).Return(nil)
+
+	disabled := false
+	dispatcher := outgoing.NewDispatcherWithDestinations(
+		[]models.Destination{{Config: models.Configuration{
+			Name: "service_one", Throttle: "200ms", ThrottleLeading: &disabled, KeepThrottledPayloads: true, Sender: "mock",
+		}}},
+		map[string]interfaces.EventSenderInterface{"mock": sender},
+	)
+
+	for i := 1; i <= 3; i++ {
+		dispatcher.Dispatch(models.IncomingEvent{
+			Key:        "product.update",
+			Source:     "core",
+			Payload:    map[string]interface{}{"field1": i},
+			TargetType: "Product",
+			TargetId:   "product_id",
+		}, store, throttler, documentStores)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+
+	// the whole burst is sent once at the end of throttle period
+	sender.AssertNumberOfCalls(t, "SendEvent", 1)
+	sender.AssertCalled(t, "SendEvent", mock.MatchedBy(func(e models.IncomingEvent) bool {
+		return fmt.Sprint(e.ThrottledPayloads) == fmt.Sprint([]map[string]interface{}{
+			{"field1": 1},
+			{"field1": 2},
+			{"field1": 3},
+		})
+	}), mock.Anything)
+}
+
+func TestDispatchEvents_Throttle_Key(t *testing.T) {
+	_, documentStores, sender, _, _ := setup("fixtures/config.json")
+	store := stores.NewMemoryStore()
+	throttler := throttles.NewThrottler(store)
+	sender.On("SendEvent", mock.Anything, mock.Anything).Return(nil)
+
+	dispatcher := outgoing.NewDispatcherWithDestinations(
+		[]models.Destination{{Config: models.Configuration{
+			Name: "service_one", Throttle: "200ms", ThrottleKey: "payload.shop_id", Sender: "mock",
+		}}},
+		map[string]interfaces.EventSenderInterface{"mock": sender},
+	)
+
+	// events of different actions and targets in the same shop are throttled together
+	for _, event := range []models.IncomingEvent{
+		{Key: "product.create", TargetId: "product_1", Payload: map[string]interface{}{"shop_id": "shop_1"}},
+		{Key: "product.update", TargetId: "product_2", Payload: map[string]interface{}{"shop_id": "shop_1"}},
+		{Key: "product.update", TargetId: "product_3", Payload: map[string]interface{}{"shop_id": "shop_2"}},
+	} {
+		dispatcher.Dispatch(event, store, throttler, documentStores)
+	}
+
+	sender.AssertNumberOfCalls(t, "SendEvent", 2)
+	_, exists, _, _ := store.Get("service_one.shop_1-data")
+	assert.True(t, exists)
+
+	time.Sleep(300 * time.Millisecond)
+
+	sender.AssertNumberOfCalls(t, "SendEvent", 3)
+	sender.AssertCalled(t, "SendEvent", mock.MatchedBy(func(e models.IncomingEvent) bool {
+		return e.TargetId == "product_2"
+	}), mock.Anything)
+}
+
+func TestDispatchEvents_Throttled_MergePayloads(t *testing.T) {
+	cases := []struct {
+		strategy          string
+		limit             int
+		payload           map[string]interface{}
+		throttledPayloads []map[string]interface{}
+	}{
+		{models.ThrottledPayloadsMergeNone, 0, map[string]interface{}{"price": map[string]interface{}{"amount": 3}}, nil},
+		{models.ThrottledPayloadsMergeDeep, 0, map[string]interface{}{"name": "a", "price": map[string]interface{}{"amount": 3, "currency": "USD"}, "stock": 2}, nil},
+		{models.ThrottledPayloadsMergeUnion, 0, map[string]interface{}{"name": "a", "price": map[string]interface{}{"amount": 3}, "stock": 2}, nil},
+		{models.ThrottledPayloadsMergeKeepFirst, 0, map[string]interface{}{"name": "a", "price": map[string]interface{}{"amount": 1, "currency": "USD"}}, nil},
+		{models.ThrottledPayloadsMergeKeepLast, 0, map[string]interface{}{"price": map[string]interface{}{"amount": 3}}, nil},
+		// only the latest payloads are kept in store and merged
+		{models.ThrottledPayloadsMergeDeep, 2, map[string]interface{}{"price": map[string]interface{}{"amount": 3}, "stock": 2}, []map[string]interface{}{
+			{"price": map[string]interface{}{"amount": 2}, "stock": 2},
+			{"price": map[string]interface{}{"amount": 3}},
+		}},
+		{models.ThrottledPayloadsMergeKeepFirst, 1, map[string]interface{}{"price": map[string]interface{}{"amount": 3}}, []map[string]interface{}{
+			{"price": map[string]interface{}{"amount": 3}},
+		}},
+	}
+	payloads := []map[string]interface{}{
+		{"name": "a", "price": map[string]interface{}{"amount": 1, "currency": "USD"}},
+		{"price": map[string]interface{}{"amount": 2}, "stock": 2},
+		{"price": map[string]interface{}{"amount": 3}},
+	}
+
+	for _, c := range cases {
+		t.Run(fmt.Sprintf("%s_%d", c.strategy, c.limit), func(t *testing.T) {
+			store := stores.NewMemoryStore()
+			defer store.Close()
+			throttler := new(mocks.ThrottleMock)
+			throttler.On("CanTrigger", mock.Anything, mock.Anything).Return(false, 100*time.Millisecond, nil)
+			sender := new(mocks.SenderMock)
+			sender.On("SendEvent", mock.Anything, mock.Anything).Return(nil)
+
+			dispatcher := outgoing.NewDispatcherWithDestinations(
+				[]models.Destination{{Config: models.Configuration{
+					Name: "service_one", Throttle: "100ms", KeepThrottledPayloads: true, Sender: "mock",
+					ThrottledPayloadsMerge: c.strategy, ThrottledPayloadsLimit: c.limit,
+				}}},
+				map[string]interfaces.EventSenderInterface{"mock": sender},
+			)
+			for _, payload := range payloads {
+				dispatcher.Dispatch(models.IncomingEvent{
+					Key:        "product.update",
+					Source:     "core",
+					Payload:    payload,
+					TargetType: "Product",
+					TargetId:   "product_id",
+				}, store, throttler, map[string]interfaces.DocumentStoreInterface{})
+			}
+
+			time.Sleep(200 * time.Millisecond)
+
+			throttledPayloads := c.throttledPayloads
+			if throttledPayloads == nil {
+				throttledPayloads = payloads
+			}
+			sender.AssertNumberOfCalls(t, "SendEvent", 1)
+			sender.AssertCalled(t, "SendEvent", mock.MatchedBy(func(e models.IncomingEvent) bool {
+				return fmt.Sprint(e.Payload) == fmt.Sprint(c.payload) &&
+					fmt.Sprint(e.ThrottledPayloads) == fmt.Sprint(throttledPayloads)
+			}), mock.Anything)
+		})
+	}
+}
+
+func TestDispatchEvents_Throttled_PayloadsLimit_UnboundedStore(t *testing.T) {
+	memoryStore := stores.NewMemoryStore()
+	defer memoryStore.Close()
+	// store without EnqueueWithLimit keeps every payload, only the latest are merged and sent
+	store := struct{ interfaces.StoreInterface }{memoryStore}
+	throttler := new(mocks.ThrottleMock)
+	throttler.On("CanTrigger", mock.Anything, mock.Anything).Return(false, 100*time.Millisecond, nil)
+	sender := new(mocks.SenderMock)
+	sender.On("SendEvent", mock.Anything, mock.Anything).Return(nil)
+
+	dispatcher := outgoing.NewDispatcherWithDestinations(
+		[]models.Destination{{Config: models.Configuration{
+			Name: "service_one", Throttle: "100ms", KeepThrottledPayloads: true, Sender: "mock",
+			ThrottledPayloadsMerge: models.ThrottledPayloadsMergeUnion, ThrottledPayloadsLimit: 2,
+		}}},
+		map[string]interfaces.EventSenderInterface{"mock": sender},
+	)
+	for _, payload := range []map[string]interface{}{{"name": "a"}, {"stock": 2}, {"price": 3}} {
+		dispatcher.Dispatch(models.IncomingEvent{
+			Key: "product.update", Source: "core", Payload: payload, TargetType: "Product", TargetId: "product_id",
+		}, store, throttler, map[string]interfaces.DocumentStoreInterface{})
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	sender.AssertNumberOfCalls(t, "SendEvent", 1)
+	sender.AssertCalled(t, "SendEvent", mock.MatchedBy(func(e models.IncomingEvent) bool {
+		return fmt.Sprint(e.Payload) == fmt.Sprint(map[string]interface{}{"stock": 2, "price": 3}) &&
+			len(e.ThrottledPayloads) == 2
+	}), mock.Anything)
+}
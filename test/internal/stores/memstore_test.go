@@ -9,8 +9,16 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// newManualStore - MemoryStore without background sweeper, with a clock advanced by returned func
+func newManualStore() (*stores.MemoryStore, func(time.Duration)) {
+	now := time.Now()
+	ms := stores.NewMemoryStoreWithSweepInterval(0)
+	ms.SetClock(func() time.Time { return now })
+	return ms, func(d time.Duration) { now = now.Add(d) }
+}
+
 func TestStoreWithTTL(t *testing.T) {
-	ms := stores.NewMemoryStore()
+	ms, advance := newManualStore()
 	for i := 0; i < 10000; i++ {
 		k, v := fmt.Sprint("key", i), fmt.Sprint("value", i)
 		result, err := ms.Set(k, v, 200*time.Millisecond)
@@ -18,6 +26,245 @@ func TestStoreWithTTL(t *testing.T) {
 		assert.True(t, result)
 	}
 	assert.Equal(t, 10000, ms.Len())
-	time.Sleep(300 * time.Millisecond)
+	advance(300 * time.Millisecond)
+	ms.Sweep()
+	assert.Equal(t, 0, ms.Len())
+}
+
+func TestStoreSweeper(t *testing.T) {
+	ms := stores.NewMemoryStoreWithSweepInterval(10 * time.Millisecond)
+	defer ms.Close()
+
+	ms.Set("key", "value", 10*time.Millisecond)
+	assert.Eventually(t, func() bool { return ms.Len() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestStoreSetNX(t *testing.T) {
+	ms := stores.NewMemoryStore()
+
+	result, err := ms.SetNX("key", "first", time.Minute)
+	assert.Nil(t, err)
+	assert.True(t, result)
+
+	result, err = ms.SetNX("key", "second", time.Minute)
+	assert.Nil(t, err)
+	assert.False(t, result)
+
+	value, _, _, _ := ms.Get("key")
+	assert.Equal(t, "first", value)
+}
+
+func TestStoreIncr(t *testing.T) {
+	ms := stores.NewMemoryStore()
+
+	count, err := ms.Incr("counter", 2, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), count)
+
+	count, err = ms.Incr("counter", -1, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), count)
+
+	ms.Set("text", "value", time.Minute)
+	_, err = ms.Incr("text", 1, time.Minute)
+	assert.EqualError(t, err, "MemoryStore: value of text is not an integer")
+}
+
+func TestStoreCompareAndSwap(t *testing.T) {
+	ms := stores.NewMemoryStore()
+
+	result, err := ms.CompareAndSwap("key", "", "value", time.Minute)
+	assert.Nil(t, err)
+	assert.False(t, result)
+
+	ms.Set("key", "old", time.Minute)
+	result, _ = ms.CompareAndSwap("key", "other", "value", time.Minute)
+	assert.False(t, result)
+	result, _ = ms.CompareAndSwap("key", "old", "value", time.Minute)
+	assert.True(t, result)
+
+	value, _, _, _ := ms.Get("key")
+	assert.Equal(t, "value", value)
+}
+
+func TestStoreRemainingTime(t *testing.T) {
+	ms, advance := newManualStore()
+
+	ms.Set("key", "value", time.Second)
+	ms.Enqueue("queue", "1", time.Second)
+	advance(100 * time.Millisecond)
+
+	_, _, remaining, _ := ms.Get("key")
+	assert.Equal(t, 900*time.Millisecond, remaining)
+	_, _, remaining, _ = ms.GetQueue("queue")
+	assert.Equal(t, 900*time.Millisecond, remaining)
+
+	// update keeps ttl
+	ms.Update("key", "updated")
+	value, _, remaining, _ := ms.Get("key")
+	assert.Equal(t, "updated", value)
+	assert.Equal(t, 900*time.Millisecond, remaining)
+}
+
+func TestStoreExpiredOnGet(t *testing.T) {
+	// without sweeper, expired keys are not returned
+	ms, advance := newManualStore()
+
+	ms.Set("key", "value", 10*time.Millisecond)
+	ms.Enqueue("queue", "1", 10*time.Millisecond)
+	advance(20 * time.Millisecond)
+
+	_, exists, _, _ := ms.Get("key")
+	assert.False(t, exists)
+	_, exists, _, _ = ms.GetQueue("queue")
+	assert.False(t, exists)
+	result, _ := ms.SetNX("key", "value", time.Minute)
+	assert.True(t, result)
+}
+
+func TestStoreEnqueueWithLimit(t *testing.T) {
+	ms, _ := newManualStore()
+
+	for _, value := range []string{"1", "2", "3"} {
+		result, err := ms.EnqueueWithLimit("queue", value, time.Minute, 2)
+		assert.True(t, result)
+		assert.Nil(t, err)
+	}
+	values, _, remaining, _ := ms.GetQueue("queue")
+	assert.Equal(t, []string{"2", "3"}, values)
+	assert.Equal(t, time.Minute, remaining)
+
+	// unlimited without limit
+	ms.Enqueue("queue", "4", time.Minute)
+	values, _, _, _ = ms.GetQueue("queue")
+	assert.Equal(t, []string{"2", "3", "4"}, values)
+}
+
+func TestStoreWithoutTTL(t *testing.T) {
+	ms, advance := newManualStore()
+
+	ms.Set("key", "value", 0)
+	advance(time.Hour)
+	ms.Sweep()
+
+	value, exists, remaining, _ := ms.Get("key")
+	assert.Equal(t, "value", value)
+	assert.True(t, exists)
+	assert.Equal(t, time.Duration(0), remaining)
+}
+
+func TestStoreResetTTL(t *testing.T) {
+	ms, advance := newManualStore()
+
+	// expiry of the first set is moved by setting again
+	ms.Set("key", "value", 50*time.Millisecond)
+	ms.Set("key", "value", time.Minute)
+	advance(200 * time.Millisecond)
+	ms.Sweep()
+
+	_, exists, _, _ := ms.Get("key")
+	assert.True(t, exists)
+	assert.Equal(t, 1, ms.Len())
+
+	// expiry is removed by setting without ttl
+	ms.Set("key", "value", 0)
+	advance(time.Hour)
+	ms.Sweep()
+	assert.Equal(t, 1, ms.Len())
+
+	// expiry is set again after reset
+	ms.Set("key", "value", time.Second)
+	advance(2 * time.Second)
+	ms.Sweep()
 	assert.Equal(t, 0, ms.Len())
 }
+
+func TestStoreRemoveTTL(t *testing.T) {
+	ms, advance := newManualStore()
+
+	// expiry of removed key does not remove key set again
+	ms.Set("key", "value", 50*time.Millisecond)
+	ms.Remove("key")
+	ms.Set("key", "value", 0)
+	advance(200 * time.Millisecond)
+	ms.Sweep()
+
+	_, exists, _, _ := ms.Get("key")
+	assert.True(t, exists)
+
+	// expired keys removed on get can be set again
+	ms.Set("other", "value", 50*time.Millisecond)
+	advance(100 * time.Millisecond)
+	_, exists, _, _ = ms.Get("other")
+	assert.False(t, exists)
+	ms.Set("other", "value", time.Minute)
+	ms.Sweep()
+	assert.Equal(t, 2, ms.Len())
+}
+
+func TestStoreClose(t *testing.T) {
+	ms := stores.NewMemoryStore()
+	ms.Set("key", "value", 10*time.Millisecond)
+	assert.Nil(t, ms.Close())
+	assert.Nil(t, ms.Close())
+
+	time.Sleep(200 * time.Millisecond)
+	// sweeper is stopped
+	assert.Equal(t, 1, ms.Len())
+}
+
+const benchmarkKeys = 1000000
+
+func setupBenchmark(b *testing.B) (*stores.MemoryStore, []string) {
+	ms := stores.NewMemoryStore()
+	keys := make([]string, benchmarkKeys)
+	for i := range keys {
+		keys[i] = fmt.Sprint("key", i)
+		ms.Set(keys[i], "value", time.Hour)
+	}
+	b.ResetTimer()
+	return ms, keys
+}
+
+func BenchmarkMemoryStore_Get(b *testing.B) {
+	ms, keys := setupBenchmark(b)
+	defer ms.Close()
+	for i := 0; i < b.N; i++ {
+		ms.Get(keys[i%benchmarkKeys])
+	}
+}
+
+func BenchmarkMemoryStore_Set(b *testing.B) {
+	ms, keys := setupBenchmark(b)
+	defer ms.Close()
+	for i := 0; i < b.N; i++ {
+		ms.Set(keys[i%benchmarkKeys], "value", time.Hour)
+	}
+}
+
+func BenchmarkMemoryStore_SetNX_Parallel(b *testing.B) {
+	ms, keys := setupBenchmark(b)
+	defer ms.Close()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			ms.SetNX(keys[i%benchmarkKeys], "value", time.Hour)
+			i++
+		}
+	})
+}
+
+func BenchmarkMemoryStore_Expire(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		b.StopTimer()
+		ms := stores.NewMemoryStore()
+		for i := 0; i < benchmarkKeys; i++ {
+			ms.Set(fmt.Sprint("key", i), "value", 100*time.Millisecond)
+		}
+		b.StartTimer()
+		for ms.Len() > 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		ms.Close()
+	}
+}
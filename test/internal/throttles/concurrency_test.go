@@ -0,0 +1,48 @@
+package throttles_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	interfaces "github.com/shoplineapp/captin/interfaces"
+	stores "github.com/shoplineapp/captin/internal/stores"
+	throttles "github.com/shoplineapp/captin/internal/throttles"
+	"github.com/stretchr/testify/assert"
+)
+
+// triggerConcurrently - Call CanTrigger on the same key from many goroutines, return number of triggers
+func triggerConcurrently(throttler interfaces.ThrottleInterface, period time.Duration) int {
+	var count int32
+	start := make(chan bool)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if result, _, _ := throttler.CanTrigger("concurrent", period); result {
+				atomic.AddInt32(&count, 1)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+	return int(count)
+}
+
+func TestThrottler_Concurrent(t *testing.T) {
+	subject := throttles.NewThrottler(stores.NewMemoryStore())
+	assert.Equal(t, 1, triggerConcurrently(subject, time.Minute))
+}
+
+func TestTokenBucketThrottler_Concurrent(t *testing.T) {
+	subject := throttles.NewTokenBucketThrottler(stores.NewMemoryStore(), 1, 5)
+	assert.Equal(t, 5, triggerConcurrently(subject, time.Hour))
+}
+
+func TestSlidingWindowThrottler_Concurrent(t *testing.T) {
+	subject := throttles.NewSlidingWindowThrottler(stores.NewMemoryStore(), 5)
+	assert.Equal(t, 5, triggerConcurrently(subject, time.Hour))
+}
@@ -0,0 +1,44 @@
+package throttles_test
+
+import (
+	"testing"
+	"time"
+
+	stores "github.com/shoplineapp/captin/internal/stores"
+	throttles "github.com/shoplineapp/captin/internal/throttles"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlidingWindowThrottler_Limit(t *testing.T) {
+	subject := throttles.NewSlidingWindowThrottler(stores.NewMemoryStore(), 3)
+
+	for i := 0; i < 3; i++ {
+		result, _, err := subject.CanTrigger("window", time.Hour)
+		assert.True(t, result)
+		assert.Nil(t, err)
+	}
+
+	result, duration, err := subject.CanTrigger("window", time.Hour)
+	assert.False(t, result)
+	assert.Nil(t, err)
+	assert.True(t, duration > 0 && duration <= time.Hour)
+
+	// windows are per id
+	result, _, _ = subject.CanTrigger("another", time.Hour)
+	assert.True(t, result)
+}
+
+func TestSlidingWindowThrottler_Slide(t *testing.T) {
+	subject := throttles.NewSlidingWindowThrottler(stores.NewMemoryStore(), 1)
+
+	result, _, _ := subject.CanTrigger("window", 100*time.Millisecond)
+	assert.True(t, result)
+	result, duration, _ := subject.CanTrigger("window", 100*time.Millisecond)
+	assert.False(t, result)
+	assert.True(t, duration <= 100*time.Millisecond)
+
+	// previous window is weighted out after a full period
+	time.Sleep(200 * time.Millisecond)
+	result, _, _ = subject.CanTrigger("window", 100*time.Millisecond)
+	assert.True(t, result)
+}
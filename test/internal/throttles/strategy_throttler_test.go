@@ -0,0 +1,54 @@
+package throttles_test
+
+import (
+	"testing"
+	"time"
+
+	stores "github.com/shoplineapp/captin/internal/stores"
+	throttles "github.com/shoplineapp/captin/internal/throttles"
+	models "github.com/shoplineapp/captin/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func countTriggers(subject *throttles.StrategyThrottler, config models.Configuration, times int) int {
+	count := 0
+	for i := 0; i < times; i++ {
+		if result, _, _ := subject.CanTriggerDestination("strategy", models.Destination{Config: config}); result {
+			count++
+		}
+	}
+	return count
+}
+
+func TestStrategyThrottler_Period(t *testing.T) {
+	subject := throttles.NewStrategyThrottler(stores.NewMemoryStore())
+	assert.Equal(t, 1, countTriggers(subject, models.Configuration{Throttle: "1m", ThrottleLimit: 5}, 5))
+}
+
+func TestStrategyThrottler_UnknownStrategy(t *testing.T) {
+	subject := throttles.NewStrategyThrottler(stores.NewMemoryStore())
+	assert.Equal(t, 1, countTriggers(subject, models.Configuration{Throttle: "1m", ThrottleStrategy: "unknown"}, 5))
+}
+
+func TestStrategyThrottler_TokenBucket(t *testing.T) {
+	subject := throttles.NewStrategyThrottler(stores.NewMemoryStore())
+	config := models.Configuration{Throttle: "1m", ThrottleStrategy: models.ThrottleStrategyTokenBucket, ThrottleLimit: 2, ThrottleBurst: 4}
+	assert.Equal(t, 4, countTriggers(subject, config, 10))
+}
+
+func TestStrategyThrottler_SlidingWindow(t *testing.T) {
+	subject := throttles.NewStrategyThrottler(stores.NewMemoryStore())
+	config := models.Configuration{Throttle: "1h", ThrottleStrategy: models.ThrottleStrategySlidingWindow, ThrottleLimit: 3}
+	assert.Equal(t, 3, countTriggers(subject, config, 10))
+}
+
+func TestStrategyThrottler_NoThrottleSet(t *testing.T) {
+	subject := throttles.NewStrategyThrottler(stores.NewMemoryStore())
+	config := models.Configuration{ThrottleStrategy: models.ThrottleStrategySlidingWindow, ThrottleLimit: 3}
+	assert.Equal(t, 10, countTriggers(subject, config, 10))
+
+	result, duration, err := subject.CanTrigger("strategy", time.Duration(0))
+	assert.True(t, result)
+	assert.Equal(t, time.Duration(0), duration)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,63 @@
+package throttles_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	stores "github.com/shoplineapp/captin/internal/stores"
+	throttles "github.com/shoplineapp/captin/internal/throttles"
+	mocks "github.com/shoplineapp/captin/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestTokenBucketThrottler_Burst(t *testing.T) {
+	subject := throttles.NewTokenBucketThrottler(stores.NewMemoryStore(), 2, 3)
+
+	for i := 0; i < 3; i++ {
+		result, _, err := subject.CanTrigger("bucket", time.Second)
+		assert.True(t, result)
+		assert.Nil(t, err)
+	}
+
+	result, duration, err := subject.CanTrigger("bucket", time.Second)
+	assert.False(t, result)
+	assert.Nil(t, err)
+	// 2 tokens per second, a token is refilled in 500ms
+	assert.InDelta(t, float64(500*time.Millisecond), float64(duration), float64(50*time.Millisecond))
+}
+
+func TestTokenBucketThrottler_Refill(t *testing.T) {
+	subject := throttles.NewTokenBucketThrottler(stores.NewMemoryStore(), 10, 1)
+
+	result, _, _ := subject.CanTrigger("bucket", 500*time.Millisecond)
+	assert.True(t, result)
+	result, _, _ = subject.CanTrigger("bucket", 500*time.Millisecond)
+	assert.False(t, result)
+
+	time.Sleep(60 * time.Millisecond)
+	result, _, _ = subject.CanTrigger("bucket", 500*time.Millisecond)
+	assert.True(t, result)
+}
+
+func TestTokenBucketThrottler_NoThrottleSet(t *testing.T) {
+	store := new(mocks.StoreMock)
+	subject := throttles.NewTokenBucketThrottler(store, 1, 1)
+
+	result, duration, err := subject.CanTrigger("bucket", time.Duration(0))
+	assert.True(t, result)
+	assert.Equal(t, time.Duration(0), duration)
+	assert.Nil(t, err)
+	store.AssertNotCalled(t, "Get", mock.Anything)
+}
+
+func TestTokenBucketThrottler_Error(t *testing.T) {
+	store := new(mocks.StoreMock)
+	store.On("Get", "bucket").Return("", false, time.Duration(0), errors.New("some error"))
+	subject := throttles.NewTokenBucketThrottler(store, 1, 1)
+
+	result, _, err := subject.CanTrigger("bucket", time.Second)
+	assert.True(t, result)
+	assert.EqualError(t, err, "some error")
+}
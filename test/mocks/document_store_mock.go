@@ -1,6 +1,8 @@
 package mocks
 
 import (
+	"context"
+
 	"github.com/shoplineapp/captin/interfaces"
 	"github.com/shoplineapp/captin/models"
 	"github.com/stretchr/testify/mock"
@@ -19,3 +21,34 @@ func (ds *DocumentStoreMock) GetDocument(ie interfaces.IncomingEventInterface) (
 	return args.Get(0).(map[string]interface{})
 }
 
+
+// DocumentStoreV2Mock - Mock of DocumentStoreV2Interface
+type DocumentStoreV2Mock struct {
+	interfaces.DocumentStoreInterface
+	interfaces.DocumentStoreV2Interface
+	mock.Mock
+}
+
+// GetDocumentContext - Get document with error from mock
+func (ds *DocumentStoreV2Mock) GetDocumentContext(ctx context.Context, ie interfaces.IncomingEventInterface) (map[string]interface{}, error) {
+	e := ie.(models.IncomingEvent)
+	args := ds.Called(e)
+	document, _ := args.Get(0).(map[string]interface{})
+	return document, args.Error(1)
+}
+
+// BatchDocumentStoreMock - Mock of DocumentStoreInterface with BatchDocumentStoreInterface
+type BatchDocumentStoreMock struct {
+	DocumentStoreMock
+	interfaces.BatchDocumentStoreInterface
+}
+
+// GetDocuments - Get documents of events from mock
+func (ds *BatchDocumentStoreMock) GetDocuments(ctx context.Context, events []interfaces.IncomingEventInterface) ([]map[string]interface{}, error) {
+	args := ds.Called(events)
+	if fn, ok := args.Get(0).(func([]interfaces.IncomingEventInterface) []map[string]interface{}); ok {
+		return fn(events), args.Error(1)
+	}
+	documents, _ := args.Get(0).([]map[string]interface{})
+	return documents, args.Error(1)
+}
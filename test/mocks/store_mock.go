@@ -1,7 +1,6 @@
 package mocks
 
 import (
-	"fmt"
 	"time"
 
 	"github.com/shoplineapp/captin/interfaces"
@@ -51,7 +50,5 @@ func (s *StoreMock) Remove(key string) (bool, error) {
 
 // DataKey - Generate DataKey with events and destination (Won't Mock)
 func (s *StoreMock) DataKey(ie interfaces.IncomingEventInterface, idest interfaces.DestinationInterface, prefix string, suffix string) string {
-	e := ie.(models.IncomingEvent)
-	dest := idest.(models.Destination)
-	return fmt.Sprintf("%s%s.%s.%s%s", prefix, e.Key, dest.Config.GetName(), e.TargetId, suffix)
+	return models.DataKey(ie, idest, prefix, suffix)
 }
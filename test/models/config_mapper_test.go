@@ -95,3 +95,214 @@ func TestConfigsForKey(t *testing.T) {
 	assert.Contains(t, names, "0")
 	assert.Contains(t, names, "1")
 }
+
+func TestReadLocalFile_Formats(t *testing.T) {
+	for _, file := range []string{"fixtures/config_list.yaml", "fixtures/config_list.toml"} {
+		t.Run(file, func(t *testing.T) {
+			subject := NewConfigurationMapperFromPath(file)
+			action := subject.ActionMap["product.update"]
+			assert.Equal(t, 2, len(action))
+			names := getNames(action)
+			assert.Contains(t, names, "sync_service")
+			assert.Contains(t, names, "sync_service2")
+			assert.Equal(t, 1, len(subject.ActionMap["order.update"]))
+
+			config := subject.ActionMap["user.create"][1].(Configuration)
+			assert.Equal(t, "sync_service2", config.Name)
+			assert.Equal(t, "2", config.ConfigID)
+			assert.Equal(t, "500ms", config.Throttle)
+			assert.Equal(t, 2, config.ThrottleLimit)
+			assert.Equal(t, []string{"product.update", "product.create", "user.create"}, config.Actions)
+		})
+	}
+
+	toml := NewConfigurationMapperFromPath("fixtures/config_list.toml")
+	assert.Equal(t, map[string]string{"region": "eu"}, toml.ActionMap["user.create"][1].GetExtras())
+}
+
+func TestReadLocalFile_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"fixtures/config_invalid_type.json":   "fixtures/config_invalid_type.json:5: invalid throttle, cannot use number as string",
+		"fixtures/config_invalid_syntax.json": "fixtures/config_invalid_syntax.json:5: invalid character '}' looking for beginning of object key string",
+		"fixtures/config_invalid_type.yaml":   "fixtures/config_invalid_type.yaml:5: invalid throttle_limit, cannot use string as int",
+		"fixtures/config_invalid_syntax.yaml": "fixtures/config_invalid_syntax.yaml:2: did not find expected ',' or ']'",
+		"fixtures/config_invalid_type.toml":   "fixtures/config_invalid_type.toml:7: invalid include_document, cannot use string as bool",
+		"fixtures/config_invalid_syntax.toml": "fixtures/config_invalid_syntax.toml:4: unterminated array",
+		"fixtures/config.json":                "fixtures/config.json:1: missing hooks",
+	}
+	for file, message := range cases {
+		t.Run(file, func(t *testing.T) {
+			assert.PanicsWithError(t, message, func() {
+				NewConfigurationMapperFromPath(file)
+			})
+		})
+	}
+}
+
+func TestReadLocalDirectory(t *testing.T) {
+	subject := NewConfigurationMapperFromPath("fixtures/config_dir")
+
+	names := getNames(subject.ActionMap["product.update"])
+	assert.Equal(t, []string{"product_sync", "search_index"}, names)
+	assert.Equal(t, 1, len(subject.ActionMap["order.create"]))
+
+	// hook fields override defaults of file, which override defaults of directory
+	order := subject.ActionMap["order.create"][0].(Configuration)
+	assert.Equal(t, "sqs", order.Sender)
+	assert.Equal(t, "1s", order.Throttle)
+	assert.Equal(t, map[string]string{"team": "core"}, order.Extras)
+
+	product := subject.ActionMap["product.create"][0].(Configuration)
+	assert.Equal(t, "http", product.Sender)
+	assert.Equal(t, "500ms", product.Throttle)
+	assert.Equal(t, map[string]string{"team": "core", "owner": "catalog"}, product.Extras)
+
+	// included files have their own defaults
+	search := subject.ActionMap["product.update"][1].(Configuration)
+	assert.Equal(t, "1s", search.Throttle)
+}
+
+func TestReadLocalDirectory_Duplicate(t *testing.T) {
+	assert.PanicsWithError(t, "fixtures/config_dir_duplicate/b.yaml:4: duplicate hook name \"product_sync\", already defined at fixtures/config_dir_duplicate/a.yaml:2", func() {
+		NewConfigurationMapperFromPath("fixtures/config_dir_duplicate")
+	})
+}
+
+func TestReadLocalFile_IncludeCycle(t *testing.T) {
+	pwd, _ := os.Getwd()
+	a := filepath.Join(pwd, "fixtures/config_include_cycle/a.yaml")
+	b := filepath.Join(pwd, "fixtures/config_include_cycle/b.yaml")
+	assert.PanicsWithError(t, "include cycle "+a+" -> "+b+" -> "+a, func() {
+		NewConfigurationMapperFromPath("fixtures/config_include_cycle/a.yaml")
+	})
+}
+
+func TestLoadConfigurationMapper(t *testing.T) {
+	subject, err := LoadConfigurationMapper("fixtures/config_list.yaml")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(subject.Configs()))
+
+	_, err = LoadConfigurationMapper("fixtures/config_invalid_type.json")
+	assert.EqualError(t, err, "fixtures/config_invalid_type.json:5: invalid throttle, cannot use number as string")
+
+	_, err = LoadConfigurationMapper("fixtures/missing.json")
+	assert.Error(t, err)
+}
+
+func TestLoadConfigurationMapper_Invalid(t *testing.T) {
+	_, err := LoadConfigurationMapper("fixtures/config_invalid_values.yaml")
+	errors, ok := err.(ConfigurationErrors)
+	assert.True(t, ok)
+	assert.Equal(t, []string{
+		"hook \"product_sync\": delay: invalid time value \"5x\", expected number with unit ms, s, m or h",
+		"hook \"product_sync\": retry_backoff: invalid seconds \"soon\"",
+		"hook \"product_sync\": validate: (anonymous): Line 1:20 Unexpected end of input",
+		"hook #1: name: is required",
+		"hook #1: throttle_strategy: unknown strategy \"burst\"",
+		"hook #1: callback_url: invalid url, scheme and host are required",
+	}, messages(errors))
+}
+
+func messages(errors ConfigurationErrors) []string {
+	result := []string{}
+	for _, err := range errors {
+		result = append(result, err.Error())
+	}
+	return result
+}
+
+func TestConfigsForKey_Patterns(t *testing.T) {
+	configs := []interfaces.ConfigurationInterface{
+		Configuration{Name: "exact", Actions: []string{"product.update"}},
+		Configuration{Name: "product", Actions: []string{"product.*", "!product.view"}},
+		Configuration{Name: "delete", Actions: []string{"*.delete"}},
+		Configuration{Name: "all", Actions: []string{"**", "!*.view", "product.view"}},
+		Configuration{Name: "nested", Actions: []string{"shop.**.update", "!shop.settings.**"}},
+	}
+	subject := NewConfigurationMapper(configs)
+
+	cases := map[string][]string{
+		"product.update":             {"exact", "product", "all"},
+		"product.view":               {"all"},
+		"product.delete":             {"product", "delete", "all"},
+		"order.delete":               {"delete", "all"},
+		"order.view":                 {},
+		"product.variation.update":   {"all"},
+		"shop.update":                {"all", "nested"},
+		"shop.domain.ssl.update":     {"all", "nested"},
+		"shop.settings.update":       {"all"},
+		"shop.settings.theme.update": {"all"},
+	}
+	for key, expected := range cases {
+		assert.Equal(t, expected, getNames(subject.ConfigsForKey(key)), key)
+	}
+
+	// exact actions of hooks without patterns are mapped
+	assert.Equal(t, []string{"exact"}, getNames(subject.ActionMap["product.update"]))
+}
+
+func TestConfigsForKey_InvalidPattern(t *testing.T) {
+	subject := NewConfigurationMapper([]interfaces.ConfigurationInterface{
+		Configuration{Name: "invalid", Actions: []string{"product*", "order.*"}},
+	})
+	assert.Equal(t, []string{}, getNames(subject.ConfigsForKey("product.update")))
+	assert.Equal(t, []string{"invalid"}, getNames(subject.ConfigsForKey("order.create")))
+
+	err := ValidateConfigurations(subject.Configs(), ConfigurationRules{})
+	assert.EqualError(t, err, "hook \"invalid\": actions: invalid action pattern \"product*\"")
+}
+
+func TestActionMatcher(t *testing.T) {
+	subject, err := NewActionMatcher([]string{"**.delete", "!order.delete", "product.update"})
+	assert.Nil(t, err)
+	assert.True(t, subject.Match("product.delete"))
+	assert.True(t, subject.Match("product.variant.delete"))
+	assert.True(t, subject.Match("product.update"))
+	assert.False(t, subject.Match("order.delete"))
+	assert.False(t, subject.Match("product.create"))
+
+	_, err = NewActionMatcher([]string{"product*"})
+	assert.EqualError(t, err, "invalid action pattern \"product*\"")
+}
+
+func TestConfigsForTenantKey(t *testing.T) {
+	subject := NewConfigurationMapper([]interfaces.ConfigurationInterface{
+		Configuration{Name: "merchant_a", Actions: []string{"product.*"}, Tenant: "merchant_a"},
+		Configuration{Name: "global", Actions: []string{"product.update"}},
+		Configuration{Name: "merchant_b", Actions: []string{"product.update"}, Tenant: "merchant_b"},
+	})
+
+	// global hooks first, then hooks of tenant
+	assert.Equal(t, []string{"global", "merchant_a"}, getNames(subject.ConfigsForTenantKey("merchant_a", "product.update")))
+	assert.Equal(t, []string{"merchant_a"}, getNames(subject.ConfigsForTenantKey("merchant_a", "product.create")))
+	assert.Equal(t, []string{"global", "merchant_b"}, getNames(subject.ConfigsForTenantKey("merchant_b", "product.update")))
+	assert.Equal(t, []string{"global"}, getNames(subject.ConfigsForTenantKey("merchant_c", "product.update")))
+
+	// hooks of tenants are excluded without tenant
+	assert.Equal(t, []string{"global"}, getNames(subject.ConfigsForKey("product.update")))
+	assert.Equal(t, 3, len(subject.Configs()))
+}
+
+func TestLoadConfigurationMapper_Tenants(t *testing.T) {
+	subject, err := LoadConfigurationMapper("fixtures/config_tenants.yaml")
+	assert.Nil(t, err)
+
+	configs := subject.ConfigsForTenantKey("merchant_a", "product.update")
+	assert.Equal(t, []string{"product_sync", "product_sync"}, getNames(configs))
+	assert.Equal(t, "", TenantOf(configs[0]))
+	assert.Equal(t, "merchant_a", TenantOf(configs[1]))
+}
+
+func BenchmarkConfigsForKey_Exact(b *testing.B) {
+	subject := NewConfigurationMapper(setup())
+	for i := 0; i < b.N; i++ {
+		subject.ConfigsForKey("action:0")
+	}
+}
+
+func BenchmarkConfigsForKey_Patterns(b *testing.B) {
+	subject := NewConfigurationMapper(append(setup(), Configuration{Name: "3", Actions: []string{"action.*", "!action.1"}}))
+	for i := 0; i < b.N; i++ {
+		subject.ConfigsForKey("action.0")
+	}
+}
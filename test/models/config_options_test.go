@@ -0,0 +1,76 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	interfaces "github.com/shoplineapp/captin/interfaces"
+	. "github.com/shoplineapp/captin/models"
+)
+
+// plainConfiguration - Configuration implementing ConfigurationInterface only
+type plainConfiguration struct {
+	interfaces.ConfigurationInterface
+}
+
+func TestPayloadSchemaOf(t *testing.T) {
+	assert.Equal(t, "product", PayloadSchemaOf(Configuration{PayloadSchema: "product"}))
+	assert.Equal(t, "", PayloadSchemaOf(plainConfiguration{}))
+}
+
+func TestDocumentOptionsOf(t *testing.T) {
+	config := Configuration{DocumentTimeout: "1s", OnDocumentError: DocumentPolicySkip}
+	assert.Equal(t, time.Second, DocumentOptionsOf(config).GetDocumentTimeoutValue())
+	assert.Equal(t, DocumentPolicySkip, DocumentOptionsOf(config).GetOnDocumentNotFound())
+
+	// defaults for configurations without options
+	assert.Equal(t, time.Duration(0), DocumentOptionsOf(plainConfiguration{}).GetDocumentTimeoutValue())
+	assert.Equal(t, DocumentPolicyError, DocumentOptionsOf(plainConfiguration{}).GetOnDocumentNotFound())
+}
+
+func TestThrottleStrategyOptionsOf(t *testing.T) {
+	config := Configuration{ThrottleStrategy: ThrottleStrategyTokenBucket, ThrottleLimit: 2}
+	assert.Equal(t, ThrottleStrategyTokenBucket, ThrottleStrategyOptionsOf(config).GetThrottleStrategy())
+	assert.Equal(t, 2, ThrottleStrategyOptionsOf(config).GetThrottleBurst())
+
+	// defaults for configurations without options
+	assert.Equal(t, ThrottleStrategyPeriod, ThrottleStrategyOptionsOf(plainConfiguration{}).GetThrottleStrategy())
+	assert.Equal(t, 1, ThrottleStrategyOptionsOf(plainConfiguration{}).GetThrottleLimit())
+}
+
+func TestDebounceOptionsOf(t *testing.T) {
+	config := Configuration{Debounce: "1s", MaxWait: "5s"}
+	assert.Equal(t, time.Second, DebounceOptionsOf(config).GetDebounceValue())
+	assert.Equal(t, 5*time.Second, DebounceOptionsOf(config).GetMaxWaitValue())
+
+	// not debounced for configurations without options
+	assert.Equal(t, time.Duration(0), DebounceOptionsOf(plainConfiguration{}).GetDebounceValue())
+}
+
+func TestThrottleLeadingOf(t *testing.T) {
+	leading := false
+	assert.False(t, ThrottleLeadingOf(Configuration{ThrottleLeading: &leading}))
+	assert.True(t, ThrottleLeadingOf(plainConfiguration{}))
+}
+
+func TestThrottleKeyOf(t *testing.T) {
+	assert.Equal(t, "target_id", ThrottleKeyOf(Configuration{ThrottleKey: "target_id"}))
+	assert.Equal(t, "", ThrottleKeyOf(plainConfiguration{}))
+}
+
+func TestThrottledPayloadsOptionsOf(t *testing.T) {
+	config := Configuration{ThrottledPayloadsMerge: ThrottledPayloadsMergeDeep, ThrottledPayloadsLimit: 3}
+	assert.Equal(t, ThrottledPayloadsMergeDeep, ThrottledPayloadsOptionsOf(config).GetThrottledPayloadsMerge())
+	assert.Equal(t, 3, ThrottledPayloadsOptionsOf(config).GetThrottledPayloadsLimit())
+
+	// kept as they are for configurations without options
+	assert.Equal(t, "", ThrottledPayloadsOptionsOf(plainConfiguration{}).GetThrottledPayloadsMerge())
+	assert.Equal(t, 0, ThrottledPayloadsOptionsOf(plainConfiguration{}).GetThrottledPayloadsLimit())
+}
+
+func TestTenantOf(t *testing.T) {
+	assert.Equal(t, "merchant_a", TenantOf(Configuration{Tenant: "merchant_a"}))
+	assert.Equal(t, "", TenantOf(plainConfiguration{}))
+}
@@ -60,8 +60,61 @@ func TestGetDelay(t *testing.T) {
 	assert.Equal(t, subject.GetDelayValue(), time.Duration(3)*time.Hour)
 }
 
+func TestGetDebounce(t *testing.T) {
+	subject := Configuration{}
+	assert.Equal(t, subject.GetDebounceValue(), time.Duration(0))
+	assert.Equal(t, subject.GetMaxWaitValue(), time.Duration(0))
+
+	subject.Debounce = "5s"
+	subject.MaxWait = "1m"
+	assert.Equal(t, subject.GetDebounceValue(), time.Duration(5)*time.Second)
+	assert.Equal(t, subject.GetMaxWaitValue(), time.Duration(1)*time.Minute)
+}
+
+func TestGetThrottleLeading(t *testing.T) {
+	enabled, disabled := true, false
+	subject := Configuration{}
+	assert.True(t, subject.GetThrottleLeading())
+
+	subject.ThrottleLeading = &enabled
+	assert.True(t, subject.GetThrottleLeading())
+
+	subject.ThrottleLeading = &disabled
+	assert.False(t, subject.GetThrottleLeading())
+
+	// leading edge cannot be disabled along with trailing edge
+	subject.ThrottleTrailingDisabled = true
+	assert.True(t, subject.GetThrottleLeading())
+}
+
 func TestConfiguration_GetDocumentStore(t *testing.T) {
 	subject := Configuration{}
 	subject.DocumentStore = "another"
 	assert.Equal(t, subject.DocumentStore, "another")
 }
+
+func TestParseTimeValue(t *testing.T) {
+	cases := map[string]time.Duration{
+		"":      0,
+		"500ms": 500 * time.Millisecond,
+		"1.5s":  1500 * time.Millisecond,
+		"0.5h":  30 * time.Minute,
+		"1m30s": 90 * time.Second,
+	}
+	for value, expected := range cases {
+		result, err := ParseTimeValue(value)
+		assert.Nil(t, err, value)
+		assert.Equal(t, expected, result, value)
+	}
+
+	for _, value := range []string{"5", "5x", "1.5.5s", "-1s", "5 s"} {
+		_, err := ParseTimeValue(value)
+		assert.EqualError(t, err, "invalid time value \""+value+"\", expected number with unit ms, s, m or h")
+	}
+
+	// invalid values are not throttled instead of panic
+	subject := Configuration{Throttle: "5d"}
+	assert.NotPanics(t, func() {
+		assert.Equal(t, time.Duration(0), subject.GetThrottleValue())
+	})
+}
@@ -0,0 +1,67 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	interfaces "github.com/shoplineapp/captin/interfaces"
+	. "github.com/shoplineapp/captin/models"
+	schemas "github.com/shoplineapp/captin/schemas"
+)
+
+func TestValidateConfigurations(t *testing.T) {
+	configs := []interfaces.ConfigurationInterface{
+		Configuration{
+			Name:          "product_sync",
+			Actions:       []string{"product.update"},
+			CallbackURL:   "https://localhost/sync",
+			Throttle:      "500ms",
+			RetryBackoff:  "5,10,60",
+			Validate:      "document.shop_id == 'shop'",
+			ThrottleKey:   "payload.shop_id",
+			DocumentStore: "mongo",
+		},
+	}
+	assert.Nil(t, ValidateConfigurations(configs, ConfigurationRules{}))
+	assert.Nil(t, ValidateConfigurations(configs, ConfigurationRules{Senders: []string{"http"}, DocumentStores: []string{"mongo"}}))
+
+	err := ValidateConfigurations(configs, ConfigurationRules{Senders: []string{"sqs"}, DocumentStores: []string{"default"}})
+	assert.EqualError(t, err, "hook \"product_sync\": sender: unknown sender \"http\"; hook \"product_sync\": document_store: unknown document store \"mongo\"")
+}
+
+func TestValidateConfigurations_PayloadSchema(t *testing.T) {
+	configs := []interfaces.ConfigurationInterface{
+		Configuration{Name: "partner_sync", Actions: []string{"product.update"}, PayloadSchema: "partner.product"},
+	}
+	registry := schemas.NewSchemaRegistry()
+	assert.Nil(t, ValidateConfigurations(configs, ConfigurationRules{}))
+	assert.EqualError(t, ValidateConfigurations(configs, ConfigurationRules{Schemas: registry}), "hook \"partner_sync\": payload_schema: unknown schema \"partner.product\"")
+
+	registry.Register("partner.product", `{"type": "object"}`)
+	assert.Nil(t, ValidateConfigurations(configs, ConfigurationRules{Schemas: registry}))
+}
+
+func TestValidateConfigurations_Fields(t *testing.T) {
+	cases := []struct {
+		config   Configuration
+		expected string
+	}{
+		{Configuration{Name: "hook"}, "hook \"hook\": actions: is required"},
+		{Configuration{Name: "hook", Actions: []string{"a"}, Debounce: "1d"}, "hook \"hook\": debounce: invalid time value \"1d\", expected number with unit ms, s, m or h"},
+		{Configuration{Name: "hook", Actions: []string{"a"}, MaxWait: "s"}, "hook \"hook\": max_wait: invalid time value \"s\", expected number with unit ms, s, m or h"},
+		{Configuration{Name: "hook", Actions: []string{"a"}, RetryBackoff: "-1"}, "hook \"hook\": retry_backoff: invalid seconds \"-1\""},
+		{Configuration{Name: "hook", Actions: []string{"a"}, ThrottleKey: "shop"}, "hook \"hook\": throttle_key: invalid throttle_key field \"shop\""},
+		{Configuration{Name: "hook", Actions: []string{"a"}, ThrottledPayloadsMerge: "all"}, "hook \"hook\": throttled_payloads_merge: unknown strategy \"all\""},
+		{Configuration{Name: "hook", Actions: []string{"a"}, OnDocumentNotFound: "retry"}, "hook \"hook\": on_document_not_found: unknown policy \"retry\""},
+		{Configuration{Name: "hook", Actions: []string{"a"}, CallbackURL: "http://%zz"}, "hook \"hook\": callback_url: invalid url"},
+		{Configuration{Name: "hook", Actions: []string{"a"}, IncludePayloadAttrs: []string{"price as "}}, "hook \"hook\": include_payload_attrs: invalid field \"price as \": missing alias"},
+		{Configuration{Name: "hook", Actions: []string{"a"}, ExcludePayloadAttrs: []string{"email:encrypt"}}, "hook \"hook\": exclude_payload_attrs: invalid field \"email:encrypt\": unknown transformation \"encrypt\""},
+		{Configuration{Name: "hook", Actions: []string{"a"}, IncludeDocumentAttrs: []string{"email:encrypt"}}, "hook \"hook\": include_document_attrs: invalid field \"email:encrypt\": unknown transformation \"encrypt\""},
+		{Configuration{Name: "hook", Actions: []string{"a"}, ExcludeDocumentAttrs: []string{"email:encrypt"}}, "hook \"hook\": exclude_document_attrs: invalid field \"email:encrypt\": unknown transformation \"encrypt\""},
+	}
+	for _, c := range cases {
+		err := ValidateConfigurations([]interfaces.ConfigurationInterface{c.config}, ConfigurationRules{})
+		assert.EqualError(t, err, c.expected)
+	}
+}
@@ -0,0 +1,195 @@
+package models_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	interfaces "github.com/shoplineapp/captin/interfaces"
+	. "github.com/shoplineapp/captin/models"
+)
+
+const reloadableConfig = `
+hooks:
+  - name: product_sync
+    actions: [product.update]
+  - name: order_sync
+    actions: [order.create]
+`
+
+const reloadedConfig = `
+hooks:
+  - name: product_sync
+    actions: [product.update]
+    throttle: 1s
+  - name: search_index
+    actions: [product.update]
+`
+
+func setupReloadable(t *testing.T) (string, *ReloadableConfigurationMapper) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	ioutil.WriteFile(path, []byte(reloadableConfig), 0644)
+	subject, err := NewReloadableConfigurationMapper(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, subject
+}
+
+func TestReloadableConfigurationMapper_Reload(t *testing.T) {
+	path, subject := setupReloadable(t)
+	defer subject.Close()
+
+	inflight := subject.ConfigsForKey("product.update")
+	assert.Equal(t, []string{"product_sync"}, getNames(inflight))
+	assert.Equal(t, 1, len(subject.ConfigsForKey("order.create")))
+
+	ioutil.WriteFile(path, []byte(reloadedConfig), 0644)
+	assert.Nil(t, subject.Reload())
+
+	assert.Equal(t, []string{"product_sync", "search_index"}, getNames(subject.ConfigsForKey("product.update")))
+	assert.Equal(t, 0, len(subject.ConfigsForKey("order.create")))
+	// configurations taken before reload are kept
+	assert.Equal(t, []string{"product_sync"}, getNames(inflight))
+	assert.Equal(t, "", inflight[0].GetThrottle())
+}
+
+func TestReloadableConfigurationMapper_Reload_Invalid(t *testing.T) {
+	path, subject := setupReloadable(t)
+	defer subject.Close()
+
+	ioutil.WriteFile(path, []byte("hooks:\n  - name: [product_sync\n"), 0644)
+	assert.Error(t, subject.Reload())
+	assert.Equal(t, []string{"product_sync"}, getNames(subject.ConfigsForKey("product.update")))
+
+	// rejected by validator
+	ioutil.WriteFile(path, []byte(reloadedConfig), 0644)
+	subject.SetValidator(func(configs []interfaces.ConfigurationInterface) error {
+		return errors.New("invalid")
+	})
+	assert.EqualError(t, subject.Reload(), "invalid")
+	assert.Equal(t, []string{"product_sync"}, getNames(subject.ConfigsForKey("product.update")))
+
+	subject.SetValidator(func(configs []interfaces.ConfigurationInterface) error {
+		return nil
+	})
+	assert.Nil(t, subject.Reload())
+	assert.Equal(t, 2, len(subject.ConfigsForKey("product.update")))
+}
+
+func TestReloadableConfigurationMapper_Watch(t *testing.T) {
+	path, subject := setupReloadable(t)
+	defer subject.Close()
+	assert.Nil(t, subject.Watch())
+
+	// replace file as editors do
+	tmp := filepath.Join(filepath.Dir(path), "config.yaml.tmp")
+	ioutil.WriteFile(tmp, []byte(reloadedConfig), 0644)
+	assert.Nil(t, os.Rename(tmp, path))
+
+	assert.Eventually(t, func() bool {
+		return len(subject.ConfigsForKey("product.update")) == 2
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestReloadableConfigurationMapper_Reload_Schemas(t *testing.T) {
+	path, subject := setupReloadable(t)
+	defer subject.Close()
+	assert.Nil(t, subject.SchemaRegistry())
+
+	schemaDir := filepath.Join(filepath.Dir(path), "schemas")
+	os.Mkdir(schemaDir, 0755)
+	ioutil.WriteFile(filepath.Join(schemaDir, "product.update.json"), []byte(`{"type": "object"}`), 0644)
+	ioutil.WriteFile(path, []byte(reloadedConfig), 0644)
+	assert.Nil(t, subject.Reload())
+	assert.True(t, subject.SchemaRegistry().Has("product.update"))
+	assert.Equal(t, 2, len(subject.ConfigsForKey("product.update")))
+
+	// payload_schema is checked against schemas reloaded with hooks
+	ioutil.WriteFile(path, []byte("- name: partner_sync\n  actions: [product.update]\n  payload_schema: partner.product\n"), 0644)
+	assert.EqualError(t, subject.Reload(), "hook \"partner_sync\": payload_schema: unknown schema \"partner.product\"")
+	ioutil.WriteFile(filepath.Join(schemaDir, "partner.product.json"), []byte(`{"type": "object"}`), 0644)
+	assert.Nil(t, subject.Reload())
+	assert.Equal(t, []string{"partner_sync"}, getNames(subject.ConfigsForKey("product.update")))
+
+	// hooks are kept with their schemas when schemas are invalid
+	ioutil.WriteFile(filepath.Join(schemaDir, "order.create.json"), []byte(`{"type": 1`), 0644)
+	ioutil.WriteFile(path, []byte(reloadableConfig), 0644)
+	assert.Error(t, subject.Reload())
+	assert.False(t, subject.SchemaRegistry().Has("order.create"))
+	assert.Equal(t, []string{"partner_sync"}, getNames(subject.ConfigsForKey("product.update")))
+}
+
+func TestReloadableConfigurationMapper_Watch_Schemas(t *testing.T) {
+	path, subject := setupReloadable(t)
+	defer subject.Close()
+	schemaDir := filepath.Join(filepath.Dir(path), "schemas")
+	os.Mkdir(schemaDir, 0755)
+	assert.Nil(t, subject.Reload())
+	assert.Nil(t, subject.Watch())
+
+	ioutil.WriteFile(filepath.Join(schemaDir, "product.update.json"), []byte(`{"type": "object"}`), 0644)
+	assert.Eventually(t, func() bool {
+		registry := subject.SchemaRegistry()
+		return registry != nil && registry.Has("product.update")
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestReloadableConfigurationMapper_Watch_Includes(t *testing.T) {
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "hooks"), 0755)
+	os.Mkdir(filepath.Join(root, "shared"), 0755)
+	included := filepath.Join(root, "shared", "search.yaml")
+	ioutil.WriteFile(included, []byte(reloadableConfig), 0644)
+	ioutil.WriteFile(filepath.Join(root, "hooks", "config.yaml"), []byte("include: ../shared/search.yaml\n"), 0644)
+
+	subject, err := NewReloadableConfigurationMapper(filepath.Join(root, "hooks"))
+	assert.Nil(t, err)
+	defer subject.Close()
+	assert.Nil(t, subject.Watch())
+
+	// included file outside of the watched directory
+	ioutil.WriteFile(included, []byte(reloadedConfig), 0644)
+	assert.Eventually(t, func() bool {
+		return len(subject.ConfigsForKey("product.update")) == 2
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestNewReloadableConfigurationMapper_Error(t *testing.T) {
+	_, err := NewReloadableConfigurationMapper("fixtures/not_found.yaml")
+	assert.Error(t, err)
+}
+
+func TestDiffConfigurations(t *testing.T) {
+	before := []interfaces.ConfigurationInterface{
+		Configuration{Name: "product_sync", Throttle: "1s"},
+		Configuration{Name: "order_sync"},
+		Configuration{ConfigID: "1"},
+	}
+	after := []interfaces.ConfigurationInterface{
+		Configuration{Name: "product_sync", Throttle: "2s"},
+		Configuration{Name: "search_index"},
+		Configuration{ConfigID: "1"},
+	}
+	diff := DiffConfigurations(before, after)
+	assert.Equal(t, []string{"search_index"}, diff.Added)
+	assert.Equal(t, []string{"order_sync"}, diff.Removed)
+	assert.Equal(t, []string{"product_sync"}, diff.Changed)
+	assert.False(t, diff.IsEmpty())
+	assert.True(t, DiffConfigurations(after, after).IsEmpty())
+
+	// hooks of tenants are compared within tenant
+	tenant := append(after, Configuration{Name: "product_sync", Tenant: "merchant_a"})
+	assert.Equal(t, []string{"merchant_a:product_sync"}, DiffConfigurations(after, tenant).Added)
+
+	// fields kept for logging, e.g. overrides, are not compared
+	setEnv(t, map[string]string{"HOOK_PRODUCT_SYNC_THROTTLE": "2s"})
+	overridden, err := ApplyEnvOverrides([]Configuration{{Name: "product_sync", Throttle: "1s"}})
+	assert.Nil(t, err)
+	assert.True(t, DiffConfigurations(after[:1], []interfaces.ConfigurationInterface{overridden[0]}).IsEmpty())
+}
@@ -0,0 +1,180 @@
+package models_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	stores "github.com/shoplineapp/captin/internal/stores"
+	. "github.com/shoplineapp/captin/models"
+)
+
+// configServer - Serve configuration document with ETag, counting requests answered with 304
+type configServer struct {
+	lock        sync.Mutex
+	document    string
+	etag        string
+	secret      string
+	status      int
+	notModified int
+}
+
+func (s *configServer) set(document string, etag string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.document, s.etag = document, etag
+}
+
+func (s *configServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+		return
+	}
+	if s.etag != "" && r.Header.Get("If-None-Match") == s.etag {
+		s.notModified++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if s.secret != "" {
+		mac := hmac.New(sha256.New, []byte(s.secret))
+		mac.Write([]byte(s.document))
+		w.Header().Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	w.Header().Set("ETag", s.etag)
+	w.Write([]byte(s.document))
+}
+
+func TestRemoteConfigurationMapper_HTTP(t *testing.T) {
+	server := &configServer{document: reloadableConfig, etag: "v1"}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	source := NewHTTPConfigSource(ts.URL + "/hooks.yaml")
+	assert.Equal(t, "hooks.yaml", source.Name())
+	subject, err := NewRemoteConfigurationMapper(source, "")
+	assert.Nil(t, err)
+	defer subject.Close()
+	assert.Equal(t, []string{"product_sync"}, getNames(subject.ConfigsForKey("product.update")))
+
+	// unchanged document is not reloaded
+	assert.Nil(t, subject.Reload())
+	assert.Equal(t, 1, server.notModified)
+
+	server.set(reloadedConfig, "v2")
+	subject.Poll(10 * time.Millisecond)
+	assert.Eventually(t, func() bool {
+		return len(subject.ConfigsForKey("product.update")) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	// remote configurations are not watched
+	assert.Error(t, subject.Watch())
+}
+
+func TestRemoteConfigurationMapper_HTTP_Signature(t *testing.T) {
+	server := &configServer{document: reloadableConfig, secret: "secret"}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	source := NewHTTPConfigSource(ts.URL + "/hooks")
+	source.SetName("hooks.yaml")
+	source.SetSecret("secret")
+	subject, err := NewRemoteConfigurationMapper(source, "")
+	assert.Nil(t, err)
+	defer subject.Close()
+
+	source = NewHTTPConfigSource(ts.URL + "/hooks")
+	source.SetName("hooks.yaml")
+	source.SetSecret("another")
+	_, err = NewRemoteConfigurationMapper(source, "")
+	assert.EqualError(t, err, "invalid signature of configurations from "+ts.URL+"/hooks")
+}
+
+func TestRemoteConfigurationMapper_HTTP_Rejected(t *testing.T) {
+	server := &configServer{document: reloadableConfig, etag: "v1"}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	subject, err := NewRemoteConfigurationMapper(NewHTTPConfigSource(ts.URL+"/hooks.yaml"), "")
+	assert.Nil(t, err)
+	defer subject.Close()
+
+	// rejected document is fetched and validated again instead of being not modified
+	server.set("hooks: [{name: broken}]", "v2")
+	assert.Error(t, subject.Reload())
+	assert.Error(t, subject.Reload())
+	assert.Equal(t, 0, server.notModified)
+
+	server.set(reloadedConfig, "v3")
+	assert.Nil(t, subject.Reload())
+	assert.Nil(t, subject.Reload())
+	assert.Equal(t, 1, server.notModified)
+	assert.Equal(t, []string{"product_sync", "search_index"}, getNames(subject.ConfigsForKey("product.update")))
+}
+
+func TestRemoteConfigurationMapper_LastKnownGood(t *testing.T) {
+	cachePath := filepath.Join(t.TempDir(), "hooks.yaml")
+	server := &configServer{document: reloadableConfig}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	subject, err := NewRemoteConfigurationMapper(NewHTTPConfigSource(ts.URL+"/hooks.yaml"), cachePath)
+	assert.Nil(t, err)
+	cached, _ := ioutil.ReadFile(cachePath)
+	assert.Equal(t, reloadableConfig, string(cached))
+
+	// invalid configurations are not kept
+	server.set("hooks: [{name: broken}]", "")
+	assert.Error(t, subject.Reload())
+	cached, _ = ioutil.ReadFile(cachePath)
+	assert.Equal(t, reloadableConfig, string(cached))
+
+	server.set(reloadedConfig, "")
+	assert.Nil(t, subject.Reload())
+	cached, _ = ioutil.ReadFile(cachePath)
+	assert.Equal(t, reloadedConfig, string(cached))
+	subject.Close()
+
+	// last known good configurations are used when source is unavailable on start
+	server.status = http.StatusServiceUnavailable
+	subject, err = NewRemoteConfigurationMapper(NewHTTPConfigSource(ts.URL+"/hooks.yaml"), cachePath)
+	assert.Nil(t, err)
+	defer subject.Close()
+	assert.Equal(t, []string{"product_sync", "search_index"}, getNames(subject.ConfigsForKey("product.update")))
+
+	_, err = NewRemoteConfigurationMapper(NewHTTPConfigSource(ts.URL+"/hooks.yaml"), "")
+	assert.EqualError(t, err, "failed to fetch configurations from "+ts.URL+"/hooks.yaml, status 503")
+}
+
+func TestRemoteConfigurationMapper_Store(t *testing.T) {
+	store := stores.NewMemoryStore()
+	defer store.Close()
+
+	_, err := NewRemoteConfigurationMapper(NewStoreConfigSource(store, "captin:hooks.yaml"), "")
+	assert.EqualError(t, err, "configuration key captin:hooks.yaml does not exist")
+
+	store.Set("captin:hooks.yaml", reloadableConfig, 0)
+	subject, err := NewRemoteConfigurationMapper(NewStoreConfigSource(store, "captin:hooks.yaml"), "")
+	assert.Nil(t, err)
+	defer subject.Close()
+	assert.Equal(t, 1, len(subject.ConfigsForKey("order.create")))
+
+	store.Set("captin:hooks.yaml", "hooks: []\ninclude: other.yaml", 0)
+	assert.EqualError(t, subject.Reload(), "captin:hooks.yaml:2: include is only supported in files")
+	// rejected value is validated again instead of being not modified
+	assert.EqualError(t, subject.Reload(), "captin:hooks.yaml:2: include is only supported in files")
+
+	store.Set("captin:hooks.yaml", reloadedConfig, 0)
+	assert.Nil(t, subject.Reload())
+	assert.Equal(t, 0, len(subject.ConfigsForKey("order.create")))
+}
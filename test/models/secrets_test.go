@@ -0,0 +1,153 @@
+package models_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	interfaces "github.com/shoplineapp/captin/interfaces"
+	. "github.com/shoplineapp/captin/models"
+)
+
+// vaultMock - Secret provider with fixed secrets
+type vaultMock map[string]string
+
+func (v vaultMock) Resolve(name string) (string, error) {
+	if secret, exists := v[name]; exists {
+		return secret, nil
+	}
+	return "", errors.New("secret " + name + " not found")
+}
+
+func TestSecretResolver_Resolve(t *testing.T) {
+	os.Setenv("CAPTIN_TEST_SECRET", "from-env")
+	defer os.Unsetenv("CAPTIN_TEST_SECRET")
+	file := filepath.Join(t.TempDir(), "secret")
+	ioutil.WriteFile(file, []byte("from-file\n"), 0600)
+
+	subject := NewSecretResolver()
+	subject.SetProvider("vault", vaultMock{"sqs/key": "from-vault"})
+
+	cases := []struct {
+		value       string
+		expected    string
+		isReference bool
+	}{
+		{"env:CAPTIN_TEST_SECRET", "from-env", true},
+		{"file:" + file, "from-file", true},
+		{"vault:sqs/key", "from-vault", true},
+		{"http://localhost/sync", "http://localhost/sync", false},
+		{"plain", "plain", false},
+	}
+	for _, c := range cases {
+		secret, isReference, err := subject.Resolve(c.value)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, secret)
+		assert.Equal(t, c.isReference, isReference)
+	}
+
+	_, _, err := subject.Resolve("env:CAPTIN_TEST_MISSING")
+	assert.EqualError(t, err, "environment variable CAPTIN_TEST_MISSING is not set")
+}
+
+func TestSecretResolver_ResolveConfigurations(t *testing.T) {
+	subject := NewSecretResolver()
+	subject.SetProvider("vault", vaultMock{"token": "s3cr3t", "key": "AKIA"})
+
+	configs, err := subject.ResolveConfigurations([]Configuration{{
+		Name:        "sqs_sync",
+		CallbackURL: "vault:token",
+		Extras:      map[string]string{"sqs_sender_aws_access_key_id": "vault:key", "team": "core"},
+	}})
+	assert.Nil(t, err)
+	assert.Equal(t, "s3cr3t", configs[0].CallbackURL)
+	assert.Equal(t, map[string]string{"sqs_sender_aws_access_key_id": "AKIA", "team": "core"}, configs[0].Extras)
+	assert.Equal(t, "AKIA", Destination{Config: configs[0]}.GetSqsSenderConfig("AWS_ACCESS_KEY_ID"))
+
+	_, err = subject.ResolveConfigurations([]Configuration{{
+		Name:     "broken",
+		Validate: "vault:missing",
+		Extras:   map[string]string{"key": "vault:unknown"},
+	}})
+	assert.EqualError(t, err, "hook \"broken\": validate: secret missing not found; hook \"broken\": extras.key: secret unknown not found")
+}
+
+func TestSecretResolver_Redacted(t *testing.T) {
+	subject := NewSecretResolver()
+	subject.SetProvider("vault", vaultMock{"token": "s3cr3t"})
+	configs, _ := subject.ResolveConfigurations([]Configuration{{
+		Name:        "sync",
+		CallbackURL: "http://localhost/sync",
+		Extras:      map[string]string{"token": "vault:token"},
+	}})
+
+	config := configs[0]
+	config.CallbackURL = "http://localhost/sync?token=s3cr3t"
+	destination := Destination{Config: config}
+
+	assert.Equal(t, "http://localhost/sync?token=[REDACTED]", config.Redacted().CallbackURL)
+	assert.Equal(t, map[string]string{"token": "[REDACTED]"}, config.Redacted().Extras)
+	// original configuration is kept
+	assert.Equal(t, "s3cr3t", config.Extras["token"])
+
+	for _, formatted := range []string{fmt.Sprint(config), fmt.Sprint(destination), fmt.Sprintf("%v", []interfaces.DestinationInterface{destination})} {
+		assert.NotContains(t, formatted, "s3cr3t")
+		assert.Contains(t, formatted, "[REDACTED]")
+	}
+
+	data, err := json.Marshal(destination)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), "s3cr3t")
+	assert.Contains(t, string(data), "\"callback_url\":\"http://localhost/sync?token=[REDACTED]\"")
+
+	// configurations logged by JSON formatters
+	data, err = json.Marshal(map[string]interface{}{"config": config})
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), "s3cr3t")
+	assert.Contains(t, string(data), "\"callback_url\":\"http://localhost/sync?token=[REDACTED]\"")
+	assert.Contains(t, string(data), "\"extras\":{\"token\":\"[REDACTED]\"}")
+
+	assert.Equal(t, "Post http://localhost/sync?token=[REDACTED]: refused", destination.Redact("Post http://localhost/sync?token=s3cr3t: refused"))
+}
+
+func TestLoadConfigurationMapper_Secrets(t *testing.T) {
+	os.Setenv("CAPTIN_TEST_CALLBACK_URL", "https://localhost/sync")
+	defer os.Unsetenv("CAPTIN_TEST_CALLBACK_URL")
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	ioutil.WriteFile(path, []byte("- name: sync\n  actions: [product.update]\n  callback_url: env:CAPTIN_TEST_CALLBACK_URL\n"), 0644)
+	subject, err := LoadConfigurationMapper(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://localhost/sync", subject.ConfigsForKey("product.update")[0].GetCallbackURL())
+
+	// secrets are resolved again on reload
+	reloadable, err := NewReloadableConfigurationMapper(path)
+	assert.Nil(t, err)
+	defer reloadable.Close()
+	os.Setenv("CAPTIN_TEST_CALLBACK_URL", "https://localhost/rotated")
+	assert.Nil(t, reloadable.Reload())
+	assert.Equal(t, "https://localhost/rotated", reloadable.ConfigsForKey("product.update")[0].GetCallbackURL())
+
+	os.Unsetenv("CAPTIN_TEST_CALLBACK_URL")
+	assert.EqualError(t, reloadable.Reload(), "hook \"sync\": callback_url: environment variable CAPTIN_TEST_CALLBACK_URL is not set")
+}
+
+func TestLoadConfigurationMapper_SecretsNotInErrors(t *testing.T) {
+	setEnv(t, map[string]string{"CAPTIN_TEST_CALLBACK_URL": "localhost/sync?token=s3cr3t", "CAPTIN_TEST_BROKEN_URL": "http://[::1/sync?token=s3cr3t"})
+
+	for _, env := range []string{"CAPTIN_TEST_CALLBACK_URL", "CAPTIN_TEST_BROKEN_URL"} {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		ioutil.WriteFile(path, []byte("- name: sync\n  actions: [product.update]\n  callback_url: env:"+env+"\n"), 0644)
+		_, err := LoadConfigurationMapper(path)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "hook \"sync\": callback_url: invalid url")
+			assert.NotContains(t, err.Error(), "s3cr3t")
+		}
+	}
+}
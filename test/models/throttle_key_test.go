@@ -0,0 +1,116 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	interfaces "github.com/shoplineapp/captin/interfaces"
+	. "github.com/shoplineapp/captin/models"
+)
+
+func throttleKeyEvent() IncomingEvent {
+	return IncomingEvent{
+		Key:        "product.update",
+		Source:     "core",
+		TargetType: "Product",
+		TargetId:   "product_id",
+		Payload:    map[string]interface{}{"shop": map[string]interface{}{"id": "shop_id"}, "count": 2},
+		Control:    map[string]interface{}{"region": "eu"},
+	}
+}
+
+func TestThrottleKey_Default(t *testing.T) {
+	config := Configuration{Name: "service_one"}
+	assert.Equal(t, `product\.update.service_one.product_id`, ThrottleKey(throttleKeyEvent(), config))
+}
+
+func TestThrottleKey_Fields(t *testing.T) {
+	config := Configuration{Name: "service_one", ThrottleKey: "target_type, payload.shop.id,control.region,payload.count"}
+	assert.Equal(t, "service_one.Product.shop_id.eu.2", ThrottleKey(throttleKeyEvent(), config))
+
+	// ignoring event key throttles all actions of the hook together
+	config.ThrottleKey = "target_type,target_id"
+	create := throttleKeyEvent()
+	create.Key = "product.create"
+	assert.Equal(t, ThrottleKey(throttleKeyEvent(), config), ThrottleKey(create, config))
+	assert.Equal(t, "service_one.Product.product_id", ThrottleKey(create, config))
+}
+
+func TestThrottleKey_MissingField(t *testing.T) {
+	config := Configuration{Name: "service_one", ThrottleKey: "event_key,payload.shop.name,control.missing.path"}
+	assert.Equal(t, `service_one.product\.update..`, ThrottleKey(throttleKeyEvent(), config))
+}
+
+func TestThrottleKey_Escaped(t *testing.T) {
+	// dots in values do not collide with separators of fields
+	config := Configuration{Name: "service_one", ThrottleKey: "payload.a,payload.b"}
+	dotted := IncomingEvent{Payload: map[string]interface{}{"a": "x.y", "b": "z"}}
+	split := IncomingEvent{Payload: map[string]interface{}{"a": "x", "b": "y.z"}}
+	assert.Equal(t, `service_one.x\.y.z`, ThrottleKey(dotted, config))
+	assert.Equal(t, `service_one.x.y\.z`, ThrottleKey(split, config))
+
+	dotted.Tenant = "a:b"
+	assert.Equal(t, `a\:b:service_one.x\.y.z`, ThrottleKey(dotted, config))
+
+	// parts of default key are escaped alike
+	config = Configuration{Name: "service.one"}
+	assert.Equal(t, `a\.b.service\.one.c`, ThrottleKey(IncomingEvent{Key: "a.b", TargetId: "c"}, config))
+	assert.Equal(t, `a.service\.one.b\.c`, ThrottleKey(IncomingEvent{Key: "a", TargetId: "b.c"}, config))
+}
+
+// traceEvent - Event implementation other than IncomingEvent
+type traceEvent struct {
+	interfaces.IncomingEventInterface
+}
+
+func (e traceEvent) GetTraceInfo() map[string]interface{} {
+	return map[string]interface{}{"key": "product.update", "id": "product_id"}
+}
+
+func (e traceEvent) GetControl() map[string]interface{} {
+	return nil
+}
+
+func TestDataKey(t *testing.T) {
+	d := Destination{Config: Configuration{Name: "service_one"}}
+	assert.Equal(t, `product\.update.service_one.product_id-data`, DataKey(throttleKeyEvent(), d, "", "-data"))
+	e := throttleKeyEvent()
+	assert.Equal(t, `prefix-product\.update.service_one.product_id`, DataKey(&e, d, "prefix-", ""))
+
+	// other implementations are keyed by trace info
+	assert.Equal(t, `product\.update.service_one.product_id`, DataKey(traceEvent{}, d, "", ""))
+}
+
+func TestThrottleKey_Tenant(t *testing.T) {
+	e := throttleKeyEvent()
+	e.Tenant = "merchant_a"
+	config := Configuration{Name: "service_one"}
+	assert.Equal(t, `merchant_a:product\.update.service_one.product_id`, ThrottleKey(e, config))
+
+	config.ThrottleKey = "payload.shop.id"
+	assert.Equal(t, "merchant_a:service_one.shop_id", ThrottleKey(e, config))
+
+	// hooks of tenant never share keys with global hooks of the same name
+	config.Tenant = "merchant_a"
+	assert.Equal(t, "merchant_a::service_one.shop_id", ThrottleKey(e, config))
+	e.Tenant = ""
+	assert.Equal(t, "merchant_a::service_one.shop_id", ThrottleKey(e, config))
+}
+
+func TestThrottleKey_Invalid(t *testing.T) {
+	config := Configuration{Name: "service_one", ThrottleKey: "target_id,unknown"}
+	assert.Equal(t, `product\.update.service_one.product_id`, ThrottleKey(throttleKeyEvent(), config))
+}
+
+func TestParseThrottleKey(t *testing.T) {
+	fields, err := ParseThrottleKey("event_key, source,payload.id")
+	assert.Equal(t, []string{"event_key", "source", "payload.id"}, fields)
+	assert.Nil(t, err)
+
+	_, err = ParseThrottleKey("payload.")
+	assert.EqualError(t, err, "invalid throttle_key field \"payload.\"")
+
+	_, err = ParseThrottleKey("target_id,")
+	assert.EqualError(t, err, "invalid throttle_key field \"\"")
+}
@@ -0,0 +1,104 @@
+package schemas_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	schemas "github.com/shoplineapp/captin/schemas"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSchemaRegistryFromPath(t *testing.T) {
+	subject, err := schemas.NewSchemaRegistryFromPath("fixtures")
+	assert.Nil(t, err)
+	assert.True(t, subject.Has("product.update"))
+	assert.True(t, subject.Has("definitions"))
+	assert.False(t, subject.Has("ignored"))
+}
+
+func TestNewSchemaRegistryFromPath_NotExist(t *testing.T) {
+	subject, err := schemas.NewSchemaRegistryFromPath("not_exist")
+	assert.Nil(t, subject)
+	assert.NotNil(t, err)
+}
+
+func TestNewSchemaRegistryFromPath_InvalidSchema(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "schemas")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "broken.json"), []byte(`{"type": 1`), 0644)
+
+	_, err := schemas.NewSchemaRegistryFromPath(dir)
+	assert.Contains(t, err.Error(), "broken.json")
+}
+
+func TestNewSchemaRegistryForConfig(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "config")
+	defer os.RemoveAll(dir)
+	configPath := filepath.Join(dir, "config.json")
+
+	// No schema directory alongside the config
+	subject, err := schemas.NewSchemaRegistryForConfig(configPath)
+	assert.Nil(t, err)
+	assert.Nil(t, subject)
+
+	os.Mkdir(schemas.DirForConfig(configPath), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "schemas", "product.create.json"), []byte(`{"type": "object"}`), 0644)
+	subject, err = schemas.NewSchemaRegistryForConfig(configPath)
+	assert.Nil(t, err)
+	assert.True(t, subject.Has("product.create"))
+}
+
+func TestNewSchemaRegistryForConfig_Directory(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "config")
+	defer os.RemoveAll(dir)
+
+	// schema directory inside the config directory
+	assert.Equal(t, filepath.Join(dir, "schemas"), schemas.DirForConfig(dir))
+	os.Mkdir(filepath.Join(dir, "schemas"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "schemas", "product.create.json"), []byte(`{"type": "object"}`), 0644)
+	subject, err := schemas.NewSchemaRegistryForConfig(dir)
+	assert.Nil(t, err)
+	assert.True(t, subject.Has("product.create"))
+}
+
+func TestNewSchemaRegistryForConfig_DirectoryFixture(t *testing.T) {
+	// schemas of the configuration directory, not of its parent
+	dir := filepath.Join("..", "models", "fixtures", "config_dir")
+	assert.Equal(t, filepath.Join(dir, "schemas"), schemas.DirForConfig(dir))
+	subject, err := schemas.NewSchemaRegistryForConfig(dir)
+	assert.Nil(t, err)
+	assert.True(t, subject.Has("product"))
+}
+
+func TestValidate(t *testing.T) {
+	subject, _ := schemas.NewSchemaRegistryFromPath("fixtures")
+
+	violations, err := subject.Validate("product.update", map[string]interface{}{"_id": "1", "price": 10})
+	assert.Nil(t, err)
+	assert.Empty(t, violations)
+
+	violations, err = subject.Validate("product.update", map[string]interface{}{"price": -1})
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{
+		"(root): _id is required",
+		"price: Must be greater than or equal to 0",
+	}, violations)
+}
+
+func TestValidate_NilDocument(t *testing.T) {
+	subject := schemas.NewSchemaRegistry()
+	assert.Nil(t, subject.Register("any", `{"type": "object"}`))
+
+	var payload map[string]interface{}
+	violations, err := subject.Validate("any", payload)
+	assert.Nil(t, err)
+	assert.Empty(t, violations)
+}
+
+func TestValidate_NotRegistered(t *testing.T) {
+	subject := schemas.NewSchemaRegistry()
+	_, err := subject.Validate("product.update", map[string]interface{}{})
+	assert.EqualError(t, err, "schema product.update is not registered")
+}
@@ -0,0 +1,206 @@
+package stores_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	models "github.com/shoplineapp/captin/models"
+	stores "github.com/shoplineapp/captin/stores"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupBolt(t *testing.T, sweepInterval time.Duration) (string, *stores.BoltStore) {
+	path := filepath.Join(t.TempDir(), "captin.db")
+	store, err := stores.NewBoltStore(path, sweepInterval)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, store
+}
+
+func TestBoltStore_GetSet(t *testing.T) {
+	_, store := setupBolt(t, time.Minute)
+	defer store.Close()
+
+	_, exists, _, err := store.Get("key")
+	assert.False(t, exists)
+	assert.Nil(t, err)
+
+	result, err := store.Set("key", "value", time.Minute)
+	assert.True(t, result)
+	assert.Nil(t, err)
+
+	value, exists, ttl, err := store.Get("key")
+	assert.Equal(t, "value", value)
+	assert.True(t, exists)
+	assert.InDelta(t, float64(time.Minute), float64(ttl), float64(time.Second))
+	assert.Nil(t, err)
+
+	result, _ = store.Update("key", "updated")
+	assert.True(t, result)
+	value, _, _, _ = store.Get("key")
+	assert.Equal(t, "updated", value)
+
+	result, _ = store.Update("missing", "value")
+	assert.False(t, result)
+
+	store.Remove("key")
+	_, exists, _, _ = store.Get("key")
+	assert.False(t, exists)
+}
+
+func TestBoltStore_Expiry(t *testing.T) {
+	_, store := setupBolt(t, 20*time.Millisecond)
+	defer store.Close()
+
+	store.Set("short", "value", 30*time.Millisecond)
+	store.Set("long", "value", time.Minute)
+	store.Set("forever", "value", 0)
+	store.Enqueue("queue", "1", 30*time.Millisecond)
+	assert.Equal(t, 4, store.Len())
+
+	time.Sleep(100 * time.Millisecond)
+
+	_, exists, _, _ := store.Get("short")
+	assert.False(t, exists)
+	_, exists, _, _ = store.GetQueue("queue")
+	assert.False(t, exists)
+	_, exists, ttl, _ := store.Get("forever")
+	assert.True(t, exists)
+	assert.Equal(t, time.Duration(0), ttl)
+	// expired keys are swept
+	assert.Equal(t, 2, store.Len())
+}
+
+func TestBoltStore_Queue(t *testing.T) {
+	_, store := setupBolt(t, time.Minute)
+	defer store.Close()
+
+	values, exists, _, _ := store.GetQueue("queue")
+	assert.Equal(t, []string{}, values)
+	assert.False(t, exists)
+
+	store.Enqueue("queue", "1", time.Minute)
+	store.Enqueue("queue", "2", time.Hour)
+
+	values, exists, ttl, err := store.GetQueue("queue")
+	assert.Equal(t, []string{"1", "2"}, values)
+	assert.True(t, exists)
+	// ttl is set on first element
+	assert.True(t, ttl <= time.Minute)
+	assert.Nil(t, err)
+}
+
+func TestBoltStore_EnqueueWithLimit(t *testing.T) {
+	_, store := setupBolt(t, time.Minute)
+	defer store.Close()
+
+	for _, value := range []string{"1", "2", "3"} {
+		result, err := store.EnqueueWithLimit("queue", value, time.Minute, 2)
+		assert.True(t, result)
+		assert.Nil(t, err)
+	}
+	values, _, _, _ := store.GetQueue("queue")
+	assert.Equal(t, []string{"2", "3"}, values)
+}
+
+func TestBoltStore_Atomic(t *testing.T) {
+	_, store := setupBolt(t, time.Minute)
+	defer store.Close()
+
+	result, _ := store.SetNX("key", "first", time.Minute)
+	assert.True(t, result)
+	result, _ = store.SetNX("key", "second", time.Minute)
+	assert.False(t, result)
+
+	result, _ = store.CompareAndSwap("key", "second", "third", time.Minute)
+	assert.False(t, result)
+	result, _ = store.CompareAndSwap("key", "first", "third", time.Minute)
+	assert.True(t, result)
+
+	_, err := store.Incr("key", 1, time.Minute)
+	assert.EqualError(t, err, "BoltStore: value of key is not an integer")
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store.Incr("counter", 1, time.Minute)
+		}()
+	}
+	wg.Wait()
+	value, _, _, _ := store.Get("counter")
+	assert.Equal(t, "20", value)
+}
+
+func TestBoltStore_DataKey(t *testing.T) {
+	_, store := setupBolt(t, time.Minute)
+	defer store.Close()
+
+	e := models.IncomingEvent{Key: "product.update", TargetId: "product_id"}
+	d := models.Destination{Config: models.Configuration{Name: "service_one"}}
+	assert.Equal(t, `product\.update.service_one.product_id-data`, store.DataKey(e, d, "", "-data"))
+}
+
+func TestBoltStore_Reopen(t *testing.T) {
+	path, store := setupBolt(t, time.Minute)
+	store.Set("key", "value", time.Minute)
+	store.Set("expiring", "value", 20*time.Millisecond)
+	store.Enqueue("queue", "1", time.Minute)
+	assert.Nil(t, store.Close())
+	assert.Nil(t, store.Close())
+
+	time.Sleep(30 * time.Millisecond)
+
+	reopened, err := stores.NewBoltStore(path, time.Minute)
+	assert.Nil(t, err)
+	defer reopened.Close()
+
+	value, exists, ttl, _ := reopened.Get("key")
+	assert.Equal(t, "value", value)
+	assert.True(t, exists)
+	assert.True(t, ttl > 0 && ttl < time.Minute)
+	_, exists, _, _ = reopened.Get("expiring")
+	assert.False(t, exists)
+	values, _, _, _ := reopened.GetQueue("queue")
+	assert.Equal(t, []string{"1"}, values)
+}
+
+func TestBoltStore_Crash(t *testing.T) {
+	path, store := setupBolt(t, time.Minute)
+	defer store.Close()
+	store.Set("key", "value", time.Minute)
+	store.Enqueue("queue", "1", time.Minute)
+
+	// copy database file without closing store, as if process crashed after the writes
+	copyPath := filepath.Join(t.TempDir(), "copy.db")
+	source, _ := os.Open(path)
+	target, _ := os.Create(copyPath)
+	io.Copy(target, source)
+	source.Close()
+	target.Close()
+
+	recovered, err := stores.NewBoltStore(copyPath, time.Minute)
+	assert.Nil(t, err)
+	defer recovered.Close()
+
+	value, exists, _, _ := recovered.Get("key")
+	assert.Equal(t, "value", value)
+	assert.True(t, exists)
+	values, _, _, _ := recovered.GetQueue("queue")
+	assert.Equal(t, []string{"1"}, values)
+}
+
+func TestBoltStore_Locked(t *testing.T) {
+	path, store := setupBolt(t, time.Minute)
+	defer store.Close()
+
+	// database is locked by the running store
+	_, err := stores.NewBoltStore(path, time.Minute)
+	assert.Error(t, err)
+}
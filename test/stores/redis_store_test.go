@@ -0,0 +1,183 @@
+package stores_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+	throttles "github.com/shoplineapp/captin/internal/throttles"
+	models "github.com/shoplineapp/captin/models"
+	stores "github.com/shoplineapp/captin/stores"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupRedis(t *testing.T) (*miniredis.Miniredis, *stores.RedisStore) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := stores.NewRedisStore(&redis.UniversalOptions{Addrs: []string{server.Addr()}}, "test:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, store
+}
+
+func TestRedisStore_NewRedisStore_Error(t *testing.T) {
+	_, err := stores.NewRedisStore(&redis.UniversalOptions{Addrs: []string{"127.0.0.1:1"}}, "")
+	assert.Error(t, err)
+}
+
+func TestRedisStore_GetSet(t *testing.T) {
+	server, store := setupRedis(t)
+	defer server.Close()
+	defer store.Close()
+
+	value, exists, ttl, err := store.Get("key")
+	assert.Equal(t, "", value)
+	assert.False(t, exists)
+	assert.Equal(t, time.Duration(0), ttl)
+	assert.Nil(t, err)
+
+	result, err := store.Set("key", "value", time.Minute)
+	assert.True(t, result)
+	assert.Nil(t, err)
+	assert.True(t, server.Exists("test:key"))
+
+	server.FastForward(20 * time.Second)
+	value, exists, ttl, err = store.Get("key")
+	assert.Equal(t, "value", value)
+	assert.True(t, exists)
+	assert.Equal(t, 40*time.Second, ttl)
+	assert.Nil(t, err)
+
+	server.FastForward(time.Minute)
+	_, exists, _, _ = store.Get("key")
+	assert.False(t, exists)
+}
+
+func TestRedisStore_Update(t *testing.T) {
+	server, store := setupRedis(t)
+	defer server.Close()
+
+	result, err := store.Update("key", "value")
+	assert.False(t, result)
+	assert.Nil(t, err)
+
+	store.Set("key", "value", time.Minute)
+	server.FastForward(30 * time.Second)
+	result, err = store.Update("key", "updated")
+	assert.True(t, result)
+	assert.Nil(t, err)
+
+	value, _, ttl, _ := store.Get("key")
+	assert.Equal(t, "updated", value)
+	assert.Equal(t, 30*time.Second, ttl)
+}
+
+func TestRedisStore_Remove(t *testing.T) {
+	server, store := setupRedis(t)
+	defer server.Close()
+
+	store.Set("key", "value", time.Minute)
+	result, err := store.Remove("key")
+	assert.True(t, result)
+	assert.Nil(t, err)
+	assert.False(t, server.Exists("test:key"))
+}
+
+func TestRedisStore_Queue(t *testing.T) {
+	server, store := setupRedis(t)
+	defer server.Close()
+
+	values, exists, _, err := store.GetQueue("queue")
+	assert.Equal(t, []string{}, values)
+	assert.False(t, exists)
+	assert.Nil(t, err)
+
+	store.Enqueue("queue", "1", time.Minute)
+	server.FastForward(30 * time.Second)
+	store.Enqueue("queue", "2", time.Minute)
+
+	values, exists, ttl, err := store.GetQueue("queue")
+	assert.Equal(t, []string{"1", "2"}, values)
+	assert.True(t, exists)
+	// ttl is set on first element
+	assert.Equal(t, 30*time.Second, ttl)
+	assert.Nil(t, err)
+
+	server.FastForward(30 * time.Second)
+	_, exists, _, _ = store.GetQueue("queue")
+	assert.False(t, exists)
+}
+
+func TestRedisStore_EnqueueWithLimit(t *testing.T) {
+	server, store := setupRedis(t)
+	defer server.Close()
+
+	for _, value := range []string{"1", "2", "3"} {
+		result, err := store.EnqueueWithLimit("queue", value, time.Minute, 2)
+		assert.True(t, result)
+		assert.Nil(t, err)
+	}
+	values, _, ttl, _ := store.GetQueue("queue")
+	assert.Equal(t, []string{"2", "3"}, values)
+	assert.Equal(t, time.Minute, ttl)
+}
+
+func TestRedisStore_Atomic(t *testing.T) {
+	server, store := setupRedis(t)
+	defer server.Close()
+
+	result, _ := store.SetNX("key", "first", time.Minute)
+	assert.True(t, result)
+	result, _ = store.SetNX("key", "second", time.Minute)
+	assert.False(t, result)
+
+	result, _ = store.CompareAndSwap("key", "second", "third", time.Minute)
+	assert.False(t, result)
+	result, _ = store.CompareAndSwap("key", "first", "third", 2*time.Minute)
+	assert.True(t, result)
+	assert.Equal(t, 2*time.Minute, server.TTL("test:key"))
+
+	count, err := store.Incr("counter", 5, time.Minute)
+	assert.Equal(t, int64(5), count)
+	assert.Nil(t, err)
+	server.FastForward(30 * time.Second)
+	count, _ = store.Incr("counter", -2, time.Minute)
+	assert.Equal(t, int64(3), count)
+	assert.Equal(t, 30*time.Second, server.TTL("test:counter"))
+
+	_, err = store.Incr("key", 1, time.Minute)
+	assert.Error(t, err)
+}
+
+func TestRedisStore_DataKey(t *testing.T) {
+	_, store := setupRedis(t)
+	e := models.IncomingEvent{Key: "product.update", TargetId: "product_id"}
+	d := models.Destination{Config: models.Configuration{Name: "service_one"}}
+	assert.Equal(t, `product\.update.service_one.product_id-data`, store.DataKey(e, d, "", "-data"))
+}
+
+func TestRedisStore_Throttler_Concurrent(t *testing.T) {
+	server, store := setupRedis(t)
+	defer server.Close()
+	throttler := throttles.NewThrottler(store)
+
+	var count int32
+	wg := sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if result, _, _ := throttler.CanTrigger("throttle", time.Minute); result {
+				atomic.AddInt32(&count, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), count)
+}